@@ -5,12 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/tendermint/tendermint/crypto"
 	"math/big"
+	"sort"
+	"sync"
+	"time"
 
 	abci "github.com/tendermint/tendermint/abci/types"
+	cs "github.com/tendermint/tendermint/consensus"
 	"github.com/tendermint/tendermint/mempool"
 	"github.com/tendermint/tendermint/node"
+	"github.com/tendermint/tendermint/p2p"
 	tmtypes "github.com/tendermint/tendermint/types"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -20,8 +26,11 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/rpc"
 
+	"github.com/smartbch/moeingevm/ebp"
 	"github.com/smartbch/moeingevm/types"
 	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/internal/ethutils"
+	"github.com/smartbch/smartbch/staking"
 )
 
 var _ BackendService = &apiBackend{}
@@ -30,8 +39,32 @@ const (
 	// Ethereum Wire Protocol
 	// https://github.com/ethereum/devp2p/blob/master/caps/eth.md
 	protocolVersion = 63
+
+	DefaultMaxHistoryQueries = 16
+
+	// HistoryBusyErrorCode is the JSON-RPC error code of ErrTooManyHistoryQueries
+	HistoryBusyErrorCode = -32008
+
+	// the same as go-ethereum's eth/bloombits.go
+	bloomServiceThreads = 16               // the goroutines reading the bit vectors for all the filters
+	bloomFilterThreads  = 3                // the goroutines of a filter multiplexing its retrievals
+	bloomRetrievalBatch = 16               // the max count of sections in a retrieval
+	bloomRetrievalWait  = time.Duration(0) // how long to wait for more sections to be batched
 )
 
+// ErrTooManyHistoryQueries rejects a query on the history store when maxHistoryQueries of them are running
+var ErrTooManyHistoryQueries = historyBusyError{}
+
+type historyBusyError struct{}
+
+func (historyBusyError) Error() string {
+	return "too many concurrent history queries, please retry later"
+}
+
+func (historyBusyError) ErrorCode() int {
+	return HistoryBusyErrorCode
+}
+
 type apiBackend struct {
 	//extRPCEnabled bool
 	node *node.Node
@@ -41,46 +74,60 @@ type apiBackend struct {
 	//chainSideFeed event.Feed
 	//chainHeadFeed event.Feed
 	//blockProcFeed event.Feed
-	txFeed event.Feed
 	//logsFeed   event.Feed
 	rmLogsFeed event.Feed
 	//pendingLogsFeed event.Feed
+
+	// the slots of the running queries on the history store, nil means unlimited
+	historyQueries chan struct{}
+	// the height of the latest block when this node started
+	startingBlock int64
+
+	// the retrievals of bit vectors from the filters, served by the goroutines started at the first filter
+	bloomRequests chan chan *bloombits.Retrieval
+	bloomOnce     sync.Once
 }
 
-func NewBackend(node *node.Node, app *app.App) BackendService {
-	return &apiBackend{
-		node: node,
-		app:  app,
+// NewBackend creates a backend running at most maxHistoryQueries queries on the history store at the same time,
+// 0 means unlimited
+func NewBackend(node *node.Node, app *app.App, maxHistoryQueries int) BackendService {
+	backend := &apiBackend{
+		node:          node,
+		app:           app,
+		bloomRequests: make(chan chan *bloombits.Retrieval),
+	}
+	if maxHistoryQueries > 0 {
+		backend.historyQueries = make(chan struct{}, maxHistoryQueries)
 	}
+	if node != nil {
+		backend.startingBlock = node.BlockStore().Height()
+	}
+	return backend
 }
 
 func (backend *apiBackend) ChainId() *big.Int {
 	return backend.app.ChainID().ToBig()
 }
 
-func (backend *apiBackend) GetStorageAt(address common.Address, key string, blockNumber int64) []byte {
-	if blockNumber != int64(rpc.LatestBlockNumber) {
-		// TODO: not supported yet
-		return nil
+func (backend *apiBackend) GetStorageAt(address common.Address, key string, height int64) ([]byte, error) {
+	ctx, err := backend.app.GetRpcContextAtHeight(height)
+	if err != nil {
+		return nil, err
 	}
-
-	ctx := backend.app.GetRpcContext()
 	defer ctx.Close(false)
 
 	acc := ctx.GetAccount(address)
 	if acc == nil {
-		return nil
+		return nil, nil
 	}
-	return ctx.GetStorageAt(acc.Sequence(), key)
+	return ctx.GetStorageAt(acc.Sequence(), key), nil
 }
 
-func (backend *apiBackend) GetCode(contract common.Address, blockNumber int64) (bytecode []byte, codeHash []byte) {
-	if blockNumber != int64(rpc.LatestBlockNumber) {
-		// TODO: not supported yet
-		return
+func (backend *apiBackend) GetCode(contract common.Address, height int64) (bytecode []byte, codeHash []byte, err error) {
+	ctx, err := backend.app.GetRpcContextAtHeight(height)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	ctx := backend.app.GetRpcContext()
 	defer ctx.Close(false)
 
 	info := ctx.GetCode(contract)
@@ -92,17 +139,23 @@ func (backend *apiBackend) GetCode(contract common.Address, blockNumber int64) (
 }
 
 func (backend *apiBackend) GetBalance(owner common.Address, height int64) (*big.Int, error) {
-	ctx := backend.app.GetRpcContext()
+	ctx, err := backend.app.GetRpcContextAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
 	defer ctx.Close(false)
-	b, err := ctx.GetBalance(owner, height)
+	b, err := ctx.GetBalance(owner, int64(rpc.LatestBlockNumber)) // ctx is bound to height
 	if err != nil {
 		return nil, err
 	}
 	return b.ToBig(), nil
 }
 
-func (backend *apiBackend) GetNonce(address common.Address) (uint64, error) {
-	ctx := backend.app.GetRpcContext()
+func (backend *apiBackend) GetNonce(address common.Address, height int64) (uint64, error) {
+	ctx, err := backend.app.GetRpcContextAtHeight(height)
+	if err != nil {
+		return 0, err
+	}
 	defer ctx.Close(false)
 	if acc := ctx.GetAccount(address); acc != nil {
 		return acc.Nonce(), nil
@@ -128,6 +181,10 @@ func (backend *apiBackend) GetTransaction(txHash common.Hash) (tx *types.Transac
 	return
 }
 
+func (backend *apiBackend) GetStandbyTxQueue() (txs []app.StandbyTx, qLen int) {
+	return backend.app.GetStandbyTxQueue()
+}
+
 func (backend *apiBackend) BlockByHash(hash common.Hash) (*types.Block, error) {
 	ctx := backend.app.GetHistoryOnlyContext()
 	defer ctx.Close(false)
@@ -144,6 +201,10 @@ func (backend *apiBackend) BlockByNumber(number int64) (*types.Block, error) {
 	return ctx.GetBlockByHeight(uint64(number))
 }
 
+func (backend *apiBackend) ReceiptsRoot(height int64) common.Hash {
+	return backend.app.GetReceiptsRoot(height)
+}
+
 func (backend *apiBackend) ProtocolVersion() int {
 	return protocolVersion
 }
@@ -166,6 +227,50 @@ func (backend *apiBackend) SendRawTx(signedTx []byte) (common.Hash, error) {
 	return backend.broadcastTxSync(signedTx)
 }
 
+// Returns the txs in tendermint's mempool grouped by sender and sorted by nonce. Like geth, the txs whose nonces
+// follow the sender's account nonce continuously are pending, and the ones after a nonce gap are queued.
+func (backend *apiBackend) TxPoolContent() (pending, queued map[common.Address]gethtypes.Transactions) {
+	pending = make(map[common.Address]gethtypes.Transactions)
+	queued = make(map[common.Address]gethtypes.Transactions)
+	if backend.node == nil {
+		return
+	}
+	txsBySender := make(map[common.Address]gethtypes.Transactions)
+	for _, tmTx := range backend.node.Mempool().ReapMaxTxs(-1) {
+		tx, err := ethutils.DecodeTx(tmTx)
+		if err != nil {
+			continue
+		}
+		sender, err := backend.app.GetSender(tx)
+		if err != nil {
+			continue
+		}
+		txsBySender[sender] = append(txsBySender[sender], tx)
+	}
+
+	ctx := backend.app.GetRpcContext()
+	defer ctx.Close(false)
+	for sender, txs := range txsBySender {
+		sort.Sort(gethtypes.TxByNonce(txs))
+		nonce := uint64(0)
+		if acc := ctx.GetAccount(sender); acc != nil {
+			nonce = acc.Nonce()
+		}
+		for i, tx := range txs {
+			if tx.Nonce() < nonce { // already executed or replaced, waiting to be removed by rechecking
+				continue
+			}
+			if tx.Nonce() > nonce {
+				queued[sender] = txs[i:]
+				break
+			}
+			pending[sender] = append(pending[sender], tx)
+			nonce++
+		}
+	}
+	return
+}
+
 func (backend *apiBackend) broadcastTxSync(tx tmtypes.Tx) (common.Hash, error) {
 	resCh := make(chan *abci.Response, 1)
 	err := backend.node.Mempool().CheckTx(tx, func(res *abci.Response) {
@@ -182,17 +287,67 @@ func (backend *apiBackend) broadcastTxSync(tx tmtypes.Tx) (common.Hash, error) {
 	return common.BytesToHash(tx.Hash()), nil
 }
 
-func (backend *apiBackend) Call(tx *gethtypes.Transaction, sender common.Address) (statusCode int, retData []byte) {
-	runner, _ := backend.app.RunTxForRpc(tx, sender, false)
-	return runner.Status, runner.OutData
+func (backend *apiBackend) Call(tx *gethtypes.Transaction, sender common.Address, height int64) (statusCode int, retData []byte, err error) {
+	runner, _, err := backend.app.RunTxForRpcAtHeight(tx, sender, false, height)
+	if err != nil {
+		return 0, nil, err
+	}
+	return runner.Status, runner.OutData, nil
+}
+
+func (backend *apiBackend) EstimateGas(tx *gethtypes.Transaction, sender common.Address, height int64) (statusCode int, retData []byte, gas int64, err error) {
+	runner, gas, err := backend.app.RunTxForRpcAtHeight(tx, sender, true, height)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	return runner.Status, runner.OutData, gas, nil
+}
+
+// Returns the min gas price of the current block (isLast=true) or the next block (isLast=false)
+func (backend *apiBackend) MinGasPrice(isLast bool) uint64 {
+	ctx := backend.app.GetRpcContext()
+	defer ctx.Close(false)
+	return staking.LoadMinGasPrice(ctx, isLast)
+}
+
+func (backend *apiBackend) TraceTx(txHash common.Hash) (*ebp.TxRunner, error) {
+	return backend.app.ReplayTx(txHash)
+}
+
+func (backend *apiBackend) TraceCall(tx *gethtypes.Transaction, sender common.Address, height int64) (*ebp.TxRunner, error) {
+	runner, gas, err := backend.app.RunTxForRpcAtHeight(tx, sender, true, height)
+	if err != nil {
+		return nil, err
+	}
+	// the runners for RPC do not count the used gas, so the estimated gas is reported instead
+	runner.GasUsed = uint64(gas)
+	return runner, nil
+}
+
+// The queries scanning the history store are rejected rather than queued when all the slots are taken
+func (backend *apiBackend) acquireHistoryQuery() error {
+	if backend.historyQueries == nil {
+		return nil
+	}
+	select {
+	case backend.historyQueries <- struct{}{}:
+		return nil
+	default:
+		return ErrTooManyHistoryQueries
+	}
 }
 
-func (backend *apiBackend) EstimateGas(tx *gethtypes.Transaction, sender common.Address) (statusCode int, retData []byte, gas int64) {
-	runner, gas := backend.app.RunTxForRpc(tx, sender, true)
-	return runner.Status, runner.OutData, gas
+func (backend *apiBackend) releaseHistoryQuery() {
+	if backend.historyQueries != nil {
+		<-backend.historyQueries
+	}
 }
 
 func (backend *apiBackend) QueryLogs(addresses []common.Address, topics [][]common.Hash, startHeight, endHeight uint32) ([]types.Log, error) {
+	if err := backend.acquireHistoryQuery(); err != nil {
+		return nil, err
+	}
+	defer backend.releaseHistoryQuery()
 	ctx := backend.app.GetHistoryOnlyContext()
 	defer ctx.Close(false)
 
@@ -200,24 +355,40 @@ func (backend *apiBackend) QueryLogs(addresses []common.Address, topics [][]comm
 }
 
 func (backend *apiBackend) QueryTxBySrc(addr common.Address, startHeight, endHeight, limit uint32) (tx []*types.Transaction, err error) {
+	if err := backend.acquireHistoryQuery(); err != nil {
+		return nil, err
+	}
+	defer backend.releaseHistoryQuery()
 	ctx := backend.app.GetHistoryOnlyContext()
 	defer ctx.Close(false)
 	return ctx.QueryTxBySrc(addr, startHeight, endHeight, limit)
 }
 
 func (backend *apiBackend) QueryTxByDst(addr common.Address, startHeight, endHeight, limit uint32) (tx []*types.Transaction, err error) {
+	if err := backend.acquireHistoryQuery(); err != nil {
+		return nil, err
+	}
+	defer backend.releaseHistoryQuery()
 	ctx := backend.app.GetHistoryOnlyContext()
 	defer ctx.Close(false)
 	return ctx.QueryTxByDst(addr, startHeight, endHeight, limit)
 }
 
 func (backend *apiBackend) QueryTxByAddr(addr common.Address, startHeight, endHeight, limit uint32) (tx []*types.Transaction, err error) {
+	if err := backend.acquireHistoryQuery(); err != nil {
+		return nil, err
+	}
+	defer backend.releaseHistoryQuery()
 	ctx := backend.app.GetHistoryOnlyContext()
 	defer ctx.Close(false)
 	return ctx.QueryTxByAddr(addr, startHeight, endHeight, limit)
 }
 
 func (backend *apiBackend) SbchQueryLogs(addr common.Address, topics []common.Hash, startHeight, endHeight, limit uint32) ([]types.Log, error) {
+	if err := backend.acquireHistoryQuery(); err != nil {
+		return nil, err
+	}
+	defer backend.releaseHistoryQuery()
 	ctx := backend.app.GetHistoryOnlyContext()
 	defer ctx.Close(false)
 
@@ -283,25 +454,22 @@ func (backend *apiBackend) HeaderByHash(ctx context.Context, blockHash common.Ha
 	return &types.Header{
 		Number:    uint64(block.Number),
 		BlockHash: block.Hash,
+		Bloom:     block.LogsBloom,
 	}, nil
 }
 func (backend *apiBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (gethtypes.Receipts, error) {
 	appCtx := backend.app.GetHistoryOnlyContext()
 	defer appCtx.Close(false)
 
-	receipts := make([]*gethtypes.Receipt, 0, 8)
-
-	// TODO: query receipts
-	//block, err := appCtx.GetBlockByHash(blockHash)
-	//if err == nil && block != nil {
-	//	for _, txHash := range block.Transactions {
-	//		tx, err := appCtx.GetTxByHash(txHash)
-	//		if err == nil && tx != nil {
-	//			receipts = append(receipts, toGethReceipt(tx))
-	//		}
-	//	}
-	//}
-	return receipts, nil
+	block, err := appCtx.GetBlockByHash(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := appCtx.GetTxListByHeight(uint32(block.Number))
+	if err != nil {
+		return nil, err
+	}
+	return ethutils.ToGethReceipts(txs), nil
 }
 
 func (backend *apiBackend) GetLogs(ctx context.Context, blockHash common.Hash) ([][]*gethtypes.Log, error) {
@@ -312,13 +480,19 @@ func (backend *apiBackend) GetLogs(ctx context.Context, blockHash common.Hash) (
 
 	block, err := appCtx.GetBlockByHash(blockHash)
 	if err == nil && block != nil {
-		for _, txHash := range block.Transactions {
+		logIndex := uint(0)
+		for i, txHash := range block.Transactions {
 			tx, err := appCtx.GetTxByHash(txHash)
 			if err == nil && tx != nil {
 				txLogs := types.ToGethLogs(tx.Logs)
-				// fix log.TxHash
+				// fix the metadata of logs
 				for _, txLog := range txLogs {
 					txLog.TxHash = tx.Hash
+					txLog.TxIndex = uint(i)
+					txLog.BlockHash = block.Hash
+					txLog.BlockNumber = uint64(block.Number)
+					txLog.Index = logIndex
+					logIndex++
 				}
 				logs = append(logs, txLogs)
 			}
@@ -335,7 +509,7 @@ func (backend *apiBackend) SubscribeLogsEvent(ch chan<- []*gethtypes.Log) event.
 	return backend.app.SubscribeLogsEvent(ch)
 }
 func (backend *apiBackend) SubscribeNewTxsEvent(ch chan<- gethcore.NewTxsEvent) event.Subscription {
-	return backend.txFeed.Subscribe(ch)
+	return backend.app.SubscribeNewTxsEvent(ch)
 }
 func (backend *apiBackend) SubscribeRemovedLogsEvent(ch chan<- gethcore.RemovedLogsEvent) event.Subscription {
 	return backend.rmLogsFeed.Subscribe(ch)
@@ -345,11 +519,38 @@ func (backend *apiBackend) SubscribeRemovedLogsEvent(ch chan<- gethcore.RemovedL
 //	return b2.pendingLogsFeed.Subscribe(ch)
 //}
 
+// BloomStatus returns the section size of the bloombits index and the count of indexed sections
 func (backend *apiBackend) BloomStatus() (uint64, uint64) {
-	return 4096, 0 // TODO: this is temporary implementation
+	return backend.app.BloomStatus()
 }
+
+// ServiceFilter serves the retrievals of bit vectors from the session until it is closed
 func (backend *apiBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
-	panic("implement me")
+	backend.bloomOnce.Do(backend.startBloomHandlers)
+	for i := 0; i < bloomFilterThreads; i++ {
+		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, backend.bloomRequests)
+	}
+}
+
+// Start the goroutines reading the bit vectors from the bloombits index, which run until the node exits
+func (backend *apiBackend) startBloomHandlers() {
+	for i := 0; i < bloomServiceThreads; i++ {
+		go func() {
+			for request := range backend.bloomRequests {
+				task := <-request
+				task.Bitsets = make([][]byte, len(task.Sections))
+				for i, section := range task.Sections {
+					bitset, err := backend.app.BloomBits(task.Bit, section)
+					if err != nil {
+						task.Error = err
+						break
+					}
+					task.Bitsets[i] = bitset
+				}
+				request <- task
+			}
+		}()
+	}
 }
 
 /*-----------------------tendermint info----------------------------*/
@@ -383,3 +584,101 @@ func (backend *apiBackend) NodeInfo() Info {
 	}
 	return i
 }
+
+/*-----------------------node status----------------------------*/
+
+// SyncStatus is the progress of catching up with the peers
+type SyncStatus struct {
+	CatchingUp    bool
+	StartingBlock int64
+	CurrentBlock  int64
+	HighestBlock  int64
+}
+
+// The node status methods report an idle node without peers if there is no tendermint node, as in unit tests
+
+func (backend *apiBackend) PeerCount() int {
+	if backend.node == nil {
+		return 0
+	}
+	return backend.node.Switch().Peers().Size()
+}
+
+func (backend *apiBackend) IsListening() bool {
+	if backend.node == nil {
+		return false
+	}
+	return backend.node.IsListening()
+}
+
+func (backend *apiBackend) SyncStatus() SyncStatus {
+	if backend.node == nil {
+		current := backend.app.GetLatestBlockNum()
+		return SyncStatus{StartingBlock: backend.startingBlock, CurrentBlock: current, HighestBlock: current}
+	}
+	current := backend.node.BlockStore().Height()
+	status := SyncStatus{
+		CatchingUp:    backend.node.ConsensusReactor().WaitSync(),
+		StartingBlock: backend.startingBlock,
+		CurrentBlock:  current,
+		HighestBlock:  current,
+	}
+	for _, peer := range backend.node.Switch().Peers().List() {
+		// a peer reports the height it is working on, so the block before it has been committed
+		if ps, ok := peer.Get(tmtypes.PeerStateKey).(*cs.PeerState); ok && ps.GetHeight()-1 > status.HighestBlock {
+			status.HighestBlock = ps.GetHeight() - 1
+		}
+	}
+	return status
+}
+
+// ValidatorRewardTo returns where the rewards of this node's validator go, or false if this node is not a validator
+func (backend *apiBackend) ValidatorRewardTo() (common.Address, bool) {
+	if backend.node == nil {
+		return common.Address{}, false
+	}
+	pubKey, err := backend.node.PrivValidator().GetPubKey()
+	if err != nil {
+		return common.Address{}, false
+	}
+	ctx := backend.app.GetRpcContext()
+	defer ctx.Close(false)
+	_, info := staking.LoadStakingAcc(ctx)
+	for _, v := range info.Validators {
+		if bytes.Equal(v.Pubkey[:], pubKey.Bytes()) {
+			return v.RewardTo, true
+		}
+	}
+	return common.Address{}, false
+}
+
+/*-----------------------p2p and mempool administration----------------------------*/
+
+func (backend *apiBackend) P2PNodeInfo() p2p.NodeInfo {
+	return backend.node.NodeInfo()
+}
+
+func (backend *apiBackend) Peers() []p2p.Peer {
+	return backend.node.Switch().Peers().List()
+}
+
+// DialPeer connects to a peer in the format of <id>@<host>:<port> in the background
+func (backend *apiBackend) DialPeer(addr string) error {
+	return backend.node.Switch().DialPeersAsync([]string{addr})
+}
+
+// StopPeer disconnects a peer, a persistent one will be reconnected by tendermint
+func (backend *apiBackend) StopPeer(id string) error {
+	peer := backend.node.Switch().Peers().Get(p2p.ID(id))
+	if peer == nil {
+		return fmt.Errorf("peer %s is not connected", id)
+	}
+	backend.node.Switch().StopPeerGracefully(peer)
+	return nil
+}
+
+// FlushMempool drops all the txs in mempool. The app forgets them after two blocks,
+// since they are not rechecked any more.
+func (backend *apiBackend) FlushMempool() {
+	backend.node.Mempool().Flush()
+}
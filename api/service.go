@@ -10,8 +10,11 @@ import (
 	gethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/tendermint/tendermint/p2p"
 
+	"github.com/smartbch/moeingevm/ebp"
 	motypes "github.com/smartbch/moeingevm/types"
+	"github.com/smartbch/smartbch/app"
 )
 
 type FilterService interface {
@@ -54,6 +57,7 @@ type BackendService interface {
 	CurrentBlock() (*motypes.Block, error)
 	BlockByNumber(number int64) (*motypes.Block, error)
 	BlockByHash(hash common.Hash) (*motypes.Block, error)
+	ReceiptsRoot(height int64) common.Hash
 	//BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error)
 	//StateAndHeaderByNumber(ctx context.Context, number int64) (*state.StateDB, error)
 	//StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error)
@@ -64,11 +68,12 @@ type BackendService interface {
 	// Transaction pool API
 	SendRawTx(signedTx []byte) (common.Hash, error)
 	GetTransaction(txHash common.Hash) (tx *motypes.Transaction, blockHash common.Hash, blockNumber uint64, blockIndex uint64, err error)
+	GetStandbyTxQueue() (txs []app.StandbyTx, qLen int)
 	//GetPoolTransactions() (types.Transactions, error)
 	//GetPoolTransaction(txHash common.Hash) *types.Transaction
 	//GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	//Stats() (pending int, queued int)
-	//TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
+	TxPoolContent() (pending, queued map[common.Address]gethtypes.Transactions)
 
 	// Filter API
 	//BloomStatus() (uint64, uint64)
@@ -85,12 +90,16 @@ type BackendService interface {
 	//Engine() consensus.Engine
 
 	//Below is added in moeing chain only
-	GetNonce(address common.Address) (uint64, error)
+	//the height of a state is the number of its block, and a negative height means the latest state
+	MinGasPrice(isLast bool) uint64
+	GetNonce(address common.Address, height int64) (uint64, error)
 	GetBalance(address common.Address, height int64) (*big.Int, error)
-	GetCode(contract common.Address, blockNumber int64) (bytecode []byte, codeHash []byte)
-	GetStorageAt(address common.Address, key string, blockNumber int64) []byte
-	Call(tx *gethtypes.Transaction, from common.Address) (statusCode int, retData []byte)
-	EstimateGas(tx *gethtypes.Transaction, from common.Address) (statusCode int, retData []byte, gas int64)
+	GetCode(contract common.Address, height int64) (bytecode []byte, codeHash []byte, err error)
+	GetStorageAt(address common.Address, key string, height int64) ([]byte, error)
+	Call(tx *gethtypes.Transaction, from common.Address, height int64) (statusCode int, retData []byte, err error)
+	EstimateGas(tx *gethtypes.Transaction, from common.Address, height int64) (statusCode int, retData []byte, gas int64, err error)
+	TraceTx(txHash common.Hash) (*ebp.TxRunner, error)
+	TraceCall(tx *gethtypes.Transaction, from common.Address, height int64) (*ebp.TxRunner, error)
 	QueryLogs(addresses []common.Address, topics [][]common.Hash, startHeight, endHeight uint32) ([]motypes.Log, error)
 	QueryTxBySrc(address common.Address, startHeight, endHeight, limit uint32) (tx []*motypes.Transaction, err error)
 	QueryTxByDst(address common.Address, startHeight, endHeight, limit uint32) (tx []*motypes.Transaction, err error)
@@ -104,4 +113,15 @@ type BackendService interface {
 
 	//tendermint info
 	NodeInfo() Info
+	PeerCount() int
+	IsListening() bool
+	SyncStatus() SyncStatus
+	ValidatorRewardTo() (common.Address, bool)
+
+	// p2p and mempool administration
+	P2PNodeInfo() p2p.NodeInfo
+	Peers() []p2p.Peer
+	DialPeer(addr string) error
+	StopPeer(id string) error
+	FlushMempool()
 }
@@ -8,6 +8,7 @@ import (
 	"math"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,7 @@ import (
 	gethcmn "github.com/ethereum/go-ethereum/common"
 	gethcore "github.com/ethereum/go-ethereum/core"
 	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/rlp"
 
@@ -24,6 +26,8 @@ import (
 	"github.com/tendermint/tendermint/crypto/ed25519"
 	cryptoenc "github.com/tendermint/tendermint/crypto/encoding"
 	"github.com/tendermint/tendermint/libs/log"
+	mempl "github.com/tendermint/tendermint/mempool"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
 
 	"github.com/smartbch/moeingads"
 	"github.com/smartbch/moeingads/store"
@@ -64,17 +68,25 @@ const (
 	InvalidMinGasPrice   uint32 = 107
 	HasPendingTx         uint32 = 108
 	MempoolBusy          uint32 = 109
+	UnknownQueryPath     uint32 = 110
+	InvalidQueryParam    uint32 = 111
+	QueryTargetNotFound  uint32 = 112
+	HeightNotAvailable   uint32 = 113
+	TooManyPendingTxs    uint32 = 114
 
 	PruneEveryN = 10
 
 	BlockMaxBytes = 24 * 1024 * 1024 // 24MB
 	BlockMaxGas   = 900_000_000_000
+
+	NewTxsQueueSize = 4096 // the new txs waiting to be sent to txFeed
 )
 
 type App struct {
 	mtx sync.Mutex
 
 	//config
+	config       *param.ChainConfig
 	chainId      *uint256.Int
 	retainBlocks int64
 
@@ -83,15 +95,18 @@ type App struct {
 	root          *store.RootStore
 	numKeptBlocks int64
 	historyStore  modbtypes.DB
+	archive       *archiveStore // the states of past blocks, only used in archive mode
+	bloomBits     *bloomBitsStore
 
 	//refresh with block
-	currHeight      int64
+	currHeight      int64 // written atomically, and read atomically outside of the ABCI methods
 	trunk           *store.TrunkStore
 	checkTrunk      *store.TrunkStore
 	block           *types.Block
 	blockInfo       atomic.Value // to store *types.BlockInfo
 	slashValidators [][20]byte
 	lastCommitInfo  [][]byte
+	lastAbsentees   [][]byte
 	lastProposer    [20]byte
 	lastGasUsed     uint64
 	lastGasRefund   uint256.Int
@@ -101,12 +116,14 @@ type App struct {
 	// feeds
 	chainFeed event.Feed
 	logsFeed  event.Feed
+	txFeed    event.Feed
 	scope     event.SubscriptionScope
+	newTxs    chan *gethtypes.Transaction // drained to txFeed by publishNewTxs
+	quit      chan struct{}
 
 	//engine
-	txEngine     ebp.TxExecutor
-	reorderSeed  int64
-	touchedAddrs map[gethcmn.Address]int
+	txEngine    ebp.TxExecutor
+	reorderSeed int64
 
 	//watcher
 	watcher   *staking.Watcher
@@ -118,18 +135,40 @@ type App struct {
 
 	//genesis data
 	currValidators []*stakingtypes.Validator
+	// the validators jailed in last block, whose voting power must be set to zero in EndBlock
+	jailedValidators []*stakingtypes.Validator
 
 	//signature cache, cache ecrecovery's resulting sender addresses, to speed up checktx
 	sigCache     map[gethcmn.Hash]SenderAndHeight
 	sigCacheSize int
+	sigCacheMtx  sync.Mutex // sigCache is also read by the txpool RPC
+
+	// the pending transactions accepted by checktx, sorted by nonce for each sender
+	pendingTxs *pendingTxQueue
+	// tendermint's mempool, from which the replaced txs are removed
+	mempool MempoolTxRemover
+	// whether the standby queue was empty before preparing the txs in current block
+	allTxsExecuted bool
 
 	// the senders who send native tokens through sep206 in the last block
 	sep206SenderSet map[gethcmn.Address]struct{}
 	// it shows how many tx remains in the mempool after committing a new block
 	recheckCounter int
 	// if recheckCounter is larger than recheckThreshold, mempool is in a traffic-jam status
-	// and we'd better refuse further transactions
+	// and we'd better refuse further transactions, unless they pay more than the remained ones
 	recheckThreshold int
+	// if there are more than recheckThreshold pending txs in mempool, it is the gas price of the
+	// recheckThreshold-th highest-paying tx, and the txs paying less are evicted during rechecking
+	jamGasPrice *uint256.Int
+
+	//state-sync snapshots
+	snapshots *snapshotStore
+	restore   *snapshotRestore
+
+	//the handlers of scheduled upgrades, indexed by name
+	upgradeHandlers map[string]UpgradeHandler
+	halted          chan struct{} // closed once the block at the halt height is committed
+	haltOnce        sync.Once
 }
 
 // The value entry of signature cache. The Height helps in evicting old entries.
@@ -146,8 +185,10 @@ func NewApp(config *param.ChainConfig, chainId *uint256.Int, logger log.Logger)
 	/*------signature cache------*/
 	app.sigCacheSize = config.SigCacheSize
 	app.sigCache = make(map[gethcmn.Hash]SenderAndHeight, app.sigCacheSize)
+	app.pendingTxs = newPendingTxQueue(config.MaxPendingTxsPerSender, config.MaxPendingNonceGap)
 
 	/*------set config------*/
+	app.config = config
 	app.retainBlocks = config.RetainBlocks
 	app.chainId = chainId
 
@@ -155,8 +196,18 @@ func NewApp(config *param.ChainConfig, chainId *uint256.Int, logger log.Logger)
 	app.numKeptBlocks = int64(config.NumKeptBlocks)
 	app.root, app.mads = createRootStore(config)
 	app.historyStore = createHistoryStore(config)
+	app.archive = createArchiveStore(config)
+	app.bloomBits = createBloomBitsStore(config)
 	app.trunk = app.root.GetTrunkStore().(*store.TrunkStore)
 	app.checkTrunk = app.root.GetReadOnlyTrunkStore().(*store.TrunkStore)
+	app.snapshots = newSnapshotStore(config)
+	app.registerUpgradeHandlers()
+
+	/*------set feeds------*/
+	app.newTxs = make(chan *gethtypes.Transaction, NewTxsQueueSize)
+	app.quit = make(chan struct{})
+	app.halted = make(chan struct{})
+	go app.publishNewTxs()
 
 	/*------set util------*/
 	app.signer = gethtypes.NewEIP155Signer(app.chainId.ToBig())
@@ -177,8 +228,7 @@ func NewApp(config *param.ChainConfig, chainId *uint256.Int, logger log.Logger)
 	ebp.PredefinedSystemContractExecutor = &staking.StakingContractExecutor{}
 	ebp.PredefinedSystemContractExecutor.Init(ctx)
 
-	// We make these maps not for really usage, just to avoid accessing nil-maps
-	app.touchedAddrs = make(map[gethcmn.Address]int)
+	// We make this map not for really usage, just to avoid accessing a nil-map
 	app.sep206SenderSet = make(map[gethcmn.Address]struct{})
 
 	/*------set refresh field------*/
@@ -186,9 +236,18 @@ func NewApp(config *param.ChainConfig, chainId *uint256.Int, logger log.Logger)
 	app.block = &types.Block{}
 	if prevBlk != nil {
 		app.block.Number = prevBlk.Number
-		app.currHeight = app.block.Number
+		atomic.StoreInt64(&app.currHeight, app.block.Number)
 	}
 
+	if app.archive != nil {
+		if err := app.archive.setup(archivedHeight(app.currHeight)); err != nil {
+			panic(err)
+		}
+	}
+
+	// the block at currHeight is added to the history store when committing the next block
+	app.bloomBits.setup(app.currHeight-1, app.historyStore.GetBlockByHeight)
+
 	app.root.SetHeight(app.currHeight + 1)
 	if app.currHeight != 0 {
 		app.reload()
@@ -207,9 +266,13 @@ func NewApp(config *param.ChainConfig, chainId *uint256.Int, logger log.Logger)
 }
 
 func createRootStore(config *param.ChainConfig) (*store.RootStore, *moeingads.MoeingADS) {
+	return createRootStoreInDir(config.AppDataPath)
+}
+
+func createRootStoreInDir(dir string) (*store.RootStore, *moeingads.MoeingADS) {
 	first := []byte{0, 0, 0, 0, 0, 0, 0, 0}
 	last := []byte{255, 255, 255, 255, 255, 255, 255, 255}
-	mads, err := moeingads.NewMoeingADS(config.AppDataPath, false, [][]byte{first, last})
+	mads, err := moeingads.NewMoeingADS(dir, false, [][]byte{first, last})
 	if err != nil {
 		panic(err)
 	}
@@ -245,6 +308,7 @@ func (app *App) reload() {
 func (app *App) Info(req abcitypes.RequestInfo) abcitypes.ResponseInfo {
 	fmt.Printf("Info app.block.Number %d\n", app.block.Number)
 	return abcitypes.ResponseInfo{
+		AppVersion:       app.config.AppVersionAt(app.block.Number),
 		LastBlockHeight:  app.block.Number,
 		LastBlockAppHash: app.root.GetRootHash(),
 	}
@@ -254,87 +318,179 @@ func (app *App) SetOption(option abcitypes.RequestSetOption) abcitypes.ResponseS
 	return abcitypes.ResponseSetOption{}
 }
 
-func (app *App) Query(req abcitypes.RequestQuery) abcitypes.ResponseQuery {
-	return abcitypes.ResponseQuery{Code: abcitypes.CodeTypeOK}
-}
-
 func (app *App) CheckTx(req abcitypes.RequestCheckTx) abcitypes.ResponseCheckTx {
 	app.logger.Debug("enter check tx!")
 	if req.Type == abcitypes.CheckTxType_Recheck {
 		app.recheckCounter++ // calculate how many TXs remain in the mempool after a new block
-	} else if app.recheckCounter > app.recheckThreshold {
-		// Refuse to accept new TXs to drain the remain TXs
-		return abcitypes.ResponseCheckTx{Code: MempoolBusy, Info: "mempool is too busy"}
 	}
 	tx := &gethtypes.Transaction{}
 	err := tx.DecodeRLP(rlp.NewStream(bytes.NewReader(req.Tx), 0))
 	if err != nil {
 		return abcitypes.ResponseCheckTx{Code: CannotDecodeTx}
 	}
+	gasPrice, _ := uint256.FromBig(tx.GasPrice())
+	if req.Type == abcitypes.CheckTxType_New && app.recheckCounter > app.recheckThreshold && !app.outbidsJam(gasPrice) {
+		// Refuse to accept new TXs which pay no more than the remain TXs, to drain them
+		return abcitypes.ResponseCheckTx{Code: MempoolBusy, Info: "mempool is too busy"}
+	}
 	txid := tx.Hash()
-	var sender gethcmn.Address
-	senderAndHeight, ok := app.sigCache[txid]
-	if ok { // cache hit
-		sender = senderAndHeight.Sender
-	} else { // cache miss
-		sender, err = app.signer.Sender(tx)
-		if err != nil {
-			return abcitypes.ResponseCheckTx{Code: CannotRecoverSender, Info: "invalid sender: " + err.Error()}
+	sender, err := app.GetSender(tx)
+	if err != nil {
+		return abcitypes.ResponseCheckTx{Code: CannotRecoverSender, Info: "invalid sender: " + err.Error()}
+	}
+	if req.Type == abcitypes.CheckTxType_Recheck {
+		pending := app.pendingTxs.getQueue(sender).get(tx.Nonce())
+		if pending != nil && (pending.hash != txid || pending.inBlock) {
+			return abcitypes.ResponseCheckTx{Code: HasPendingTx, Info: "nonce is taken by another transaction"}
 		}
-		if len(app.sigCache) > app.sigCacheSize { //select one old entry to evict
-			delKey, minHeight, count := gethcmn.Hash{}, int64(math.MaxInt64), 6 /*iterate 6 steps*/
-			for key, value := range app.sigCache {                              //pseudo-random iterate
-				if minHeight > value.Height { //select the oldest entry within a short iteration
-					minHeight, delKey = value.Height, key
-				}
-				if count--; count == 0 {
-					break
-				}
+		if app.jamGasPrice != nil && gasPrice.Lt(app.jamGasPrice) {
+			// make room for the txs paying more
+			app.pendingTxs.remove(sender, tx.Nonce(), txid)
+			return abcitypes.ResponseCheckTx{Code: MempoolBusy, Info: "evicted by the transactions paying more"}
+		}
+		// During rechecking, if the sender has not not been touched or lose balance, the tx can pass
+		// as long as its nonce is not consumed
+		if _, ok := app.sep206SenderSet[sender]; !ok && pending != nil {
+			res := app.recheckNonce(tx, sender)
+			if res.Code == abcitypes.CodeTypeOK {
+				pending.height = app.currHeight
+			} else {
+				app.pendingTxs.remove(sender, tx.Nonce(), txid)
 			}
-			delete(app.sigCache, delKey)
+			return res
 		}
-		app.sigCache[txid] = SenderAndHeight{sender, app.currHeight} // add to cache
 	}
-	if _, ok := app.touchedAddrs[sender]; ok {
-		// if the sender is touched, it is most likely to have an uncertain nonce, so we reject it
-		return abcitypes.ResponseCheckTx{Code: HasPendingTx, Info: "still has pending transaction"}
+	replaced := app.pendingTxs.getQueue(sender).get(tx.Nonce())
+	res := app.checkTx(tx, sender, gasPrice, mempl.TxKey(req.Tx))
+	if req.Type == abcitypes.CheckTxType_Recheck && res.Code != abcitypes.CodeTypeOK {
+		app.pendingTxs.remove(sender, tx.Nonce(), txid)
 	}
-	if req.Type == abcitypes.CheckTxType_Recheck {
-		// During rechecking, if the sender has not not been touched or lose balance, the tx can pass
-		if _, ok := app.sep206SenderSet[sender]; !ok {
-			return abcitypes.ResponseCheckTx{
-				Code:      abcitypes.CodeTypeOK,
-				GasWanted: int64(tx.Gas()),
+	if req.Type == abcitypes.CheckTxType_New && res.Code == abcitypes.CodeTypeOK {
+		if replaced != nil && replaced.hash != txid && app.mempool != nil {
+			app.mempool.RemoveTxByKey(replaced.key, false)
+		}
+		// do not block CheckTx by slow subscribers
+		select {
+		case app.newTxs <- tx:
+		default:
+			app.logger.Debug("new txs queue is full, tx not published", "hash", txid.Hex())
+		}
+	}
+	return res
+}
+
+// When mempool is jammed, a new tx must pay more than the remained ones to get in
+func (app *App) outbidsJam(gasPrice *uint256.Int) bool {
+	jamGasPrice := app.jamGasPrice
+	if jamGasPrice == nil { // the remained txs are not checked by this node
+		jamGasPrice = uint256.NewInt().SetUint64(app.lastMinGasPrice)
+	}
+	return gasPrice.Gt(jamGasPrice)
+}
+
+// Get the sender of tx, with the help of signature cache. It is also used by the txpool RPC.
+func (app *App) GetSender(tx *gethtypes.Transaction) (sender gethcmn.Address, err error) {
+	app.sigCacheMtx.Lock()
+	defer app.sigCacheMtx.Unlock()
+	txid := tx.Hash()
+	senderAndHeight, ok := app.sigCache[txid]
+	if ok { // cache hit
+		return senderAndHeight.Sender, nil
+	}
+	// cache miss
+	sender, err = app.signer.Sender(tx)
+	if err != nil {
+		return
+	}
+	if len(app.sigCache) > app.sigCacheSize { //select one old entry to evict
+		delKey, minHeight, count := gethcmn.Hash{}, int64(math.MaxInt64), 6 /*iterate 6 steps*/
+		for key, value := range app.sigCache {                              //pseudo-random iterate
+			if minHeight > value.Height { //select the oldest entry within a short iteration
+				minHeight, delKey = value.Height, key
+			}
+			if count--; count == 0 {
+				break
 			}
 		}
+		delete(app.sigCache, delKey)
 	}
-	return app.checkTx(tx, sender)
+	app.sigCache[txid] = SenderAndHeight{sender, atomic.LoadInt64(&app.currHeight)} // add to cache
+	return
 }
 
-func (app *App) checkTx(tx *gethtypes.Transaction, sender gethcmn.Address) abcitypes.ResponseCheckTx {
+func (app *App) recheckNonce(tx *gethtypes.Transaction, sender gethcmn.Address) abcitypes.ResponseCheckTx {
 	ctx := app.GetCheckTxContext()
-	dirty := false
-	defer func(dirtyPtr *bool) {
-		ctx.Close(*dirtyPtr)
-	}(&dirty)
+	defer ctx.Close(false)
+	acc := ctx.GetAccount(sender)
+	if acc == nil || tx.Nonce() < acc.Nonce() {
+		return abcitypes.ResponseCheckTx{Code: AccountNonceMismatch, Info: "bad nonce: " + types.ErrNonceTooSmall.Error()}
+	}
+	// the txs before it may have been evicted
+	if tx.Nonce() > app.pendingTxs.getQueue(sender).nextNonce(acc.Nonce())+app.pendingTxs.maxNonceGap {
+		return abcitypes.ResponseCheckTx{Code: AccountNonceMismatch, Info: "bad nonce: " + types.ErrNonceTooLarge.Error()}
+	}
+	return abcitypes.ResponseCheckTx{
+		Code:      abcitypes.CodeTypeOK,
+		GasWanted: int64(tx.Gas()),
+	}
+}
+
+// Check tx against its sender's account and pending transactions. Its nonce can go beyond the sender's
+// pending transactions by at most MaxPendingNonceGap, which is 0 by default because tendermint proposes
+// the txs in mempool in FIFO order and a tx with a nonce gap may fail in the block without paying any fee.
+// The sender must afford the gas fees of all its pending transactions in mempool, and the values of all
+// its pending transactions.
+// A pending tx in mempool can be replaced by a new one with the same nonce, if the new one pays enough more.
+func (app *App) checkTx(tx *gethtypes.Transaction, sender gethcmn.Address, gasPrice *uint256.Int,
+	txKey [mempl.TxKeySize]byte) abcitypes.ResponseCheckTx {
+	ctx := app.GetCheckTxContext()
+	defer ctx.Close(false)
 
 	//todo: replace with engine param
 	if tx.Gas() > ebp.MaxTxGasLimit {
 		return abcitypes.ResponseCheckTx{Code: GasLimitInvalid, Info: "invalid gas limit"}
 	}
-	acc, err := ctx.CheckNonce(sender, tx.Nonce())
-	if err != nil {
-		return abcitypes.ResponseCheckTx{Code: AccountNonceMismatch, Info: "bad nonce: " + err.Error()}
+	acc := ctx.GetAccount(sender)
+	if acc == nil {
+		return abcitypes.ResponseCheckTx{Code: AccountNonceMismatch, Info: "bad nonce: " + types.ErrAccountNotExist.Error()}
+	}
+	nonce := tx.Nonce()
+	if nonce < acc.Nonce() {
+		return abcitypes.ResponseCheckTx{Code: AccountNonceMismatch, Info: "bad nonce: " + types.ErrNonceTooSmall.Error()}
+	}
+	queue := app.pendingTxs.getQueue(sender)
+	if pending := queue.get(nonce); pending != nil {
+		if pending.inBlock {
+			return abcitypes.ResponseCheckTx{Code: HasPendingTx, Info: "still has pending transaction with the same nonce"}
+		}
+		if pending.hash != tx.Hash() && !canReplace(pending.gasPrice, gasPrice, app.config.ReplaceTxPriceBump) {
+			return abcitypes.ResponseCheckTx{Code: HasPendingTx, Info: "replacement transaction underpriced"}
+		}
+	} else if queue.len() >= app.pendingTxs.maxTxsPerSender {
+		return abcitypes.ResponseCheckTx{Code: TooManyPendingTxs, Info: "too many pending transactions"}
+	}
+	if nonce > queue.nextNonce(acc.Nonce())+app.pendingTxs.maxNonceGap {
+		return abcitypes.ResponseCheckTx{Code: AccountNonceMismatch, Info: "bad nonce: " + types.ErrNonceTooLarge.Error()}
 	}
-	gasPrice, _ := uint256.FromBig(tx.GasPrice())
 	if gasPrice.Cmp(uint256.NewInt().SetUint64(app.lastMinGasPrice)) < 0 {
 		return abcitypes.ResponseCheckTx{Code: InvalidMinGasPrice, Info: "gas price too small"}
 	}
-	err = ctx.DeductTxFee(sender, acc, tx.Gas(), gasPrice)
-	if err != nil {
+	gasFee := uint256.NewInt().SetUint64(tx.Gas())
+	value, overflow := uint256.FromBig(tx.Value())
+	total, ok := queue.reservedBalance(nonce)
+	if overflow || !ok || gasFee.MulOverflow(gasFee, gasPrice) || total.AddOverflow(total, gasFee) ||
+		total.AddOverflow(total, value) || acc.Balance().Cmp(total) < 0 {
 		return abcitypes.ResponseCheckTx{Code: CannotPayGasFee, Info: "failed to deduct tx fee"}
 	}
-	dirty = true
+	app.pendingTxs.set(sender, &pendingTx{
+		hash:     tx.Hash(),
+		key:      txKey,
+		nonce:    nonce,
+		gasPrice: gasPrice,
+		gasFee:   gasFee,
+		value:    value,
+		height:   app.currHeight,
+	})
 	app.logger.Debug("leave check tx!")
 	return abcitypes.ResponseCheckTx{
 		Code:      abcitypes.CodeTypeOK,
@@ -349,6 +505,7 @@ func (app *App) InitChain(req abcitypes.RequestInitChain) abcitypes.ResponseInit
 
 	ctx := app.GetRunTxContext()
 	var genesisValidators []*stakingtypes.Validator
+	var genesisStakingInfo *stakingtypes.StakingInfo
 	if len(req.AppStateBytes) != 0 {
 		//fmt.Printf("appstate:%s\n", req.AppStateBytes)
 		genesisData := GenesisData{}
@@ -357,10 +514,16 @@ func (app *App) InitChain(req abcitypes.RequestInitChain) abcitypes.ResponseInit
 			panic(err)
 		}
 
-		app.createGenesisAccs(genesisData.Alloc)
+		if err = app.createGenesisAccs(genesisData.Version, genesisData.Alloc); err != nil {
+			panic(err)
+		}
 		genesisValidators = genesisData.Validators
+		genesisStakingInfo = genesisData.StakingInfo
 	}
 
+	if genesisStakingInfo != nil {
+		genesisValidators = genesisStakingInfo.Validators
+	}
 	if len(genesisValidators) == 0 {
 		panic("no genesis validator in genesis.json")
 	}
@@ -380,12 +543,15 @@ func (app *App) InitChain(req abcitypes.RequestInitChain) abcitypes.ResponseInit
 			Address: genesisValidators[i].Address,
 		}
 	}
+	if genesisStakingInfo != nil {
+		info = *genesisStakingInfo
+	}
 	staking.SaveStakingInfo(ctx, stakingAcc, info)
 	ctx.Close(true)
 
 	var activeValidator []*stakingtypes.Validator
 	for _, v := range genesisValidators {
-		if uint256.NewInt().SetBytes(v.StakedCoins[:]).Cmp(staking.MinimumStakingAmount) >= 0 && !v.IsRetiring && v.VotingPower > 0 {
+		if uint256.NewInt().SetBytes(v.StakedCoins[:]).Cmp(staking.MinimumStakingAmount) >= 0 && !v.IsRetiring && !v.IsJailed && v.VotingPower > 0 {
 			activeValidator = append(activeValidator, v)
 		}
 	}
@@ -412,31 +578,111 @@ func (app *App) InitChain(req abcitypes.RequestInitChain) abcitypes.ResponseInit
 	}
 }
 
-func (app *App) createGenesisAccs(alloc gethcore.GenesisAlloc) {
+// Write the accounts in genesis alloc with the same key layout as the EVM does. Contract code gets a new
+// sequence from the creation counter, and the accounts which already exist keep their sequences.
+func (app *App) createGenesisAccs(version int, alloc gethcore.GenesisAlloc) error {
+	if version > CurrGenesisVersion {
+		return fmt.Errorf("unsupported genesis version %d", version)
+	}
 	if len(alloc) == 0 {
-		return
+		return nil
 	}
 
-	rbt := rabbit.NewRabbitStore(app.trunk)
+	rbt := rabbit.NewRabbitStore(app.trunkStore())
+
+	app.logger.Info("air drop", "accounts", len(alloc), "version", version)
+	if version == GenesisVersionLegacy {
+		createLegacyGenesisAccs(rbt, alloc)
+		rbt.Close()
+		rbt.WriteBack()
+		return nil
+	}
+	addrs := make([]gethcmn.Address, 0, len(alloc))
+	for addr := range alloc {
+		addrs = append(addrs, addr)
+	}
+	// creation counters are increased in a deterministic order
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	for _, addr := range addrs {
+		acc := alloc[addr]
+		if acc.Balance == nil || acc.Balance.Sign() < 0 {
+			return fmt.Errorf("invalid balance of genesis account %s", addr.Hex())
+		}
+		amt, overflow := uint256.FromBig(acc.Balance)
+		if overflow {
+			return fmt.Errorf("balance of genesis account %s overflows 256 bits", addr.Hex())
+		}
+		if len(acc.Code) == 0 && len(acc.Storage) != 0 {
+			return fmt.Errorf("genesis account %s has storage but no code", addr.Hex())
+		}
+		if len(acc.Code) != 0 && ebp.PredefinedSystemContractExecutor.IsSystemContract(addr) {
+			return fmt.Errorf("genesis account %s is a system contract, its code and storage can not be set", addr.Hex())
+		}
 
-	app.logger.Info("air drop", "accounts", len(alloc))
-	for addr, acc := range alloc {
-		amt, _ := uint256.FromBig(acc.Balance)
 		k := types.GetAccountKey(addr)
 		v := types.ZeroAccountInfo()
+		if bz := rbt.Get(k); bz != nil {
+			v = types.NewAccountInfo(append([]byte{}, bz...))
+		} else {
+			v.UpdateSequence(math.MaxUint64) // an account without code
+		}
 		v.UpdateBalance(amt)
+		v.UpdateNonce(acc.Nonce)
+		if len(acc.Code) != 0 {
+			if v.Sequence() != math.MaxUint64 {
+				return fmt.Errorf("genesis account %s already has code", addr.Hex())
+			}
+			v.UpdateSequence(nextContractSequence(rbt, addr))
+			codeHash := crypto.Keccak256(acc.Code)
+			rbt.Set(types.GetBytecodeKey(addr), append(codeHash, acc.Code...))
+			for key, value := range acc.Storage {
+				if value != (gethcmn.Hash{}) {
+					rbt.Set(types.GetValueKey(v.Sequence(), string(key[:])), append([]byte{}, value[:]...))
+				}
+			}
+		}
 		rbt.Set(k, v.Bytes())
 		//app.logger.Info("Air drop " + amt.String() + " to " + addr.Hex())
 	}
 
 	rbt.Close()
 	rbt.WriteBack()
+	return nil
+}
+
+// The genesis accounts of the existing chains are created in this way, which must not be changed
+func createLegacyGenesisAccs(rbt rabbit.RabbitStore, alloc gethcore.GenesisAlloc) {
+	for addr, acc := range alloc {
+		amt, _ := uint256.FromBig(acc.Balance)
+		k := types.GetAccountKey(addr)
+		v := types.ZeroAccountInfo()
+		v.UpdateBalance(amt)
+		rbt.Set(k, v.Bytes())
+	}
+}
+
+// Increase the creation counter of the address's first byte and return the new sequence, in the same
+// way as the EVM deploys a contract
+func nextContractSequence(rbt rabbit.RabbitStore, addr gethcmn.Address) uint64 {
+	k := types.GetCreationCounterKey(addr[0])
+	counter := uint64(0)
+	if bz := rbt.Get(k); bz != nil {
+		counter = binary.BigEndian.Uint64(bz)
+	}
+	counter++
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], counter)
+	rbt.Set(k, buf[:])
+	return (counter << 8) | uint64(addr[0])
 }
 
 func (app *App) BeginBlock(req abcitypes.RequestBeginBlock) abcitypes.ResponseBeginBlock {
 	//fmt.Printf("BeginBlock!!!!!!!!!!!!!!!\n")
 	//app.randomPanic(5000, 7919)
 	app.logger.Debug("enter begin block!")
+	app.checkUpgradeHandler(req.Header.Height)
 	app.block = &types.Block{
 		Number:    req.Header.Height,
 		Timestamp: req.Header.Time.Unix(),
@@ -447,6 +693,8 @@ func (app *App) BeginBlock(req abcitypes.RequestBeginBlock) abcitypes.ResponseBe
 	for _, v := range req.LastCommitInfo.GetVotes() {
 		if v.SignedLastBlock {
 			app.lastCommitInfo = append(app.lastCommitInfo, v.Validator.Address) //this is validator consensus address
+		} else {
+			app.lastAbsentees = append(app.lastAbsentees, v.Validator.Address)
 		}
 	}
 	copy(app.block.ParentHash[:], req.Header.LastBlockId.Hash)
@@ -459,7 +707,7 @@ func (app *App) BeginBlock(req abcitypes.RequestBeginBlock) abcitypes.ResponseBe
 	copy(app.block.Hash[:], req.Hash) // Just use tendermint's block hash
 	copy(app.block.StateRoot[:], req.Header.AppHash)
 	//TODO: slash req.ByzantineValidators
-	app.currHeight = req.Header.Height
+	atomic.StoreInt64(&app.currHeight, req.Header.Height)
 	// collect slash info, only double sign
 	var addr [20]byte
 	for _, val := range req.ByzantineValidators {
@@ -478,6 +726,11 @@ func (app *App) DeliverTx(req abcitypes.RequestDeliverTx) abcitypes.ResponseDeli
 	app.block.Size += int64(req.Size())
 	tx, err := ethutils.DecodeTx(req.Tx)
 	if err == nil {
+		// a tx with invalid signature is still collected, and txEngine will mark it as failed
+		sender, err := app.GetSender(tx)
+		if err == nil {
+			app.pendingTxs.markInBlock(sender, tx, app.currHeight)
+		}
 		app.txEngine.CollectTx(tx)
 	}
 	app.logger.Debug("leave deliver tx!")
@@ -496,18 +749,33 @@ func (app *App) EndBlock(req abcitypes.RequestEndBlock) abcitypes.ResponseEndBlo
 		}
 		fmt.Printf("endblock validator:%s\n", gethcmn.Address(v.Address).String())
 	}
-	app.logger.Debug("leave end block!")
-	return abcitypes.ResponseEndBlock{
+	for _, v := range app.jailedValidators {
+		p, _ := cryptoenc.PubKeyToProto(ed25519.PubKey(v.Pubkey[:]))
+		valSet = append(valSet, abcitypes.ValidatorUpdate{PubKey: p, Power: 0})
+		fmt.Printf("endblock jailed validator:%s\n", gethcmn.Address(v.Address).String())
+	}
+	app.jailedValidators = nil
+	res := abcitypes.ResponseEndBlock{
 		ValidatorUpdates: valSet,
 	}
+	if app.config.UpgradeAt(app.currHeight) != nil {
+		// tendermint takes the new app version from the next block
+		res.ConsensusParamUpdates = &abcitypes.ConsensusParams{
+			Version: &tmproto.VersionParams{AppVersion: app.config.AppVersionAt(app.currHeight)},
+		}
+	}
+	app.logger.Debug("leave end block!")
+	return res
 }
 
 func (app *App) Commit() abcitypes.ResponseCommit {
 	//fmt.Printf("Commit!!!!!!!!!!!!!!!\n")
 	app.logger.Debug("enter commit!", "txs", app.txEngine.CollectTxsCount())
 	app.mtx.Lock()
+	app.sealArchive()
 
 	ctx := app.GetRunTxContext()
+	app.runUpgradeHandler(ctx)
 	_, info := staking.LoadStakingAcc(ctx)
 
 	//update validator set first, maybe it should after slash
@@ -538,13 +806,31 @@ func (app *App) Commit() abcitypes.ResponseCommit {
 		staking.Slash(ctx, pubkeyMapByConsAddr[v], staking.SlashedStakingAmount)
 	}
 	app.slashValidators = nil
-	//distribute previous block gas gee
+	//jail the validators who missed too many blocks
 	voters := make([][32]byte, len(app.lastCommitInfo))
 	var tmpAddr [20]byte
 	for i, c := range app.lastCommitInfo {
 		copy(tmpAddr[:], c)
 		voters[i] = pubkeyMapByConsAddr[tmpAddr]
 	}
+	absentees := make([][32]byte, len(app.lastAbsentees))
+	for i, c := range app.lastAbsentees {
+		copy(tmpAddr[:], c)
+		absentees[i] = pubkeyMapByConsAddr[tmpAddr]
+	}
+	livenessParams := staking.LivenessParams{
+		WindowSize:      app.config.LivenessWindowSize,
+		MaxMissedBlocks: app.config.LivenessMaxMissedBlocks,
+		SlashedAmount:   app.config.DowntimeSlashedAmount,
+		MinJailBlocks:   app.config.LivenessMinJailBlocks,
+	}
+	// the votes in lastCommitInfo are for the previous block
+	if jailed := staking.HandleLiveness(ctx, livenessParams, app.currHeight-1, voters, absentees); len(jailed) != 0 {
+		app.jailedValidators = jailed
+		_, info = staking.LoadStakingAcc(ctx)
+		app.currValidators = info.GetActiveValidators(staking.MinimumStakingAmount)
+	}
+	//distribute previous block gas gee
 	var blockReward = app.lastGasFee
 	if !app.lastGasFee.IsZero() {
 		if !app.lastGasRefund.IsZero() {
@@ -582,8 +868,12 @@ func (app *App) Commit() abcitypes.ResponseCommit {
 
 	ctx.Close(true)
 
-	app.touchedAddrs = app.txEngine.Prepare(app.reorderSeed, app.lastMinGasPrice)
+	app.allTxsExecuted = app.txEngine.StandbyQLen() == 0
+	app.txEngine.Prepare(app.reorderSeed, app.lastMinGasPrice)
 	app.refresh()
+	if app.snapshots.shouldTake(app.currHeight) {
+		app.takeSnapshot()
+	}
 	bi := app.syncBlockInfo()
 	go app.postCommit(bi)
 	app.logger.Debug("leave commit!")
@@ -594,6 +884,9 @@ func (app *App) Commit() abcitypes.ResponseCommit {
 	if app.retainBlocks > 0 && app.currHeight >= app.retainBlocks && (app.currHeight%100 == 0) {
 		res.RetainHeight = app.currHeight - app.retainBlocks + 1
 	}
+	if app.config.HaltHeight > 0 && app.currHeight >= app.config.HaltHeight {
+		app.halt()
+	}
 	return res
 }
 
@@ -609,6 +902,14 @@ func (app *App) syncBlockInfo() *types.BlockInfo {
 	return bi
 }
 
+// The height of the latest committed block, which can be read without holding app.mtx
+func (app *App) committedHeight() int64 {
+	if bi, ok := app.blockInfo.Load().(*types.BlockInfo); ok {
+		return bi.Number
+	}
+	return 0
+}
+
 func (app *App) postCommit(bi *types.BlockInfo) {
 	app.logger.Debug("enter post commit!")
 	defer app.mtx.Unlock()
@@ -633,7 +934,7 @@ func (app *App) refresh() {
 	staking.SaveMinGasPrice(ctx, mGP, true)
 	app.lastMinGasPrice = mGP
 	ctx.Close(true)
-	app.trunk.Close(true)
+	app.writeBackTrunk()
 	if prevBlkInfo != nil && prevBlkInfo.Number%PruneEveryN == 0 && prevBlkInfo.Number > app.numKeptBlocks {
 		app.mads.PruneBeforeHeight(prevBlkInfo.Number - app.numKeptBlocks)
 	}
@@ -655,6 +956,8 @@ func (app *App) refresh() {
 		for i, tx := range app.txEngine.CommittedTxs() {
 			prevBlkInfo.Transactions[i] = tx.Hash
 		}
+		receiptsRoot, bloom := ethutils.ReceiptsRootAndBloom(ethutils.ToGethReceipts(app.txEngine.CommittedTxs()))
+		prevBlkInfo.LogsBloom = bloom
 		blkInfo, err := prevBlkInfo.MarshalMsg(nil)
 		if err != nil {
 			panic(err)
@@ -663,42 +966,76 @@ func (app *App) refresh() {
 		blk.BlockInfo = blkInfo
 		blk.TxList = make([]modbtypes.Tx, len(app.txEngine.CommittedTxs()))
 		for i, tx := range app.txEngine.CommittedTxs() {
-			t := modbtypes.Tx{}
-			copy(t.HashId[:], tx.Hash[:])
-			copy(t.SrcAddr[:], tx.From[:])
-			copy(t.DstAddr[:], tx.To[:])
 			txContent, err := tx.MarshalMsg(nil)
 			if err != nil {
 				panic(err)
 			}
-			t.Content = txContent
-			t.LogList = make([]modbtypes.Log, len(tx.Logs))
-			for j, l := range tx.Logs {
-				copy(t.LogList[j].Address[:], l.Address[:])
-				if len(l.Topics) != 0 {
-					t.LogList[j].Topics = make([][32]byte, len(l.Topics))
-				}
-				for k, topic := range l.Topics {
-					copy(t.LogList[j].Topics[k][:], topic[:])
-				}
-			}
-			blk.TxList[i] = t
+			blk.TxList[i] = toMoDBTx(tx, txContent)
 		}
 		app.historyStore.AddBlock(&blk, -1)
-		app.publishNewBlock(&blk)
+		app.bloomBits.add(prevBlkInfo.Number, bloom)
+		app.bloomBits.setReceiptsRoot(prevBlkInfo.Number, receiptsRoot)
+		app.publishNewBlock(&blk, app.txEngine.CommittedTxs())
 		wg.Wait() // wait for getSep206SenderSet to finish its job
 	}
 	//make new
 	app.recheckCounter = 0 // reset counter before counting the remained TXs which need rechecking
 	app.lastProposer = app.block.Miner
 	app.lastCommitInfo = app.lastCommitInfo[:0]
+	app.lastAbsentees = app.lastAbsentees[:0]
 	app.root.SetHeight(app.currHeight + 1)
 	app.trunk = app.root.GetTrunkStore().(*store.TrunkStore)
 	app.checkTrunk = app.root.GetReadOnlyTrunkStore().(*store.TrunkStore)
 	app.txEngine.SetContext(app.GetRunTxContext())
+	app.prunePendingTxs()
+	app.jamGasPrice = app.pendingTxs.jamGasPrice(app.recheckThreshold)
+}
+
+func (app *App) prunePendingTxs() {
+	ctx := app.GetCheckTxContext()
+	defer ctx.Close(false)
+	app.pendingTxs.prune(app.currHeight, app.allTxsExecuted, func(addr gethcmn.Address) uint64 {
+		if acc := ctx.GetAccount(addr); acc != nil {
+			return acc.Nonce()
+		}
+		return 0
+	})
 }
 
-func (app *App) publishNewBlock(mdbBlock *modbtypes.Block) {
+// Build the MoDB entry of a transaction, whose serialized payload is txContent
+func toMoDBTx(tx *types.Transaction, txContent []byte) modbtypes.Tx {
+	t := modbtypes.Tx{}
+	copy(t.HashId[:], tx.Hash[:])
+	copy(t.SrcAddr[:], tx.From[:])
+	copy(t.DstAddr[:], tx.To[:])
+	t.Content = txContent
+	t.LogList = make([]modbtypes.Log, len(tx.Logs))
+	for j, l := range tx.Logs {
+		copy(t.LogList[j].Address[:], l.Address[:])
+		if len(l.Topics) != 0 {
+			t.LogList[j].Topics = make([][32]byte, len(l.Topics))
+		}
+		for k, topic := range l.Topics {
+			copy(t.LogList[j].Topics[k][:], topic[:])
+		}
+	}
+	return t
+}
+
+// Send the txs accepted by CheckTx to txFeed, one by one, until the app stops
+func (app *App) publishNewTxs() {
+	for {
+		select {
+		case tx := <-app.newTxs:
+			app.txFeed.Send(gethcore.NewTxsEvent{Txs: []*gethtypes.Transaction{tx}})
+		case <-app.quit:
+			return
+		}
+	}
+}
+
+// Publish a block added to the history store, whose executed transactions are 'txs'
+func (app *App) publishNewBlock(mdbBlock *modbtypes.Block, txs []*types.Transaction) {
 	if mdbBlock == nil {
 		return
 	}
@@ -709,7 +1046,7 @@ func (app *App) publishNewBlock(mdbBlock *modbtypes.Block) {
 			BlockHash: mdbBlock.BlockHash,
 		},
 		Block: mdbBlock,
-		Logs:  collectAllGethLogs(mdbBlock),
+		Logs:  collectAllGethLogs(mdbBlock, txs),
 	}
 	app.chainFeed.Send(chainEvent)
 	if len(chainEvent.Logs) > 0 {
@@ -717,38 +1054,62 @@ func (app *App) publishNewBlock(mdbBlock *modbtypes.Block) {
 	}
 }
 
-func collectAllGethLogs(mdbBlock *modbtypes.Block) []*gethtypes.Log {
+// Collect the logs in a block, with their metadata filled. MoDB's log records only contain addresses and topics,
+// so the logs are taken from the executed transactions, which have the data.
+func collectAllGethLogs(mdbBlock *modbtypes.Block, txs []*types.Transaction) []*gethtypes.Log {
 	logs := make([]*gethtypes.Log, 0, 8)
-	for _, mdbTx := range mdbBlock.TxList {
-		for _, mdbLog := range mdbTx.LogList {
+	for i, tx := range txs {
+		for _, log := range tx.Logs {
 			logs = append(logs, &gethtypes.Log{
-				Address: mdbLog.Address,
-				Topics:  types.ToGethHashes(mdbLog.Topics),
+				Address:     log.Address,
+				Topics:      types.ToGethHashes(log.Topics),
+				Data:        log.Data,
+				BlockNumber: uint64(mdbBlock.Height),
+				TxHash:      tx.Hash,
+				TxIndex:     uint(i),
+				BlockHash:   mdbBlock.BlockHash,
+				Index:       uint(len(logs)), // the index in the block
 			})
 		}
 	}
 	return logs
 }
 
-func (app *App) ListSnapshots(snapshots abcitypes.RequestListSnapshots) abcitypes.ResponseListSnapshots {
-	return abcitypes.ResponseListSnapshots{}
+func (app *App) ListSnapshots(req abcitypes.RequestListSnapshots) abcitypes.ResponseListSnapshots {
+	snapshots, err := app.snapshots.list()
+	if err != nil {
+		app.logger.Error("failed to list snapshots", "err", err.Error())
+	}
+	return abcitypes.ResponseListSnapshots{Snapshots: snapshots}
 }
 
-func (app *App) OfferSnapshot(snapshot abcitypes.RequestOfferSnapshot) abcitypes.ResponseOfferSnapshot {
-	return abcitypes.ResponseOfferSnapshot{}
+func (app *App) OfferSnapshot(req abcitypes.RequestOfferSnapshot) abcitypes.ResponseOfferSnapshot {
+	return abcitypes.ResponseOfferSnapshot{Result: app.offerSnapshot(req.Snapshot, req.AppHash)}
 }
 
-func (app *App) LoadSnapshotChunk(chunk abcitypes.RequestLoadSnapshotChunk) abcitypes.ResponseLoadSnapshotChunk {
-	return abcitypes.ResponseLoadSnapshotChunk{}
+func (app *App) LoadSnapshotChunk(req abcitypes.RequestLoadSnapshotChunk) abcitypes.ResponseLoadSnapshotChunk {
+	chunk, err := app.snapshots.loadChunk(req.Height, req.Format, req.Chunk)
+	if err != nil {
+		app.logger.Error("failed to load snapshot chunk", "height", req.Height, "chunk", req.Chunk, "err", err.Error())
+	}
+	return abcitypes.ResponseLoadSnapshotChunk{Chunk: chunk}
 }
 
-func (app *App) ApplySnapshotChunk(chunk abcitypes.RequestApplySnapshotChunk) abcitypes.ResponseApplySnapshotChunk {
-	return abcitypes.ResponseApplySnapshotChunk{}
+func (app *App) ApplySnapshotChunk(req abcitypes.RequestApplySnapshotChunk) abcitypes.ResponseApplySnapshotChunk {
+	return app.applySnapshotChunk(req)
 }
 
+// Stop closes the stores, it must be called after tendermint stops sending blocks
 func (app *App) Stop() {
+	app.WaitLock() // postCommit of the last block
+	close(app.quit)
+	app.snapshots.wait()
 	app.historyStore.Close()
+	app.bloomBits.close()
 	app.root.Close()
+	if app.archive != nil {
+		app.archive.close()
+	}
 	app.scope.Close()
 }
 
@@ -766,12 +1127,12 @@ func (app *App) GetCheckTxContext() *types.Context {
 }
 
 func (app *App) GetContext(mode ContextMode) *types.Context {
-	c := types.NewContext(uint64(app.currHeight), nil, nil)
+	c := types.NewContext(uint64(atomic.LoadInt64(&app.currHeight)), nil, nil)
 	if mode == CheckTxMode {
 		r := rabbit.NewRabbitStore(app.checkTrunk)
 		c = c.WithRbt(&r)
 	} else if mode == RunTxMode {
-		r := rabbit.NewRabbitStore(app.trunk)
+		r := rabbit.NewRabbitStore(app.trunkStore())
 		c = c.WithRbt(&r)
 		c = c.WithDb(app.historyStore)
 	} else if mode == RpcMode {
@@ -788,17 +1149,48 @@ func (app *App) GetContext(mode ContextMode) *types.Context {
 }
 
 func (app *App) RunTxForRpc(gethTx *gethtypes.Transaction, sender gethcmn.Address, estimateGas bool) (*ebp.TxRunner, int64) {
-	txToRun := &types.TxToRun{}
-	txToRun.FromGethTx(gethTx, sender, uint64(app.currHeight))
-	ctx := app.GetRpcContext()
+	runner, estimateResult, _ := app.RunTxForRpcAtHeight(gethTx, sender, estimateGas, -1)
+	return runner, estimateResult
+}
+
+// Run the tx on the state at 'height' with the info of that block, a negative height means the latest state
+func (app *App) RunTxForRpcAtHeight(gethTx *gethtypes.Transaction, sender gethcmn.Address,
+	estimateGas bool, height int64) (*ebp.TxRunner, int64, error) {
+
+	ctx, err := app.GetRpcContextAtHeight(height)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer ctx.Close(false)
+	bi := app.blockInfo.Load().(*types.BlockInfo)
+	if height >= 0 {
+		if bi, err = app.getBlockInfo(ctx, height); err != nil {
+			return nil, 0, err
+		}
+	}
+	txToRun := &types.TxToRun{}
+	txToRun.FromGethTx(gethTx, sender, uint64(atomic.LoadInt64(&app.currHeight)))
 	runner := &ebp.TxRunner{
 		Ctx: ctx,
 		Tx:  txToRun,
 	}
-	bi := app.blockInfo.Load().(*types.BlockInfo)
 	estimateResult := ebp.RunTxForRpc(bi, estimateGas, runner)
-	return runner, estimateResult
+	return runner, estimateResult, nil
+}
+
+// Get the info of the block at 'height' from history, which is used to run txs on the state of that block
+func (app *App) getBlockInfo(ctx *types.Context, height int64) (*types.BlockInfo, error) {
+	blk, err := ctx.GetBlockByHeight(uint64(height))
+	if err != nil {
+		return nil, err
+	}
+	return &types.BlockInfo{
+		Coinbase:  blk.Miner,
+		Number:    blk.Number,
+		Timestamp: blk.Timestamp,
+		ChainId:   app.chainId.Bytes32(),
+		Hash:      blk.Hash,
+	}, nil
 }
 
 // SubscribeChainEvent registers a subscription of ChainEvent.
@@ -806,13 +1198,18 @@ func (app *App) SubscribeChainEvent(ch chan<- types.ChainEvent) event.Subscripti
 	return app.scope.Track(app.chainFeed.Subscribe(ch))
 }
 
+// SubscribeNewTxsEvent registers a subscription of NewTxsEvent, which is sent when a new tx enters mempool.
+func (app *App) SubscribeNewTxsEvent(ch chan<- gethcore.NewTxsEvent) event.Subscription {
+	return app.scope.Track(app.txFeed.Subscribe(ch))
+}
+
 // SubscribeLogsEvent registers a subscription of []*types.Log.
 func (app *App) SubscribeLogsEvent(ch chan<- []*gethtypes.Log) event.Subscription {
 	return app.scope.Track(app.logsFeed.Subscribe(ch))
 }
 
 func (app *App) GetLatestBlockNum() int64 {
-	return app.currHeight
+	return atomic.LoadInt64(&app.currHeight)
 }
 
 func (app *App) ChainID() *uint256.Int {
@@ -853,7 +1250,15 @@ func (app *App) EpochChan() chan *stakingtypes.Epoch {
 func (app *App) AddBlockFotTest(mdbBlock *modbtypes.Block) {
 	app.historyStore.AddBlock(mdbBlock, -1)
 	app.historyStore.AddBlock(nil, -1) // To Flush
-	app.publishNewBlock(mdbBlock)
+	app.bloomBits.add(mdbBlock.Height, logsBloomFromBlockInfo(mdbBlock.BlockInfo))
+	txs := make([]*types.Transaction, len(mdbBlock.TxList))
+	for i, mdbTx := range mdbBlock.TxList {
+		txs[i] = &types.Transaction{}
+		if _, err := txs[i].UnmarshalMsg(mdbTx.Content); err != nil {
+			panic(err)
+		}
+	}
+	app.publishNewBlock(mdbBlock, txs)
 }
 
 //nolint
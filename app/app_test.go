@@ -13,15 +13,18 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/ethereum/go-ethereum/common"
+	gethcore "github.com/ethereum/go-ethereum/core"
 	gethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/holiman/uint256"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/crypto/ed25519"
+	mempl "github.com/tendermint/tendermint/mempool"
 
 	"github.com/smartbch/smartbch/app"
 	"github.com/smartbch/smartbch/internal/ethutils"
 	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/param"
 )
 
 //func TestMain(m *testing.M) {
@@ -129,6 +132,11 @@ func TestCheckTx(t *testing.T) {
 	tx = testutils.MustSignTx(tx, _app.ChainID().ToBig(), key1)
 	require.Equal(t, app.CannotPayGasFee, _app.CheckNewTxABCI(tx))
 
+	//value not pay
+	tx = ethutils.NewTx(0, &addr1, big.NewInt(9000001), 100000, big.NewInt(10), nil)
+	tx = testutils.MustSignTx(tx, _app.ChainID().ToBig(), key1)
+	require.Equal(t, app.CannotPayGasFee, _app.CheckNewTxABCI(tx))
+
 	//ok
 	tx = ethutils.NewTx(0, &addr1, big.NewInt(100), 100000, big.NewInt(10), nil)
 	tx = testutils.MustSignTx(tx, _app.ChainID().ToBig(), key1)
@@ -147,7 +155,118 @@ func TestCheckTxNonce_serial(t *testing.T) {
 	require.Equal(t, uint64(0), tx2.Nonce())
 
 	require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx1))
-	require.Equal(t, app.AccountNonceMismatch, _app.CheckNewTxABCI(tx2))
+	require.Equal(t, app.HasPendingTx, _app.CheckNewTxABCI(tx2))
+}
+
+func TestCheckTx_multiplePending(t *testing.T) {
+	key1, addr1 := testutils.GenKeyAndAddr()
+	_, addr2 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key1)
+	defer _app.Destroy()
+
+	signTx := func(nonce uint64, gas uint64, gasPrice int64) *gethtypes.Transaction {
+		tx := ethutils.NewTx(nonce, &addr2, big.NewInt(1), gas, big.NewInt(gasPrice), nil)
+		return testutils.MustSignTx(tx, _app.ChainID().ToBig(), key1)
+	}
+
+	// sequential nonces
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(nonce, 100000, 1)))
+	}
+	require.Equal(t, app.HasPendingTx, _app.CheckNewTxABCI(signTx(2, 200000, 1)))
+	// nonce gap
+	require.Equal(t, app.AccountNonceMismatch, _app.CheckNewTxABCI(signTx(4, 100000, 1)))
+	// the balance must afford the gas fees of all pending txs
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(3, 5000000, 1)))
+	require.Equal(t, app.CannotPayGasFee, _app.CheckNewTxABCI(signTx(4, 5000000, 1)))
+	// too many pending txs
+	for nonce := uint64(4); nonce < param.DefaultMaxPendingTxsPerSender; nonce++ {
+		require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(nonce, 10000, 1)))
+	}
+	require.Equal(t, app.TooManyPendingTxs, _app.CheckNewTxABCI(signTx(param.DefaultMaxPendingTxsPerSender, 10000, 1)))
+	require.Equal(t, uint64(0), _app.GetNonce(addr1))
+}
+
+func TestCheckTx_nonceGap(t *testing.T) {
+	key1, _ := testutils.GenKeyAndAddr()
+	_, addr2 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppWithNonceGap(2, key1)
+	defer _app.Destroy()
+
+	signTx := func(nonce uint64) *gethtypes.Transaction {
+		tx := ethutils.NewTx(nonce, &addr2, big.NewInt(1), 100000, big.NewInt(1), nil)
+		return testutils.MustSignTx(tx, _app.ChainID().ToBig(), key1)
+	}
+	require.Equal(t, app.AccountNonceMismatch, _app.CheckNewTxABCI(signTx(3)))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(2)))
+	// the gap is counted from the next nonce, which does not skip over the gap
+	require.Equal(t, app.AccountNonceMismatch, _app.CheckNewTxABCI(signTx(3)))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(0)))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(3)))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(1)))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(6)))
+}
+
+func TestCheckTx_recheckPending(t *testing.T) {
+	key1, _ := testutils.GenKeyAndAddr()
+	_, addr2 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key1)
+	defer _app.Destroy()
+
+	txs := make([]*gethtypes.Transaction, 4)
+	for i := range txs {
+		txs[i] = ethutils.NewTx(uint64(i), &addr2, big.NewInt(1), 100000, big.NewInt(1), nil)
+		txs[i] = testutils.MustSignTx(txs[i], _app.ChainID().ToBig(), key1)
+	}
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(txs[0]))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(txs[1]))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(txs[2]))
+
+	// only the first tx gets into a block, the others are still pending
+	_app.AddTxsInBlock(_app.BlockNum()+1, txs[0])
+	recheck := func(tx *gethtypes.Transaction) uint32 {
+		return _app.CheckTx(abci.RequestCheckTx{Tx: testutils.MustEncodeTx(tx), Type: abci.CheckTxType_Recheck}).Code
+	}
+	require.Equal(t, uint32(0), recheck(txs[1]))
+	require.Equal(t, uint32(0), recheck(txs[2]))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(txs[3]))
+
+	// the txs in one block are executed in block order, so a tx before its predecessor fails
+	h := _app.ExecTxsInBlock(txs[1], txs[3], txs[2])
+	_app.WaitMS(100)
+	require.Len(t, _app.GetBlock(h).Transactions, 2)
+	_app.EnsureTxSuccess(txs[0].Hash())
+	_app.EnsureTxSuccess(txs[1].Hash())
+	_app.EnsureTxSuccess(txs[2].Hash())
+	_app.EnsureTxFailed(txs[3].Hash(), "incorrect nonce")
+	require.Equal(t, app.AccountNonceMismatch, _app.CheckNewTxABCI(txs[2]))
+}
+
+func TestCheckTx_recheckGap(t *testing.T) {
+	key1, _ := testutils.GenKeyAndAddr()
+	key2, _ := testutils.GenKeyAndAddr()
+	key3, _ := testutils.GenKeyAndAddr()
+	_, addr4 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppWithRecheckThreshold(2, key1, key2, key3)
+	defer _app.Destroy()
+
+	signTx := func(key string, nonce uint64, gasPrice int64) *gethtypes.Transaction {
+		tx := ethutils.NewTx(nonce, &addr4, big.NewInt(1), 100000, big.NewInt(gasPrice), nil)
+		return testutils.MustSignTx(tx, _app.ChainID().ToBig(), key)
+	}
+	tx0, tx1 := signTx(key1, 0, 1), signTx(key1, 1, 5)
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx0))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx1))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(key2, 0, 2)))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(key3, 0, 3)))
+
+	// tx0 is evicted, so tx1 can not stay in the mempool with a nonce gap
+	_app.AddTxsInBlock(_app.BlockNum() + 1)
+	recheck := func(tx *gethtypes.Transaction) uint32 {
+		return _app.CheckTx(abci.RequestCheckTx{Tx: testutils.MustEncodeTx(tx), Type: abci.CheckTxType_Recheck}).Code
+	}
+	require.Equal(t, app.MempoolBusy, recheck(tx0))
+	require.Equal(t, app.AccountNonceMismatch, recheck(tx1))
 }
 
 func TestCheckTx_hasPending(t *testing.T) {
@@ -162,6 +281,115 @@ func TestCheckTx_hasPending(t *testing.T) {
 	require.Equal(t, app.HasPendingTx, _app.CheckNewTxABCI(tx2))
 }
 
+func TestCheckTx_publishNewTxs(t *testing.T) {
+	key1, _ := testutils.GenKeyAndAddr()
+	_, addr2 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key1)
+	defer _app.Destroy()
+
+	ch := make(chan gethcore.NewTxsEvent)
+	sub := _app.SubscribeNewTxsEvent(ch)
+	defer sub.Unsubscribe()
+
+	var txs []*gethtypes.Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx := ethutils.NewTx(nonce, &addr2, big.NewInt(1), 100000, big.NewInt(10), nil)
+		tx = testutils.MustSignTx(tx, _app.ChainID().ToBig(), key1)
+		require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx))
+		txs = append(txs, tx)
+	}
+	// the txs are published in the order they are accepted
+	for _, tx := range txs {
+		select {
+		case ev := <-ch:
+			require.Len(t, ev.Txs, 1)
+			require.Equal(t, tx.Hash(), ev.Txs[0].Hash())
+		case <-time.After(time.Second):
+			t.Fatal("tx not published")
+		}
+	}
+}
+
+func TestCheckTx_replacement(t *testing.T) {
+	key1, _ := testutils.GenKeyAndAddr()
+	_, addr2 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key1)
+	defer _app.Destroy()
+
+	signTx := func(nonce uint64, gas uint64, gasPrice int64) *gethtypes.Transaction {
+		tx := ethutils.NewTx(nonce, &addr2, big.NewInt(1), gas, big.NewInt(gasPrice), nil)
+		return testutils.MustSignTx(tx, _app.ChainID().ToBig(), key1)
+	}
+
+	mempool := &fakeMempool{}
+	_app.SetMempool(mempool)
+
+	tx1 := signTx(0, 400000, 10)
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx1))
+	require.Equal(t, app.HasPendingTx, _app.CheckNewTxABCI(signTx(0, 300000, 10)))
+	// the gas price must be bumped by 10%
+	require.Equal(t, app.HasPendingTx, _app.CheckNewTxABCI(signTx(0, 300000, 10+param.DefaultReplaceTxPriceBump/10-1)))
+	tx2 := signTx(0, 300000, 10+param.DefaultReplaceTxPriceBump/10)
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx2))
+	// the replaced tx is removed from mempool at once
+	require.Equal(t, [][32]byte{mempl.TxKey(testutils.MustEncodeTx(tx1))}, mempool.removed)
+	// the gas fee of the replaced tx is not reserved any more
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(1, 600000, 10)))
+
+	// the replaced tx is evicted during rechecking
+	_app.AddTxsInBlock(_app.BlockNum() + 1)
+	recheck := func(tx *gethtypes.Transaction) uint32 {
+		return _app.CheckTx(abci.RequestCheckTx{Tx: testutils.MustEncodeTx(tx), Type: abci.CheckTxType_Recheck}).Code
+	}
+	require.Equal(t, app.HasPendingTx, recheck(tx1))
+	require.Equal(t, uint32(0), recheck(tx2))
+
+	// if both of them get into a block, the first one wins
+	_app.ExecTxsInBlock(tx2, tx1)
+	_app.WaitMS(100)
+	_app.EnsureTxSuccess(tx2.Hash())
+	_app.EnsureTxFailed(tx1.Hash(), "incorrect nonce")
+}
+
+type fakeMempool struct {
+	removed [][32]byte
+}
+
+func (m *fakeMempool) RemoveTxByKey(txKey [32]byte, removeFromCache bool) {
+	m.removed = append(m.removed, txKey)
+}
+
+func TestCheckTx_mempoolBusy(t *testing.T) {
+	key1, _ := testutils.GenKeyAndAddr()
+	key2, _ := testutils.GenKeyAndAddr()
+	key3, _ := testutils.GenKeyAndAddr()
+	key4, addr4 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppWithRecheckThreshold(2, key1, key2, key3, key4)
+	defer _app.Destroy()
+
+	signTx := func(key string, gasPrice int64) *gethtypes.Transaction {
+		tx := ethutils.NewTx(0, &addr4, big.NewInt(1), 100000, big.NewInt(gasPrice), nil)
+		return testutils.MustSignTx(tx, _app.ChainID().ToBig(), key)
+	}
+	tx1, tx2, tx3 := signTx(key1, 1), signTx(key2, 2), signTx(key3, 3)
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx1))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx2))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(tx3))
+
+	// all of them remain in the mempool after a block, which is more than the threshold
+	_app.AddTxsInBlock(_app.BlockNum() + 1)
+	recheck := func(tx *gethtypes.Transaction) uint32 {
+		return _app.CheckTx(abci.RequestCheckTx{Tx: testutils.MustEncodeTx(tx), Type: abci.CheckTxType_Recheck}).Code
+	}
+	require.Equal(t, app.MempoolBusy, recheck(tx1))
+	require.Equal(t, uint32(0), recheck(tx2))
+	require.Equal(t, uint32(0), recheck(tx3))
+
+	// only the txs paying more than the remained ones can get in
+	require.Equal(t, app.MempoolBusy, _app.CheckNewTxABCI(signTx(key4, 2)))
+	require.Equal(t, uint32(0), _app.CheckNewTxABCI(signTx(key4, 3)))
+}
+
 func TestIncorrectNonceErr(t *testing.T) {
 	key1, addr1 := testutils.GenKeyAndAddr()
 	_, addr2 := testutils.GenKeyAndAddr()
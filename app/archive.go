@@ -0,0 +1,412 @@
+package app
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/smartbch/moeingads/store"
+	"github.com/smartbch/moeingads/store/rabbit"
+	adstypes "github.com/smartbch/moeingads/store/types"
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/param"
+)
+
+// In archive mode, the states of past blocks are kept for RPC. MoeingADS only provides the latest state,
+// so every write from the rabbit stores to app.trunk is also recorded in archiveStore, with the height
+// of the block whose state it belongs to. The trunk written back in Commit(H) first gets the results of
+// executing block H-1 (by postCommit), and then the writes of Commit(H) itself, such as distributing
+// the fees and deducting the gas fees of block H's txs in Prepare. The former are sealed when Commit(H)
+// starts and recorded with height H-1, the latter are recorded with height H. So the state at height
+// H-1 is the one right after executing block H-1, before anything of block H is applied.
+//
+// The keys written by the rabbit stores are fixed-size short keys, and a record's key is the prefix,
+// the short key and the height, such that the latest record at or below a height can be found by seeking.
+// When a short key is recorded for the first time, the value before the write is also recorded with
+// the start height, so a short key without any record keeps the value it has in the root store.
+// The height of the latest record of the recently written short keys is cached, to avoid seeking.
+
+const (
+	archiveRecordPrefix = byte('r')
+	archiveFlagDeleted  = byte(0)
+	archiveFlagExisting = byte(1)
+
+	archiveCacheSize = 1 << 20 // the count of short keys whose latest record heights are cached
+)
+
+var (
+	ErrHistoricalStateNotAvailable = errors.New("historical state is not available")
+
+	archiveStartKey  = []byte("start")  // the first height whose state is archived
+	archiveLatestKey = []byte("latest") // the latest height whose state is archived
+)
+
+type archiveStore struct {
+	db dbm.DB
+
+	mtx          sync.RWMutex // protects startHeight and latestHeight
+	startHeight  int64
+	latestHeight int64
+
+	pendingMtx sync.Mutex
+	pending    map[string][]byte // the writes to the current trunk, nil means deletion
+	sealed     map[string][]byte // the writes to the current trunk before Commit starts
+
+	cacheMtx sync.RWMutex
+	cache    map[string]int64 // short key -> the height of its latest record
+}
+
+func createArchiveStore(config *param.ChainConfig) *archiveStore {
+	if !config.ArchiveMode {
+		return nil
+	}
+	db, err := dbm.NewDB("archive", dbm.GoLevelDBBackend, config.ArchiveDataPath)
+	if err != nil {
+		panic(err)
+	}
+	return &archiveStore{
+		db:      db,
+		pending: make(map[string][]byte),
+		sealed:  make(map[string][]byte),
+		cache:   make(map[string]int64),
+	}
+}
+
+// The height of the state which is written back to the root store when committing 'currHeight'
+func archivedHeight(currHeight int64) int64 {
+	if currHeight <= 1 {
+		return 0
+	}
+	return currHeight - 1
+}
+
+// Bind the archive to the state of 'height' in the root store. A new archive starts from 'height',
+// and an existing one must not lag behind it.
+func (a *archiveStore) setup(height int64) error {
+	latestBz, err := a.db.Get(archiveLatestKey)
+	if err != nil {
+		return err
+	}
+	if latestBz == nil {
+		return a.reset(height)
+	}
+	startBz, err := a.db.Get(archiveStartKey)
+	if err != nil {
+		return err
+	}
+	start, latest := int64(binary.BigEndian.Uint64(startBz)), int64(binary.BigEndian.Uint64(latestBz))
+	// latest may be height+1 if the node stopped after recording the writes of a block but before committing it
+	if latest < height {
+		return fmt.Errorf("the archive ends at height %d but the state is at height %d, "+
+			"please remove the archive directory to rebuild it", latest, height)
+	}
+	a.mtx.Lock()
+	a.startHeight, a.latestHeight = start, height
+	a.mtx.Unlock()
+	return nil
+}
+
+// Drop all the records and start a new archive from 'height'
+func (a *archiveStore) reset(height int64) error {
+	iter, err := a.db.Iterator(nil, nil)
+	if err != nil {
+		return err
+	}
+	batch := a.db.NewBatch()
+	defer batch.Close()
+	for ; iter.Valid(); iter.Next() {
+		if err = batch.Delete(iter.Key()); err != nil {
+			break
+		}
+	}
+	_ = iter.Close()
+	if err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(height))
+	if err = batch.Set(archiveStartKey, buf[:]); err != nil {
+		return err
+	}
+	if err = batch.Set(archiveLatestKey, buf[:]); err != nil {
+		return err
+	}
+	if err = batch.WriteSync(); err != nil {
+		return err
+	}
+	a.mtx.Lock()
+	a.startHeight, a.latestHeight = height, height
+	a.mtx.Unlock()
+	a.cacheMtx.Lock()
+	a.cache = make(map[string]int64)
+	a.cacheMtx.Unlock()
+	return nil
+}
+
+func (a *archiveStore) heightRange() (start, latest int64) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.startHeight, a.latestHeight
+}
+
+func (a *archiveStore) record(key, value []byte) {
+	a.pendingMtx.Lock()
+	defer a.pendingMtx.Unlock()
+	if value != nil {
+		value = append([]byte{}, value...)
+	}
+	a.pending[string(key)] = value
+}
+
+// Take the writes to the current trunk so far as the state of the previous block, which is called
+// when Commit starts
+func (a *archiveStore) seal() {
+	a.pendingMtx.Lock()
+	defer a.pendingMtx.Unlock()
+	for k, v := range a.pending {
+		a.sealed[k] = v
+	}
+	a.pending = make(map[string][]byte)
+}
+
+// Forget the writes to the current trunk, which is closed without writing back
+func (a *archiveStore) discard() {
+	a.pendingMtx.Lock()
+	defer a.pendingMtx.Unlock()
+	a.pending = make(map[string][]byte)
+	a.sealed = make(map[string][]byte)
+}
+
+// Record the sealed writes to the current trunk with 'height' and the other writes with 'height'+1, and
+// then write back the trunk to 'root'. The readers holding the root store's read lock delay the writing
+// back, so they never find a short key without any record whose value in the root store has changed.
+func (a *archiveStore) commit(height int64, root adstypes.BaseStoreI, writeBack func()) {
+	a.pendingMtx.Lock()
+	sealed, pending := a.sealed, a.pending
+	a.sealed, a.pending = make(map[string][]byte), make(map[string][]byte)
+	a.pendingMtx.Unlock()
+
+	start, _ := a.heightRange()
+	batch := a.db.NewBatch()
+	defer batch.Close()
+	latest := make(map[string]int64, len(sealed)+len(pending))
+	for _, writes := range []struct {
+		height int64
+		values map[string][]byte
+	}{{height, sealed}, {height + 1, pending}} {
+		for k, v := range writes.values {
+			if _, ok := latest[k]; !ok && !a.hasRecord([]byte(k), start) {
+				mustSet(batch, archiveRecordKey([]byte(k), start), archiveRecordValue(root.Get([]byte(k))))
+			}
+			mustSet(batch, archiveRecordKey([]byte(k), writes.height), archiveRecordValue(v))
+			latest[k] = writes.height
+		}
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(height))
+	mustSet(batch, archiveLatestKey, buf[:])
+	if err := batch.WriteSync(); err != nil {
+		panic(err)
+	}
+	a.updateCache(latest)
+	writeBack()
+	a.mtx.Lock()
+	a.latestHeight = height
+	a.mtx.Unlock()
+}
+
+// Every recorded short key has a record at the start height, so it is checked by a point lookup
+// if its latest record height is not cached
+func (a *archiveStore) hasRecord(key []byte, start int64) bool {
+	a.cacheMtx.RLock()
+	_, ok := a.cache[string(key)]
+	a.cacheMtx.RUnlock()
+	if ok {
+		return true
+	}
+	ok, err := a.db.Has(archiveRecordKey(key, start))
+	if err != nil {
+		panic(err)
+	}
+	return ok
+}
+
+func (a *archiveStore) updateCache(latest map[string]int64) {
+	a.cacheMtx.Lock()
+	defer a.cacheMtx.Unlock()
+	for k, height := range latest {
+		a.cache[k] = height
+	}
+	for k := range a.cache { // pseudo-random eviction
+		if len(a.cache) <= archiveCacheSize {
+			break
+		}
+		delete(a.cache, k)
+	}
+}
+
+// Get the value of the short key at 'height', found is false if the short key has no record
+func (a *archiveStore) get(key []byte, height int64) (value []byte, found bool) {
+	a.cacheMtx.RLock()
+	latest, ok := a.cache[string(key)]
+	a.cacheMtx.RUnlock()
+	var v []byte
+	if ok && latest <= height {
+		bz, err := a.db.Get(archiveRecordKey(key, latest))
+		if err != nil {
+			panic(err)
+		}
+		v = bz
+	} else {
+		iter, err := a.db.ReverseIterator(archiveRecordKey(key, 0), archiveRecordKey(key, height+1))
+		if err != nil {
+			panic(err)
+		}
+		defer iter.Close()
+		if iter.Valid() {
+			v = iter.Value()
+		}
+	}
+	if v == nil {
+		return nil, false
+	}
+	if v[0] == archiveFlagDeleted {
+		return nil, true
+	}
+	return append([]byte{}, v[1:]...), true
+}
+
+func (a *archiveStore) close() {
+	_ = a.db.Close()
+}
+
+func archiveRecordKey(key []byte, height int64) []byte {
+	res := make([]byte, 0, 1+len(key)+8)
+	res = append(res, archiveRecordPrefix)
+	res = append(res, key...)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(height))
+	return append(res, buf[:]...)
+}
+
+func archiveRecordValue(value []byte) []byte {
+	if value == nil {
+		return []byte{archiveFlagDeleted}
+	}
+	return append([]byte{archiveFlagExisting}, value...)
+}
+
+func mustSet(batch dbm.Batch, key, value []byte) {
+	if err := batch.Set(key, value); err != nil {
+		panic(err)
+	}
+}
+
+// The store under the rabbit stores writing app.trunk in archive mode, which records the writes
+type archivingTrunk struct {
+	*store.TrunkStore
+	archive *archiveStore
+}
+
+func (t *archivingTrunk) Update(updater func(db adstypes.SetDeleter)) {
+	t.TrunkStore.Update(func(db adstypes.SetDeleter) {
+		updater(&archivingSetDeleter{db: db, archive: t.archive})
+	})
+}
+
+type archivingSetDeleter struct {
+	db      adstypes.SetDeleter
+	archive *archiveStore
+}
+
+func (sd *archivingSetDeleter) Set(key, value []byte) {
+	sd.db.Set(key, value)
+	sd.archive.record(key, value)
+}
+
+func (sd *archivingSetDeleter) Delete(key []byte) {
+	sd.db.Delete(key)
+	sd.archive.record(key, nil)
+}
+
+// A read-only store presenting the state at a past height, which is read by a read-only rabbit store
+type archivedStateStore struct {
+	root    *store.RootStore
+	archive *archiveStore
+	height  int64
+}
+
+func (s *archivedStateStore) RLock()   { s.root.RLock() }
+func (s *archivedStateStore) RUnlock() { s.root.RUnlock() }
+
+func (s *archivedStateStore) Get(key []byte) []byte {
+	if value, found := s.archive.get(key, s.height); found {
+		return value
+	}
+	return s.root.Get(key)
+}
+
+// The rabbit store caches the writes of a tx run for RPC, they are never written back
+func (s *archivedStateStore) PrepareForUpdate(key []byte)   {}
+func (s *archivedStateStore) PrepareForDeletion(key []byte) {}
+
+func (s *archivedStateStore) Update(func(db adstypes.SetDeleter)) {
+	panic("archived state is read-only")
+}
+
+func (s *archivedStateStore) ActiveCount() int {
+	return s.root.ActiveCount()
+}
+
+// The store under the rabbit stores which write app.trunk
+func (app *App) trunkStore() adstypes.BaseStoreI {
+	if app.archive == nil {
+		return app.trunk
+	}
+	return &archivingTrunk{TrunkStore: app.trunk, archive: app.archive}
+}
+
+// Seal the writes to app.trunk so far as the state of the previous block, if in archive mode
+func (app *App) sealArchive() {
+	if app.archive != nil {
+		app.archive.seal()
+	}
+}
+
+// Write back app.trunk to the root store, recording the writes if in archive mode
+func (app *App) writeBackTrunk() {
+	if app.archive == nil {
+		app.trunk.Close(true)
+		return
+	}
+	app.archive.commit(archivedHeight(app.currHeight), app.root, func() {
+		app.trunk.Close(true)
+	})
+}
+
+// Returns a read-only context bound to the state at 'height', which is kept in archive mode.
+// A negative height means the latest state. Like a pruned geth node, ErrHistoricalStateNotAvailable is
+// returned for the heights whose states are not kept.
+func (app *App) GetRpcContextAtHeight(height int64) (*types.Context, error) {
+	if height < 0 {
+		return app.GetRpcContext(), nil
+	}
+	if app.archive == nil {
+		return nil, fmt.Errorf("%w at height %d, the node is not in archive mode",
+			ErrHistoricalStateNotAvailable, height)
+	}
+	start, latest := app.archive.heightRange()
+	if height < start || height > latest {
+		return nil, fmt.Errorf("%w at height %d, the archive has the states from height %d to %d",
+			ErrHistoricalStateNotAvailable, height, start, latest)
+	}
+	c := types.NewContext(uint64(height), nil, nil)
+	r := rabbit.NewReadOnlyRabbitStore(&archivedStateStore{root: app.root, archive: app.archive, height: height})
+	c = c.WithRbt(&r)
+	c = c.WithDb(app.historyStore)
+	return c, nil
+}
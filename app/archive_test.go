@@ -0,0 +1,142 @@
+package app_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+
+	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/internal/ethutils"
+	"github.com/smartbch/smartbch/internal/testutils"
+)
+
+func TestArchiveMode(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppInArchiveMode(key)
+	defer _app.Destroy()
+
+	// see testdata/counter/contracts/Counter.sol
+	creationBytecode := testutils.HexToBytes(`
+608060405234801561001057600080fd5b5060cc8061001f6000396000f3fe60
+80604052348015600f57600080fd5b506004361060325760003560e01c806361
+bc221a1460375780636299a6ef146053575b600080fd5b603d607e565b604051
+8082815260200191505060405180910390f35b607c6004803603602081101560
+6757600080fd5b81019080803590602001909291905050506084565b005b6000
+5481565b8060008082825401925050819055505056fea2646970667358221220
+37865cfcfd438966956583c78d31220c05c0f1ebfd116aced883214fcb1096c6
+64736f6c634300060c0033
+`)
+	_, h0, counterAddr := _app.DeployContractInBlock(key, creationBytecode)
+	// call update(2) and update(3)
+	_, h1 := _app.MakeAndExecTxInBlock(key, counterAddr, 0,
+		testutils.HexToBytes("0x6299a6ef0000000000000000000000000000000000000000000000000000000000000002"))
+	_, h2 := _app.MakeAndExecTxInBlock(key, counterAddr, 0,
+		testutils.HexToBytes("0x6299a6ef0000000000000000000000000000000000000000000000000000000000000003"))
+	_app.WaitMS(100)
+
+	getState := func(height int64) (nonce uint64, counter []byte) {
+		ctx, err := _app.GetRpcContextAtHeight(height)
+		require.NoError(t, err)
+		defer ctx.Close(false)
+		nonce = ctx.GetAccount(addr).Nonce()
+		if acc := ctx.GetAccount(counterAddr); acc != nil {
+			counter = ctx.GetStorageAt(acc.Sequence(), string(make([]byte, 32)))
+		}
+		return
+	}
+	nonce, counter := getState(h0 - 1)
+	require.Equal(t, uint64(0), nonce)
+	require.Nil(t, counter)
+	nonce, counter = getState(h0)
+	require.Equal(t, uint64(1), nonce)
+	require.Nil(t, counter)
+	nonce, counter = getState(h1)
+	require.Equal(t, uint64(2), nonce)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(2)).Bytes(), counter)
+	nonce, counter = getState(h2)
+	require.Equal(t, uint64(3), nonce)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(5)).Bytes(), counter)
+	nonce, counter = getState(-1)
+	require.Equal(t, uint64(3), nonce)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(5)).Bytes(), counter)
+
+	// call counter() on the past states
+	callTx := ethutils.NewTx(0, &counterAddr, big.NewInt(0), testutils.DefaultGasLimit, big.NewInt(0),
+		testutils.HexToBytes("0x61bc221a"))
+	runner, _, err := _app.RunTxForRpcAtHeight(callTx, addr, false, h1)
+	require.NoError(t, err)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(2)).Bytes(), runner.OutData)
+	runner, _, err = _app.RunTxForRpcAtHeight(callTx, addr, false, h2)
+	require.NoError(t, err)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(5)).Bytes(), runner.OutData)
+	_, _, err = _app.RunTxForRpcAtHeight(callTx, addr, false, h2+100)
+	require.True(t, errors.Is(err, app.ErrHistoricalStateNotAvailable))
+
+	// abci queries on the past states
+	queryNonce := func(height int64) uint64 {
+		res := _app.Query(abci.RequestQuery{Path: "/account/" + addr.Hex(), Height: height})
+		require.Equal(t, abci.CodeTypeOK, res.Code, res.Log)
+		require.Equal(t, height, res.Height)
+		var accRes app.AccountQueryResult
+		require.NoError(t, json.Unmarshal(res.Value, &accRes))
+		return accRes.Nonce
+	}
+	require.Equal(t, uint64(1), queryNonce(h0))
+	require.Equal(t, uint64(2), queryNonce(h1))
+	res := _app.Query(abci.RequestQuery{Path: "/account/" + addr.Hex(), Height: h2 + 100})
+	require.Equal(t, app.HeightNotAvailable, res.Code)
+}
+
+func TestArchiveMode_gasFees(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_, addr2 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppInArchiveMode(key)
+	defer _app.Destroy()
+
+	tx, h := _app.MakeAndExecTxInBlockWithGasPrice(key, addr2, 100, nil, 1)
+	_app.WaitMS(100)
+	_app.EnsureTxSuccess(tx.Hash())
+
+	getBalance := func(height int64) *big.Int {
+		ctx, err := _app.GetRpcContextAtHeight(height)
+		require.NoError(t, err)
+		defer ctx.Close(false)
+		return ctx.GetAccount(addr).Balance().ToBig()
+	}
+	// the gas fees of block h are deducted by Prepare when committing block h,
+	// which is not a part of the state at h-1
+	require.Equal(t, new(big.Int).SetUint64(testutils.DefaultInitBalance), getBalance(h-1))
+	require.Equal(t, _app.GetBalance(addr), getBalance(h))
+	require.True(t, getBalance(h).Cmp(new(big.Int).SetUint64(testutils.DefaultInitBalance-100)) < 0)
+}
+
+func TestArchiveMode_disabled(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_, addr2 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	defer _app.Destroy()
+
+	_, h := _app.MakeAndExecTxInBlock(key, addr2, 100, nil)
+	_app.WaitMS(100)
+
+	// the past heights are not served with the latest state
+	_, err := _app.GetRpcContextAtHeight(h - 1)
+	require.True(t, errors.Is(err, app.ErrHistoricalStateNotAvailable))
+	_, _, err = _app.RunTxForRpcAtHeight(ethutils.NewTx(0, &addr2, big.NewInt(0), testutils.DefaultGasLimit,
+		big.NewInt(0), nil), addr, false, h-1)
+	require.True(t, errors.Is(err, app.ErrHistoricalStateNotAvailable))
+	ctx, err := _app.GetRpcContextAtHeight(-1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), ctx.GetAccount(addr).Nonce())
+	ctx.Close(false)
+
+	// and neither to abci queries
+	res := _app.Query(abci.RequestQuery{Path: "/account/" + addr.Hex(), Height: h})
+	require.Equal(t, app.HeightNotAvailable, res.Code)
+}
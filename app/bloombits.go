@@ -0,0 +1,181 @@
+package app
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/bitutil"
+	"github.com/ethereum/go-ethereum/core/bloombits"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/param"
+)
+
+// The bloombits index is in the same format as go-ethereum's core/bloombits: the blocks are divided into
+// sections of 'size' blocks, and for each of the 2048 bits of the logs bloom, a section has a bit vector
+// telling which of its blocks have the bit set. So eth_getLogs can find the candidate blocks of a long range
+// by reading a few vectors instead of every block. Only complete sections are indexed, the blocks of the
+// incomplete section are kept in memory, and they are reloaded from the history store when the node restarts.
+
+const bloomBitsVectorPrefix = byte('b')
+
+var bloomBitsSectionsKey = []byte("sections") // the count of indexed sections
+
+type bloomBitsStore struct {
+	db   dbm.DB
+	size uint64 // the count of blocks in a section
+
+	mtx      sync.RWMutex // protects sections
+	sections uint64
+
+	gen  *bloombits.Generator // collects the blooms of the incomplete section
+	next uint64               // the height of the next block to be indexed
+}
+
+func createBloomBitsStore(config *param.ChainConfig) *bloomBitsStore {
+	db, err := dbm.NewDB("bloombits", dbm.GoLevelDBBackend, config.BloomBitsDataPath)
+	if err != nil {
+		panic(err)
+	}
+	bbs, err := newBloomBitsStore(db, config.BloomBitsSectionSize)
+	if err != nil {
+		panic(err)
+	}
+	return bbs
+}
+
+func newBloomBitsStore(db dbm.DB, size uint64) (*bloomBitsStore, error) {
+	if size == 0 || size%8 != 0 {
+		return nil, fmt.Errorf("the bloombits section size must be a positive multiple of 8, got %d", size)
+	}
+	bz, err := db.Get(bloomBitsSectionsKey)
+	if err != nil {
+		return nil, err
+	}
+	bbs := &bloomBitsStore{db: db, size: size}
+	if bz != nil {
+		bbs.sections = binary.BigEndian.Uint64(bz)
+	}
+	bbs.next = bbs.sections * size
+	return bbs, nil
+}
+
+// Index the blocks after the indexed sections, whose serialized infos are got by 'getBlockInfo'. The blocks up to
+// 'latest' are all indexed, the missing ones are taken as having no logs, and the ones after it are indexed until
+// a missing one is met.
+func (bbs *bloomBitsStore) setup(latest int64, getBlockInfo func(height int64) []byte) {
+	for height := int64(bbs.next); ; height++ {
+		blkInfo := getBlockInfo(height)
+		if blkInfo == nil && height > latest {
+			break
+		}
+		bbs.add(height, logsBloomFromBlockInfo(blkInfo))
+	}
+}
+
+// Add the bloom of the block at 'height', and write the bit vectors of the section when it is complete.
+// The blocks must be added in ascending order, the missing ones before 'height' are taken as having no logs.
+func (bbs *bloomBitsStore) add(height int64, bloom gethtypes.Bloom) {
+	if height < 0 || uint64(height) < bbs.next {
+		return // already indexed
+	}
+	for bbs.next < uint64(height) {
+		bbs.addNext(gethtypes.Bloom{})
+	}
+	bbs.addNext(bloom)
+}
+
+func (bbs *bloomBitsStore) addNext(bloom gethtypes.Bloom) {
+	if bbs.gen == nil {
+		gen, err := bloombits.NewGenerator(uint(bbs.size))
+		if err != nil {
+			panic(err)
+		}
+		bbs.gen = gen
+	}
+	if err := bbs.gen.AddBloom(uint(bbs.next%bbs.size), bloom); err != nil {
+		panic(err)
+	}
+	bbs.next++
+	if bbs.next%bbs.size == 0 {
+		bbs.writeSection(bbs.next/bbs.size - 1)
+		bbs.gen = nil
+	}
+}
+
+func (bbs *bloomBitsStore) writeSection(section uint64) {
+	batch := bbs.db.NewBatch()
+	defer batch.Close()
+	for bit := uint(0); bit < gethtypes.BloomBitLength; bit++ {
+		bitset, err := bbs.gen.Bitset(bit)
+		if err != nil {
+			panic(err)
+		}
+		// an all-zero vector is compressed to nil, which cannot be stored
+		compressed := append([]byte{}, bitutil.CompressBytes(bitset)...)
+		mustSet(batch, bloomBitsVectorKey(bit, section), compressed)
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], section+1)
+	mustSet(batch, bloomBitsSectionsKey, buf[:])
+	if err := batch.WriteSync(); err != nil {
+		panic(err)
+	}
+	bbs.mtx.Lock()
+	bbs.sections = section + 1
+	bbs.mtx.Unlock()
+}
+
+// Returns the section size and the count of indexed sections
+func (bbs *bloomBitsStore) status() (size, sections uint64) {
+	bbs.mtx.RLock()
+	defer bbs.mtx.RUnlock()
+	return bbs.size, bbs.sections
+}
+
+// Returns the bit vector of 'bit' in an indexed section
+func (bbs *bloomBitsStore) bitset(bit uint, section uint64) ([]byte, error) {
+	if _, sections := bbs.status(); section >= sections {
+		return nil, fmt.Errorf("section %d is not indexed", section)
+	}
+	compressed, err := bbs.db.Get(bloomBitsVectorKey(bit, section))
+	if err != nil {
+		return nil, err
+	}
+	return bitutil.DecompressBytes(compressed, int(bbs.size/8))
+}
+
+func (bbs *bloomBitsStore) close() {
+	_ = bbs.db.Close()
+}
+
+func bloomBitsVectorKey(bit uint, section uint64) []byte {
+	key := make([]byte, 1+2+8)
+	key[0] = bloomBitsVectorPrefix
+	binary.BigEndian.PutUint16(key[1:], uint16(bit))
+	binary.BigEndian.PutUint64(key[3:], section)
+	return key
+}
+
+// Returns the logs bloom in a serialized block info, or an empty bloom if it cannot be decoded
+func logsBloomFromBlockInfo(blkInfo []byte) gethtypes.Bloom {
+	var b types.Block
+	if _, err := b.UnmarshalMsg(blkInfo); err != nil {
+		return gethtypes.Bloom{}
+	}
+	return b.LogsBloom
+}
+
+// BloomStatus returns the section size of the bloombits index and the count of indexed sections
+func (app *App) BloomStatus() (uint64, uint64) {
+	return app.bloomBits.status()
+}
+
+// BloomBits returns the bit vector of 'bit' in an indexed section of the bloombits index
+func (app *App) BloomBits(bit uint, section uint64) ([]byte, error) {
+	return app.bloomBits.bitset(bit, section)
+}
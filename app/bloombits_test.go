@@ -0,0 +1,96 @@
+package app_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/internal/bigutils"
+	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/param"
+)
+
+const (
+	bloomBitsAdsDir  = "./bloombitstestdbdata"
+	bloomBitsModbDir = "./bloombitstestmodbdata"
+	bloomBitsDir     = "./bloombitstestdata"
+)
+
+func TestBloomBits(t *testing.T) {
+	dirs := []string{bloomBitsAdsDir, bloomBitsModbDir, bloomBitsDir}
+	for _, dir := range dirs {
+		_ = os.RemoveAll(dir)
+	}
+	defer func() {
+		for _, dir := range dirs {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+	params := param.DefaultConfig()
+	params.AppDataPath = bloomBitsAdsDir
+	params.ModbDataPath = bloomBitsModbDir
+	params.BloomBitsDataPath = bloomBitsDir
+	params.BloomBitsSectionSize = 8
+
+	addr := gethcmn.Address{0xA1}
+	var bloom gethtypes.Bloom
+	bloom.Add(addr.Bytes())
+	bit := firstBloomBit(bloom)
+	addBlocks := func(_app *app.App, from, to int64) {
+		for h := from; h <= to; h++ {
+			b := testutils.NewMdbBlockBuilder().Height(h).Hash(gethcmn.Hash{0xB0, byte(h)})
+			if h == 3 || h == 12 || h == 18 {
+				b.Tx(gethcmn.Hash{0xC0, byte(h)}, types.Log{Address: addr})
+			}
+			_app.AddBlockFotTest(b.Build())
+		}
+	}
+
+	_app := app.NewApp(params, bigutils.NewU256(1), log.NewNopLogger())
+	addBlocks(_app, 1, 13)
+	size, sections := _app.BloomStatus()
+	require.Equal(t, uint64(8), size)
+	require.Equal(t, uint64(1), sections)
+	bitset, err := _app.BloomBits(bit, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x10}, bitset) // block 3
+	bitset, err = _app.BloomBits(bit+1, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00}, bitset)
+	_, err = _app.BloomBits(bit, 1)
+	require.EqualError(t, err, "section 1 is not indexed")
+	_app.Stop()
+
+	// the blocks of the incomplete section are reloaded from the history store
+	_app = app.NewApp(params, bigutils.NewU256(1), log.NewNopLogger())
+	defer _app.Stop()
+	_, sections = _app.BloomStatus()
+	require.Equal(t, uint64(1), sections)
+	addBlocks(_app, 13, 20)
+	_, sections = _app.BloomStatus()
+	require.Equal(t, uint64(2), sections)
+	bitset, err = _app.BloomBits(bit, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x10}, bitset) // block 3
+	bitset, err = _app.BloomBits(bit, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x08}, bitset) // block 12
+}
+
+// Returns the index of the first bit set in bloom, in the order of the bit vectors of the bloombits index
+func firstBloomBit(bloom gethtypes.Bloom) uint {
+	for i := uint(0); i < gethtypes.BloomBitLength; i++ {
+		if bloom[gethtypes.BloomByteLength-1-i/8]&(1<<(i%8)) != 0 {
+			return i
+		}
+	}
+	panic("empty bloom")
+}
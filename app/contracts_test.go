@@ -12,7 +12,10 @@ import (
 	gethcmn "github.com/ethereum/go-ethereum/common"
 	gethtypes "github.com/ethereum/go-ethereum/core/types"
 
+	motypes "github.com/smartbch/moeingevm/types"
+
 	"github.com/smartbch/smartbch/internal/bigutils"
+	"github.com/smartbch/smartbch/internal/ethutils"
 	"github.com/smartbch/smartbch/internal/testutils"
 )
 
@@ -103,10 +106,26 @@ ffffffffffffffffffffffffffffffffffffff167fd1c6b99eac4e6a0f44c679
 		hex.EncodeToString(txInBlk3.Logs[0].Topics[0][:]))
 	require.Equal(t, "000000000000000000000000"+hex.EncodeToString(addr[:]),
 		hex.EncodeToString(txInBlk3.Logs[0].Topics[1][:]))
+	bloom := gethtypes.Bloom(blk2.LogsBloom)
+	require.True(t, bloom.Test(contractAddr[:]))
+	require.True(t, bloom.Test(txInBlk3.Logs[0].Topics[0][:]))
+	receiptsRoot, _ := ethutils.ReceiptsRootAndBloom(ethutils.ToGethReceipts([]*motypes.Transaction{txInBlk3}))
+	require.NotEqual(t, gethcmn.Hash{}, receiptsRoot)
+	require.Equal(t, receiptsRoot, _app.GetReceiptsRoot(h2))
 
 	// call emitEvent2()
+	logsCh := make(chan []*gethtypes.Log, 16)
+	logsSub := _app.SubscribeLogsEvent(logsCh)
+	defer logsSub.Unsubscribe()
 	tx3, h3 := _app.MakeAndExecTxInBlock(key,
 		contractAddr, 0, testutils.HexToBytes("0xfb584c39000000000000000000000000000000000000000000000000000000000000007b"))
+	publishedLogs := <-logsCh
+	require.Len(t, publishedLogs, 1)
+	require.Equal(t, tx3.Hash(), publishedLogs[0].TxHash)
+	require.Equal(t, uint64(h3), publishedLogs[0].BlockNumber)
+	require.Equal(t, uint(0), publishedLogs[0].Index)
+	require.Equal(t, "000000000000000000000000000000000000000000000000000000000000007b",
+		hex.EncodeToString(publishedLogs[0].Data))
 
 	_app.WaitMS(100)
 	blk3 := _app.GetBlock(h3)
@@ -136,6 +155,22 @@ ffffffffffffffffffffffffffffffffffffff167fd1c6b99eac4e6a0f44c679
 	require.Len(t, tx4QueryResult.Logs, 0)
 }
 
+func TestReceiptsRootOfBlockWithoutIndex(t *testing.T) {
+	key, _ := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	defer _app.Destroy()
+
+	// the block is added to the history store only, so its receipts root is recomputed from its transactions
+	log := motypes.Log{Address: gethcmn.Address{0xA1}, Topics: [][32]byte{{0xC1}}}
+	blk := testutils.NewMdbBlockBuilder().Height(100).Hash(gethcmn.Hash{0xB1}).
+		Tx(gethcmn.Hash{0xD1}, log).Build()
+	_app.AddBlockFotTest(blk)
+	tx := _app.GetTx(gethcmn.Hash{0xD1})
+	receiptsRoot, _ := ethutils.ReceiptsRootAndBloom(ethutils.ToGethReceipts([]*motypes.Transaction{tx}))
+	require.Equal(t, receiptsRoot, _app.GetReceiptsRoot(100))
+	require.Equal(t, gethcmn.Hash{}, _app.GetReceiptsRoot(101))
+}
+
 func TestChainID(t *testing.T) {
 	key, addr := testutils.GenKeyAndAddr()
 	_app := testutils.CreateTestApp(key)
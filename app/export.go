@@ -0,0 +1,83 @@
+package app
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	gethcore "github.com/ethereum/go-ethereum/core"
+
+	"github.com/smartbch/moeingads/store/rabbit"
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/staking"
+	stakingtypes "github.com/smartbch/smartbch/staking/types"
+)
+
+// Export the world state committed at the latest height as GenesisData, which can be used to start a new chain.
+// The storage of the staking contract is exported as StakingInfo, and the standby txs which are not executed
+// yet are dropped.
+func (app *App) ExportState() (genesisData *GenesisData, height int64, err error) {
+	app.mtx.Lock() // wait for postCommit
+	defer app.mtx.Unlock()
+
+	accounts := make(map[gethcmn.Address]*types.AccountInfo)
+	codes := make(map[gethcmn.Address][]byte)
+	storages := make(map[uint64]map[gethcmn.Hash]gethcmn.Hash)
+	var stakingInfoBz []byte
+
+	first := []byte{0, 0, 0, 0, 0, 0, 0, 0}
+	last := []byte{255, 255, 255, 255, 255, 255, 255, 255}
+	iter := app.mads.Iterator(first, last)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		cv := rabbit.BytesToCachedValue(iter.Value())
+		if cv == nil || cv.IsEmpty() || len(cv.GetKey()) == 0 {
+			continue
+		}
+		key, value := cv.GetKey(), append([]byte{}, cv.GetValue()...)
+		switch key[0] {
+		case types.ACCOUNT_KEY:
+			accounts[gethcmn.BytesToAddress(key[1:])] = types.NewAccountInfo(value)
+		case types.BYTECODE_KEY:
+			codes[gethcmn.BytesToAddress(key[1:])] = types.NewBytecodeInfo(value).BytecodeSlice()
+		case types.VALUE_KEY:
+			seq, slot := binary.BigEndian.Uint64(key[1:9]), string(key[9:])
+			if seq == staking.StakingContractSequence {
+				if slot == staking.SlotStakingInfo {
+					stakingInfoBz = value
+				}
+				continue
+			}
+			if len(value) > gethcmn.HashLength {
+				return nil, 0, fmt.Errorf("the value of slot %x of sequence %d is longer than 32 bytes", slot, seq)
+			}
+			if storages[seq] == nil {
+				storages[seq] = make(map[gethcmn.Hash]gethcmn.Hash)
+			}
+			storages[seq][gethcmn.BytesToHash(key[9:])] = gethcmn.BytesToHash(value)
+		}
+	}
+
+	genesisData = &GenesisData{
+		Version:     CurrGenesisVersion,
+		Alloc:       make(gethcore.GenesisAlloc, len(accounts)),
+		StakingInfo: &stakingtypes.StakingInfo{},
+	}
+	for addr, acc := range accounts {
+		genesisAcc := gethcore.GenesisAccount{
+			Balance: acc.Balance().ToBig(),
+			Nonce:   acc.Nonce(),
+		}
+		if code := codes[addr]; len(code) != 0 {
+			genesisAcc.Code = code
+			genesisAcc.Storage = storages[acc.Sequence()]
+		}
+		genesisData.Alloc[addr] = genesisAcc
+	}
+	if _, err = genesisData.StakingInfo.UnmarshalMsg(stakingInfoBz); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode staking info: %w", err)
+	}
+	genesisData.Validators = genesisData.StakingInfo.Validators
+	return genesisData, app.currHeight, nil
+}
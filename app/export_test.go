@@ -0,0 +1,59 @@
+package app_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+
+	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/staking"
+)
+
+func TestExportState(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	defer _app.Destroy()
+
+	// see testdata/counter/contracts/Counter.sol
+	creationBytecode := testutils.HexToBytes(`
+608060405234801561001057600080fd5b5060cc8061001f6000396000f3fe60
+80604052348015600f57600080fd5b506004361060325760003560e01c806361
+bc221a1460375780636299a6ef146053575b600080fd5b603d607e565b604051
+8082815260200191505060405180910390f35b607c6004803603602081101560
+6757600080fd5b81019080803590602001909291905050506084565b005b6000
+5481565b8060008082825401925050819055505056fea2646970667358221220
+37865cfcfd438966956583c78d31220c05c0f1ebfd116aced883214fcb1096c6
+64736f6c634300060c0033
+`)
+	_, _, contractAddr := _app.DeployContractInBlock(key, creationBytecode)
+	_, h := _app.MakeAndExecTxInBlock(key, contractAddr, 0,
+		testutils.HexToBytes("0x6299a6ef0000000000000000000000000000000000000000000000000000000000000007"))
+	_app.WaitMS(100)
+
+	genesisData, height, err := _app.ExportState()
+	require.NoError(t, err)
+	require.Equal(t, h+1, height)
+
+	acc, ok := genesisData.Alloc[addr]
+	require.True(t, ok)
+	require.Equal(t, _app.GetBalance(addr), acc.Balance)
+	require.Equal(t, uint64(2), acc.Nonce)
+	require.Empty(t, acc.Code)
+
+	contract, ok := genesisData.Alloc[contractAddr]
+	require.True(t, ok)
+	require.Equal(t, _app.GetCode(contractAddr), contract.Code)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(7)), contract.Storage[gethcmn.Hash{}])
+
+	stakingAcc, ok := genesisData.Alloc[staking.StakingContractAddress]
+	require.True(t, ok)
+	require.Empty(t, stakingAcc.Storage)
+
+	require.NotNil(t, genesisData.StakingInfo)
+	require.Len(t, genesisData.StakingInfo.Validators, 1)
+	require.Equal(t, _app.TestPubkey.Bytes(), genesisData.StakingInfo.Validators[0].Pubkey[:])
+	require.Equal(t, genesisData.StakingInfo.Validators, genesisData.Validators)
+}
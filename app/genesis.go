@@ -3,10 +3,27 @@ package app
 import (
 	gethcore "github.com/ethereum/go-ethereum/core"
 
+	"github.com/smartbch/smartbch/param"
 	stakingtypes "github.com/smartbch/smartbch/staking/types"
 )
 
+const (
+	// The genesis files of the existing chains have no version, their alloc only sets the balances of the
+	// accounts, and the accounts are created with zero sequences
+	GenesisVersionLegacy = 0
+	// The alloc sets the code, storage and nonce of the accounts, and the accounts without code are created
+	// with math.MaxUint64 as sequence
+	GenesisVersionAllocWithCode = 1
+
+	CurrGenesisVersion = GenesisVersionAllocWithCode
+)
+
 type GenesisData struct {
+	// New genesis files use CurrGenesisVersion, it can not be changed for a chain which has started
+	Version    int                       `json:"version,omitempty"`
 	Validators []*stakingtypes.Validator `json:"validators"`
 	Alloc      gethcore.GenesisAlloc     `json:"alloc"`
+	Upgrades   []param.Upgrade           `json:"upgrades,omitempty"`
+	// If it is not nil, it replaces Validators to initialize the staking contract
+	StakingInfo *stakingtypes.StakingInfo `json:"staking_info,omitempty"`
 }
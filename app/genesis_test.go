@@ -0,0 +1,103 @@
+package app_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	gethcore "github.com/ethereum/go-ethereum/core"
+
+	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/staking"
+)
+
+// see testdata/counter/contracts/Counter.sol
+var counterRuntimeBytecode = testutils.HexToBytes(`
+6080604052348015600f57600080fd5b506004361060325760003560e01c806361
+bc221a1460375780636299a6ef146053575b600080fd5b603d607e565b604051
+8082815260200191505060405180910390f35b607c6004803603602081101560
+6757600080fd5b81019080803590602001909291905050506084565b005b6000
+5481565b8060008082825401925050819055505056fea2646970667358221220
+37865cfcfd438966956583c78d31220c05c0f1ebfd116aced883214fcb1096c6
+64736f6c634300060c0033
+`)
+
+func TestGenesisContract(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	contractAddr := gethcmn.HexToAddress("0x8888888888888888888888888888888888888888")
+	alloc := gethcore.GenesisAlloc{
+		contractAddr: {
+			Balance: big.NewInt(100),
+			Nonce:   3,
+			Code:    counterRuntimeBytecode,
+			Storage: map[gethcmn.Hash]gethcmn.Hash{
+				{}: gethcmn.BigToHash(big.NewInt(5)),
+			},
+		},
+		staking.StakingContractAddress: {Balance: big.NewInt(200)},
+	}
+	_app := testutils.CreateTestAppWithAlloc(alloc, key)
+	defer _app.Destroy()
+
+	require.Equal(t, big.NewInt(100), _app.GetBalance(contractAddr))
+	require.Equal(t, uint64(3), _app.GetNonce(contractAddr))
+	require.Equal(t, counterRuntimeBytecode, _app.GetCode(contractAddr))
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(5)).Bytes(), _app.GetStorageAt(contractAddr, make([]byte, 32)))
+	require.Equal(t, big.NewInt(200), _app.GetBalance(staking.StakingContractAddress))
+
+	// call counter()
+	statusCode, _, retData := _app.Call(addr, contractAddr, testutils.HexToBytes("0x61bc221a"))
+	require.Equal(t, 0, statusCode)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(5)).Bytes(), retData)
+
+	// call update(2)
+	tx, _ := _app.MakeAndExecTxInBlock(key, contractAddr, 0,
+		testutils.HexToBytes("0x6299a6ef0000000000000000000000000000000000000000000000000000000000000002"))
+	_app.WaitMS(100)
+	_app.EnsureTxSuccess(tx.Hash())
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(7)).Bytes(), _app.GetStorageAt(contractAddr, make([]byte, 32)))
+
+	// the staking info is kept after the staking contract's balance is set
+	genesisData, _, err := _app.ExportState()
+	require.NoError(t, err)
+	require.Len(t, genesisData.StakingInfo.Validators, 1)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(7)), genesisData.Alloc[contractAddr].Storage[gethcmn.Hash{}])
+}
+
+func TestGenesisContract_invalid(t *testing.T) {
+	contractAddr := gethcmn.HexToAddress("0x8888888888888888888888888888888888888888")
+	require.PanicsWithError(t, "genesis account "+contractAddr.Hex()+" has storage but no code", func() {
+		testutils.CreateTestAppWithAlloc(gethcore.GenesisAlloc{
+			contractAddr: {
+				Balance: big.NewInt(0),
+				Storage: map[gethcmn.Hash]gethcmn.Hash{{}: {1}},
+			},
+		})
+	})
+	stakingAddr := gethcmn.Address(staking.StakingContractAddress)
+	require.PanicsWithError(t, "genesis account "+stakingAddr.Hex()+" is a system contract, its code and storage can not be set", func() {
+		testutils.CreateTestAppWithAlloc(gethcore.GenesisAlloc{
+			stakingAddr: {Balance: big.NewInt(0), Code: counterRuntimeBytecode},
+		})
+	})
+}
+
+func TestGenesisVersion(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppWithGenesisVersion(app.GenesisVersionLegacy, key)
+	// the accounts of the existing chains keep the zero sequences
+	require.Equal(t, uint64(0), _app.GetRunTxContext().GetAccount(addr).Sequence())
+	_app.Destroy()
+
+	_app = testutils.CreateTestAppWithGenesisVersion(app.CurrGenesisVersion, key)
+	require.Equal(t, uint64(math.MaxUint64), _app.GetRunTxContext().GetAccount(addr).Sequence())
+	_app.Destroy()
+
+	require.PanicsWithError(t, "unsupported genesis version 2", func() {
+		testutils.CreateTestAppWithGenesisVersion(app.CurrGenesisVersion + 1)
+	})
+}
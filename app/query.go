@@ -0,0 +1,160 @@
+package app
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/staking"
+)
+
+// The paths served by App.Query, which can be accessed through tendermint's abci_query
+const (
+	QueryPathAccount     = "/account/"     // "/account/<addr>", returns AccountQueryResult in JSON
+	QueryPathStorage     = "/storage/"     // "/storage/<addr>/<key>", returns the raw value of the storage slot
+	QueryPathCode        = "/code/"        // "/code/<addr>", returns the raw bytecode
+	QueryPathStakingInfo = "/staking/info" // returns stakingtypes.StakingInfo in msgp
+	QueryPathMinGasPrice = "/mingasprice"  // returns MinGasPriceQueryResult in JSON
+)
+
+type AccountQueryResult struct {
+	Address  gethcmn.Address `json:"address"`
+	Balance  string          `json:"balance"` // in decimal
+	Nonce    uint64          `json:"nonce"`
+	Sequence uint64          `json:"sequence"`
+}
+
+type MinGasPriceQueryResult struct {
+	MinGasPrice     uint64 `json:"min_gas_price"`      // the value which can be changed by validators in this block
+	LastMinGasPrice uint64 `json:"last_min_gas_price"` // the value used by CheckTx
+}
+
+func (app *App) Query(req abcitypes.RequestQuery) abcitypes.ResponseQuery {
+	height := app.committedHeight()
+	ctx := app.GetRpcContext()
+	if req.Height != 0 && req.Height != height {
+		ctx.Close(false)
+		if req.Height > height || app.archive == nil {
+			return abcitypes.ResponseQuery{Code: HeightNotAvailable,
+				Log: fmt.Sprintf("height %d is not available, the latest height is %d", req.Height, height)}
+		}
+		var err error
+		if ctx, err = app.GetRpcContextAtHeight(req.Height); err != nil {
+			return abcitypes.ResponseQuery{Code: HeightNotAvailable, Log: err.Error()}
+		}
+		height = req.Height
+	}
+	defer ctx.Close(false)
+
+	var res abcitypes.ResponseQuery
+	switch {
+	case strings.HasPrefix(req.Path, QueryPathAccount):
+		res = queryAccount(ctx, strings.TrimPrefix(req.Path, QueryPathAccount))
+	case strings.HasPrefix(req.Path, QueryPathStorage):
+		res = queryStorage(ctx, strings.TrimPrefix(req.Path, QueryPathStorage))
+	case strings.HasPrefix(req.Path, QueryPathCode):
+		res = queryCode(ctx, strings.TrimPrefix(req.Path, QueryPathCode))
+	case req.Path == QueryPathStakingInfo:
+		res = queryStakingInfo(ctx)
+	case req.Path == QueryPathMinGasPrice:
+		res = queryMinGasPrice(ctx)
+	default:
+		res = abcitypes.ResponseQuery{Code: UnknownQueryPath, Log: "unknown query path: " + req.Path}
+	}
+	res.Height = height
+	return res
+}
+
+func parseAddress(s string) (addr gethcmn.Address, ok bool) {
+	if !gethcmn.IsHexAddress(s) {
+		return
+	}
+	return gethcmn.HexToAddress(s), true
+}
+
+func queryAccount(ctx *types.Context, param string) abcitypes.ResponseQuery {
+	addr, ok := parseAddress(param)
+	if !ok {
+		return abcitypes.ResponseQuery{Code: InvalidQueryParam, Log: "invalid address: " + param}
+	}
+	acc := ctx.GetAccount(addr)
+	if acc == nil {
+		return abcitypes.ResponseQuery{Code: QueryTargetNotFound, Log: "account not found"}
+	}
+	bz, _ := json.Marshal(AccountQueryResult{
+		Address:  addr,
+		Balance:  acc.Balance().ToBig().String(),
+		Nonce:    acc.Nonce(),
+		Sequence: acc.Sequence(),
+	})
+	return abcitypes.ResponseQuery{Code: abcitypes.CodeTypeOK, Key: addr[:], Value: bz}
+}
+
+func queryStorage(ctx *types.Context, param string) abcitypes.ResponseQuery {
+	params := strings.Split(param, "/")
+	if len(params) != 2 {
+		return abcitypes.ResponseQuery{Code: InvalidQueryParam, Log: "usage: " + QueryPathStorage + "<addr>/<key>"}
+	}
+	addr, ok := parseAddress(params[0])
+	if !ok {
+		return abcitypes.ResponseQuery{Code: InvalidQueryParam, Log: "invalid address: " + params[0]}
+	}
+	key, err := decodeStorageKey(params[1])
+	if err != nil {
+		return abcitypes.ResponseQuery{Code: InvalidQueryParam, Log: "invalid key: " + params[1]}
+	}
+	acc := ctx.GetAccount(addr)
+	if acc == nil {
+		return abcitypes.ResponseQuery{Code: QueryTargetNotFound, Log: "account not found"}
+	}
+	value := ctx.GetStorageAt(acc.Sequence(), string(key[:]))
+	return abcitypes.ResponseQuery{Code: abcitypes.CodeTypeOK, Key: key[:], Value: value}
+}
+
+// The key must be a hex string of at most 32 bytes, and it is left-padded to 32 bytes
+func decodeStorageKey(s string) (key gethcmn.Hash, err error) {
+	bz, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return
+	}
+	if len(bz) > len(key) {
+		return key, errors.New("key is longer than 32 bytes")
+	}
+	return gethcmn.BytesToHash(bz), nil
+}
+
+func queryCode(ctx *types.Context, param string) abcitypes.ResponseQuery {
+	addr, ok := parseAddress(param)
+	if !ok {
+		return abcitypes.ResponseQuery{Code: InvalidQueryParam, Log: "invalid address: " + param}
+	}
+	res := abcitypes.ResponseQuery{Code: abcitypes.CodeTypeOK, Key: addr[:]}
+	if codeInfo := ctx.GetCode(addr); codeInfo != nil {
+		res.Value = codeInfo.BytecodeSlice()
+	}
+	return res
+}
+
+func queryStakingInfo(ctx *types.Context) abcitypes.ResponseQuery {
+	_, info := staking.LoadStakingAcc(ctx)
+	bz, err := info.MarshalMsg(nil)
+	if err != nil {
+		panic(err)
+	}
+	return abcitypes.ResponseQuery{Code: abcitypes.CodeTypeOK, Value: bz}
+}
+
+func queryMinGasPrice(ctx *types.Context) abcitypes.ResponseQuery {
+	bz, _ := json.Marshal(MinGasPriceQueryResult{
+		MinGasPrice:     staking.LoadMinGasPrice(ctx, false),
+		LastMinGasPrice: staking.LoadMinGasPrice(ctx, true),
+	})
+	return abcitypes.ResponseQuery{Code: abcitypes.CodeTypeOK, Value: bz}
+}
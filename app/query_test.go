@@ -0,0 +1,85 @@
+package app_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/staking"
+	stakingtypes "github.com/smartbch/smartbch/staking/types"
+)
+
+func TestQuery(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	defer _app.Destroy()
+
+	// see testdata/counter/contracts/Counter.sol
+	creationBytecode := testutils.HexToBytes(`
+608060405234801561001057600080fd5b5060cc8061001f6000396000f3fe60
+80604052348015600f57600080fd5b506004361060325760003560e01c806361
+bc221a1460375780636299a6ef146053575b600080fd5b603d607e565b604051
+8082815260200191505060405180910390f35b607c6004803603602081101560
+6757600080fd5b81019080803590602001909291905050506084565b005b6000
+5481565b8060008082825401925050819055505056fea2646970667358221220
+37865cfcfd438966956583c78d31220c05c0f1ebfd116aced883214fcb1096c6
+64736f6c634300060c0033
+`)
+	_, _, contractAddr := _app.DeployContractInBlock(key, creationBytecode)
+	_, h := _app.MakeAndExecTxInBlock(key, contractAddr, 0,
+		testutils.HexToBytes("0x6299a6ef0000000000000000000000000000000000000000000000000000000000000007"))
+	_app.WaitMS(100)
+
+	query := func(path string) abci.ResponseQuery {
+		return _app.Query(abci.RequestQuery{Path: path})
+	}
+
+	res := query("/account/" + addr.Hex())
+	require.Equal(t, abci.CodeTypeOK, res.Code)
+	require.Equal(t, h+1, res.Height)
+	var accRes app.AccountQueryResult
+	require.NoError(t, json.Unmarshal(res.Value, &accRes))
+	require.Equal(t, addr, accRes.Address)
+	require.Equal(t, _app.GetBalance(addr).String(), accRes.Balance)
+	require.Equal(t, uint64(2), accRes.Nonce)
+
+	_, addr2 := testutils.GenKeyAndAddr()
+	require.Equal(t, app.QueryTargetNotFound, query("/account/"+addr2.Hex()).Code)
+	require.Equal(t, app.InvalidQueryParam, query("/account/0x1234").Code)
+
+	res = query("/storage/" + contractAddr.Hex() + "/0x00")
+	require.Equal(t, abci.CodeTypeOK, res.Code)
+	require.Equal(t, gethcmn.BigToHash(big.NewInt(7)).Bytes(), res.Value)
+	require.Equal(t, app.InvalidQueryParam, query("/storage/"+contractAddr.Hex()).Code)
+	require.Equal(t, app.InvalidQueryParam, query("/storage/"+contractAddr.Hex()+"/0xzz").Code)
+
+	res = query("/code/" + contractAddr.Hex())
+	require.Equal(t, abci.CodeTypeOK, res.Code)
+	require.Equal(t, _app.GetCode(contractAddr), res.Value)
+
+	res = query("/staking/info")
+	require.Equal(t, abci.CodeTypeOK, res.Code)
+	var info stakingtypes.StakingInfo
+	_, err := info.UnmarshalMsg(res.Value)
+	require.NoError(t, err)
+	require.Len(t, info.Validators, 1)
+	require.Equal(t, _app.TestPubkey.Bytes(), info.Validators[0].Pubkey[:])
+
+	res = query("/mingasprice")
+	require.Equal(t, abci.CodeTypeOK, res.Code)
+	var gpRes app.MinGasPriceQueryResult
+	require.NoError(t, json.Unmarshal(res.Value, &gpRes))
+	require.Equal(t, staking.DefaultMinGasPrice, gpRes.MinGasPrice)
+	require.Equal(t, staking.DefaultMinGasPrice, gpRes.LastMinGasPrice)
+
+	require.Equal(t, app.UnknownQueryPath, query("/foo").Code)
+	res = _app.Query(abci.RequestQuery{Path: "/mingasprice", Height: 1})
+	require.Equal(t, app.HeightNotAvailable, res.Code)
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"encoding/binary"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/internal/ethutils"
+)
+
+// types.Block has no field for the receipts root, so the root of each committed block is kept in the db of the
+// bloombits index, under its own key. The blocks which have no root there (for example, the ones restored from
+// a snapshot) get their roots recomputed from the transactions in the history store.
+
+const receiptsRootPrefix = byte('r')
+
+func receiptsRootKey(height int64) []byte {
+	key := make([]byte, 1+8)
+	key[0] = receiptsRootPrefix
+	binary.BigEndian.PutUint64(key[1:], uint64(height))
+	return key
+}
+
+func (bbs *bloomBitsStore) setReceiptsRoot(height int64, root gethcmn.Hash) {
+	if err := bbs.db.Set(receiptsRootKey(height), root[:]); err != nil {
+		panic(err)
+	}
+}
+
+func (bbs *bloomBitsStore) getReceiptsRoot(height int64) (gethcmn.Hash, bool) {
+	bz, err := bbs.db.Get(receiptsRootKey(height))
+	if err != nil || len(bz) != gethcmn.HashLength {
+		return gethcmn.Hash{}, false
+	}
+	return gethcmn.BytesToHash(bz), true
+}
+
+// GetReceiptsRoot returns the receipts root of the block at 'height', or a zero hash if the block is not found
+func (app *App) GetReceiptsRoot(height int64) gethcmn.Hash {
+	if root, ok := app.bloomBits.getReceiptsRoot(height); ok {
+		return root
+	}
+	if app.historyStore.GetBlockByHeight(height) == nil {
+		return gethcmn.Hash{}
+	}
+	txList := app.historyStore.GetTxListByHeight(height)
+	receipts := make(gethtypes.Receipts, len(txList))
+	for i, txContent := range txList {
+		tx := &types.Transaction{}
+		if _, err := tx.UnmarshalMsg(txContent); err != nil {
+			return gethcmn.Hash{}
+		}
+		receipts[i] = ethutils.ToGethReceipt(tx)
+	}
+	root, _ := ethutils.ReceiptsRootAndBloom(receipts)
+	return root
+}
@@ -0,0 +1,800 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/smartbch/moeingads/indextree"
+	"github.com/smartbch/moeingads/metadb"
+	"github.com/smartbch/moeingads/store"
+	"github.com/smartbch/moeingads/store/rabbit"
+	modbtypes "github.com/smartbch/moeingdb/types"
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/internal/ethutils"
+	"github.com/smartbch/smartbch/param"
+	"github.com/smartbch/smartbch/staking"
+)
+
+// A state-sync snapshot taken at height H is a tar archive which contains all the files of MoeingADS
+// (under "app/") and the latest blocks in MoDB (under "modb/"). The archive is split into fixed-size
+// chunks, and the snapshot's hash is the sha256 hash of all the chunks' sha256 hashes.
+// MoeingADS's root hash depends on the history of its entries, so its files must be copied as they are,
+// instead of replaying the key-value pairs. Archiving and hashing them takes long, so Commit only stages
+// them: the files are hard-linked into a staging directory and their sizes are recorded, which is cheap.
+// The data files of MoeingADS are append-only and rocksdb never modifies a file in place, so the first
+// 'size' bytes of a linked file stay unchanged. But rocksdb's background flushing and compaction can
+// replace its files at any time, so its small metadata files are copied instead, and the staging is
+// retried if its MANIFEST files changed meanwhile. The archive is built from the staging directory in
+// a background goroutine.
+
+const (
+	SnapshotFormat uint32 = 1
+
+	snapshotChunkSize     = 4 * 1024 * 1024
+	snapshotHistoryBlocks = 256 // the BLOCKHASH opcode can access the latest 256 blocks
+	snapshotMetadataFile  = "metadata.json"
+	snapshotRestoringDir  = "restoring"
+	snapshotStagingSuffix = ".staging"
+	snapshotStagingTries  = 3
+	snapshotAppPrefix     = "app/"
+	snapshotModbPrefix    = "modb/"
+)
+
+// The content of abcitypes.Snapshot.Metadata
+type snapshotMetadata struct {
+	ChunkHashes [][]byte `json:"chunk_hashes"`
+}
+
+// snapshotStore manages the snapshots saved on disk. Each snapshot has its own directory named
+// after its height, which contains the chunks (named after their indexes) and a metadata file.
+type snapshotStore struct {
+	dir        string
+	interval   int64
+	keepRecent int
+
+	busy int32          // set when a snapshot is being built in background
+	wg   sync.WaitGroup // waits for the background goroutine
+}
+
+func newSnapshotStore(config *param.ChainConfig) *snapshotStore {
+	return &snapshotStore{
+		dir:        config.SnapshotDataPath,
+		interval:   config.SnapshotInterval,
+		keepRecent: config.SnapshotKeepRecent,
+	}
+}
+
+func (ss *snapshotStore) shouldTake(height int64) bool {
+	return ss.interval > 0 && height > 0 && height%ss.interval == 0
+}
+
+func (ss *snapshotStore) heightDir(height uint64) string {
+	return filepath.Join(ss.dir, strconv.FormatUint(height, 10))
+}
+
+// Create a new snapshot at 'height', whose content is written by 'fill'
+func (ss *snapshotStore) create(height int64, fill func(tw *tar.Writer) error) (err error) {
+	finalDir := ss.heightDir(uint64(height))
+	tmpDir := finalDir + ".tmp"
+	_ = os.RemoveAll(tmpDir)
+	if err = os.MkdirAll(tmpDir, 0700); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(tmpDir)
+		}
+	}()
+
+	cw := &chunkWriter{dir: tmpDir}
+	tw := tar.NewWriter(cw)
+	if err = fill(tw); err != nil {
+		return err
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = cw.Close(); err != nil {
+		return err
+	}
+	metadata, err := json.Marshal(snapshotMetadata{ChunkHashes: cw.hashes})
+	if err != nil {
+		return err
+	}
+	snapshot := abcitypes.Snapshot{
+		Height:   uint64(height),
+		Format:   SnapshotFormat,
+		Chunks:   uint32(len(cw.hashes)),
+		Hash:     hashOfChunkHashes(cw.hashes),
+		Metadata: metadata,
+	}
+	bz, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(tmpDir, snapshotMetadataFile), bz, 0600); err != nil {
+		return err
+	}
+	_ = os.RemoveAll(finalDir)
+	return os.Rename(tmpDir, finalDir)
+}
+
+// List the complete snapshots, the latest one comes first
+func (ss *snapshotStore) list() ([]*abcitypes.Snapshot, error) {
+	fileInfoList, err := ioutil.ReadDir(ss.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	res := make([]*abcitypes.Snapshot, 0, len(fileInfoList))
+	for _, fileInfo := range fileInfoList {
+		height, err := strconv.ParseUint(fileInfo.Name(), 10, 64)
+		if err != nil || !fileInfo.IsDir() {
+			continue // not a snapshot directory
+		}
+		bz, err := ioutil.ReadFile(filepath.Join(ss.heightDir(height), snapshotMetadataFile))
+		if err != nil {
+			continue
+		}
+		snapshot := &abcitypes.Snapshot{}
+		if err = json.Unmarshal(bz, snapshot); err != nil {
+			continue // a corrupt snapshot must not hide the others
+		}
+		res = append(res, snapshot)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Height > res[j].Height
+	})
+	return res, nil
+}
+
+// Wait for the snapshot being built in background
+func (ss *snapshotStore) wait() {
+	ss.wg.Wait()
+}
+
+func (ss *snapshotStore) loadChunk(height uint64, format uint32, chunk uint32) ([]byte, error) {
+	if format != SnapshotFormat {
+		return nil, fmt.Errorf("unknown snapshot format %d", format)
+	}
+	return ioutil.ReadFile(filepath.Join(ss.heightDir(height), strconv.FormatUint(uint64(chunk), 10)))
+}
+
+// Delete the old snapshots, only keep the latest 'keepRecent' ones
+func (ss *snapshotStore) prune() error {
+	snapshots, err := ss.list()
+	if err != nil {
+		return err
+	}
+	for i := ss.keepRecent; i < len(snapshots); i++ {
+		if err = os.RemoveAll(ss.heightDir(snapshots[i].Height)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashOfChunkHashes(chunkHashes [][]byte) []byte {
+	h := sha256.New()
+	for _, chunkHash := range chunkHashes {
+		h.Write(chunkHash)
+	}
+	return h.Sum(nil)
+}
+
+// chunkWriter splits the written bytes into files of snapshotChunkSize bytes, and records their hashes
+type chunkWriter struct {
+	dir    string
+	file   *os.File
+	hasher hash.Hash
+	size   int
+	hashes [][]byte
+}
+
+func (cw *chunkWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if cw.file == nil {
+			fname := filepath.Join(cw.dir, strconv.Itoa(len(cw.hashes)))
+			cw.file, err = os.OpenFile(fname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return
+			}
+			cw.hasher = sha256.New()
+			cw.size = 0
+		}
+		m := len(p)
+		if m > snapshotChunkSize-cw.size {
+			m = snapshotChunkSize - cw.size
+		}
+		if _, err = cw.file.Write(p[:m]); err != nil {
+			return
+		}
+		cw.hasher.Write(p[:m])
+		cw.size += m
+		n += m
+		p = p[m:]
+		if cw.size == snapshotChunkSize {
+			if err = cw.closeChunk(); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (cw *chunkWriter) closeChunk() error {
+	cw.hashes = append(cw.hashes, cw.hasher.Sum(nil))
+	err := cw.file.Close()
+	cw.file = nil
+	return err
+}
+
+func (cw *chunkWriter) Close() error {
+	if cw.file == nil {
+		return nil
+	}
+	return cw.closeChunk()
+}
+
+// A file of MoeingADS staged for a snapshot, only its first 'size' bytes belong to the snapshot
+type stagedFile struct {
+	name string // the slash-separated path relative to the staging directory
+	size int64
+}
+
+// Stage the regular files under 'src' into 'dst' and return them. The rocksdb metadata files are copied,
+// and the other files are hard-linked, or copied when they cannot be linked.
+func stageDir(src, dst string) (files []stagedFile, err error) {
+	err = filepath.Walk(src, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, fpath)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if err = os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		size := info.Size()
+		if isRocksDBMetaFile(info.Name()) || os.Link(fpath, target) != nil {
+			if size, err = copyFile(fpath, target); err != nil {
+				return err
+			}
+		}
+		files = append(files, stagedFile{name: filepath.ToSlash(rel), size: size})
+		return nil
+	})
+	return
+}
+
+// rocksdb's sst files are immutable, but its MANIFEST, CURRENT and OPTIONS files are replaced or appended
+// by its background jobs
+func isRocksDBMetaFile(name string) bool {
+	return name == "CURRENT" || strings.HasPrefix(name, "MANIFEST-") || strings.HasPrefix(name, "OPTIONS-")
+}
+
+// Returns the names and sizes of rocksdb's MANIFEST files under 'dir', which change when rocksdb's files change
+func rocksDBManifests(dir string) (string, error) {
+	var buf strings.Builder
+	err := filepath.Walk(dir, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), "MANIFEST-") {
+			fmt.Fprintf(&buf, "%s:%d;", fpath, info.Size())
+		}
+		return nil
+	})
+	return buf.String(), err
+}
+
+func copyFile(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// Add the staged files under 'dir' into the tar archive, with 'prefix' prepended to their names
+func addStagedFilesToTar(tw *tar.Writer, dir string, files []stagedFile, prefix string) error {
+	for _, file := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     prefix + file.name,
+			Size:     file.size,
+			Mode:     0600,
+		})
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(file.name)))
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(tw, f, file.size)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addBytesToTar(tw *tar.Writer, name string, bz []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(bz)),
+		Mode:     0600,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(bz)
+	return err
+}
+
+// Stage MoeingADS's files for a snapshot at 'height' and return the staging directory and the staged files.
+// It must be called with app.mtx held, when MoeingADS is not being written.
+func (app *App) stageSnapshot(height int64) (dir string, files []stagedFile, err error) {
+	dir = app.snapshots.heightDir(uint64(height)) + snapshotStagingSuffix
+	for i := 0; i < snapshotStagingTries; i++ {
+		_ = os.RemoveAll(dir)
+		var before, after string
+		if before, err = rocksDBManifests(app.config.AppDataPath); err != nil {
+			break
+		}
+		if files, err = stageDir(app.config.AppDataPath, dir); err != nil {
+			break
+		}
+		if after, err = rocksDBManifests(app.config.AppDataPath); err != nil {
+			break
+		}
+		if before == after {
+			return dir, files, nil
+		}
+		err = errors.New("rocksdb's files kept changing during staging")
+	}
+	_ = os.RemoveAll(dir)
+	return "", nil, err
+}
+
+// Take a snapshot at current height. It must be called with app.mtx held. Only the staging is done here,
+// and the snapshot is built in background. If the previous snapshot is still being built, this one is skipped.
+func (app *App) takeSnapshot() {
+	height := app.currHeight
+	if !atomic.CompareAndSwapInt32(&app.snapshots.busy, 0, 1) {
+		app.logger.Error("skip snapshot because the previous one is still being built", "height", height)
+		return
+	}
+	stagingDir, files, err := app.stageSnapshot(height)
+	if err != nil {
+		atomic.StoreInt32(&app.snapshots.busy, 0)
+		app.logger.Error("failed to stage snapshot", "height", height, "err", err.Error())
+		return
+	}
+	app.snapshots.wg.Add(1)
+	go func() {
+		defer app.snapshots.wg.Done()
+		defer atomic.StoreInt32(&app.snapshots.busy, 0)
+		defer os.RemoveAll(stagingDir)
+		app.buildSnapshot(height, stagingDir, files)
+	}()
+}
+
+func (app *App) buildSnapshot(height int64, stagingDir string, files []stagedFile) {
+	err := app.snapshots.create(height, func(tw *tar.Writer) error {
+		if err := addStagedFilesToTar(tw, stagingDir, files, snapshotAppPrefix); err != nil {
+			return err
+		}
+		// the block at 'height' is not in MoDB yet, it will be added during the next Commit
+		start := height - snapshotHistoryBlocks
+		if start < 1 {
+			start = 1
+		}
+		for h := start; h < height; h++ {
+			blk, err := app.loadHistoryBlock(h)
+			if err != nil {
+				return err
+			}
+			if blk == nil {
+				continue
+			}
+			bz, err := blk.MarshalMsg(nil)
+			if err != nil {
+				return err
+			}
+			if err = addBytesToTar(tw, snapshotModbPrefix+strconv.FormatInt(h, 10), bz); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		app.logger.Error("failed to take snapshot", "height", height, "err", err.Error())
+		return
+	}
+	app.logger.Info("snapshot taken", "height", height)
+	if err = app.snapshots.prune(); err != nil {
+		app.logger.Error("failed to prune snapshots", "err", err.Error())
+	}
+}
+
+// Reassemble a block in MoDB, which can be added to another MoDB
+func (app *App) loadHistoryBlock(height int64) (*modbtypes.Block, error) {
+	blkInfo := app.historyStore.GetBlockByHeight(height)
+	if blkInfo == nil {
+		return nil, nil
+	}
+	var b types.Block
+	if _, err := b.UnmarshalMsg(blkInfo); err != nil {
+		return nil, err
+	}
+	blk := &modbtypes.Block{
+		Height:    height,
+		BlockHash: b.Hash,
+		BlockInfo: blkInfo,
+	}
+	for _, content := range app.historyStore.GetTxListByHeight(height) {
+		tx := &types.Transaction{}
+		if _, err := tx.UnmarshalMsg(content); err != nil {
+			return nil, err
+		}
+		blk.TxList = append(blk.TxList, toMoDBTx(tx, content))
+	}
+	return blk, nil
+}
+
+// The snapshot being restored from
+type snapshotRestore struct {
+	snapshot    *abcitypes.Snapshot
+	appHash     []byte
+	chunkHashes [][]byte
+	applied     []bool
+	dir         string
+}
+
+func (r *snapshotRestore) done() bool {
+	for _, applied := range r.applied {
+		if !applied {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *snapshotRestore) chunkFile(index int) string {
+	return filepath.Join(r.dir, strconv.Itoa(index))
+}
+
+func (app *App) offerSnapshot(snapshot *abcitypes.Snapshot, appHash []byte) abcitypes.ResponseOfferSnapshot_Result {
+	if snapshot == nil {
+		return abcitypes.ResponseOfferSnapshot_REJECT
+	}
+	if snapshot.Format != SnapshotFormat {
+		return abcitypes.ResponseOfferSnapshot_REJECT_FORMAT
+	}
+	var metadata snapshotMetadata
+	err := json.Unmarshal(snapshot.Metadata, &metadata)
+	if err != nil || len(metadata.ChunkHashes) != int(snapshot.Chunks) || len(metadata.ChunkHashes) == 0 ||
+		!bytes.Equal(hashOfChunkHashes(metadata.ChunkHashes), snapshot.Hash) {
+		return abcitypes.ResponseOfferSnapshot_REJECT
+	}
+	dir := filepath.Join(app.snapshots.dir, snapshotRestoringDir)
+	_ = os.RemoveAll(dir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		app.logger.Error("cannot create directory for restoring", "err", err.Error())
+		return abcitypes.ResponseOfferSnapshot_ABORT
+	}
+	app.restore = &snapshotRestore{
+		snapshot:    snapshot,
+		appHash:     append([]byte{}, appHash...),
+		chunkHashes: metadata.ChunkHashes,
+		applied:     make([]bool, len(metadata.ChunkHashes)),
+		dir:         dir,
+	}
+	return abcitypes.ResponseOfferSnapshot_ACCEPT
+}
+
+func (app *App) applySnapshotChunk(req abcitypes.RequestApplySnapshotChunk) abcitypes.ResponseApplySnapshotChunk {
+	r := app.restore
+	if r == nil {
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ABORT}
+	}
+	index := int(req.Index)
+	if index >= len(r.chunkHashes) {
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_REJECT_SNAPSHOT}
+	}
+	chunkHash := sha256.Sum256(req.Chunk)
+	if !bytes.Equal(chunkHash[:], r.chunkHashes[index]) {
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result:        abcitypes.ResponseApplySnapshotChunk_RETRY,
+			RefetchChunks: []uint32{req.Index},
+			RejectSenders: []string{req.Sender},
+		}
+	}
+	if err := ioutil.WriteFile(r.chunkFile(index), req.Chunk, 0600); err != nil {
+		app.logger.Error("cannot save snapshot chunk", "index", index, "err", err.Error())
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ABORT}
+	}
+	r.applied[index] = true
+	if !r.done() {
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ACCEPT}
+	}
+	app.restore = nil
+	defer os.RemoveAll(r.dir)
+	if err := app.restoreFromChunks(r); err != nil {
+		app.logger.Error("failed to restore from snapshot", "height", r.snapshot.Height, "err", err.Error())
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_REJECT_SNAPSHOT}
+	}
+	app.logger.Info("restored from snapshot", "height", r.snapshot.Height)
+	return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ACCEPT}
+}
+
+// Replace MoeingADS's files with the ones in the snapshot, add the blocks in the snapshot into MoDB,
+// and then reload the app's state just like NewApp does. The snapshot is extracted into a staging directory
+// and verified there, so the app's stores are not touched if it is bad.
+func (app *App) restoreFromChunks(r *snapshotRestore) error {
+	readers := make([]io.Reader, len(r.chunkHashes))
+	for i := range r.chunkHashes {
+		f, err := os.Open(r.chunkFile(i))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		readers[i] = f
+	}
+	appDir := filepath.Clean(app.config.AppDataPath)
+	stagingDir := appDir + ".restoring"
+	_ = os.RemoveAll(stagingDir)
+	defer os.RemoveAll(stagingDir)
+	var blocks []*modbtypes.Block
+	tr := tar.NewReader(io.MultiReader(readers...))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(hdr.Name, snapshotAppPrefix):
+			err = extractFile(tr, stagingDir, strings.TrimPrefix(hdr.Name, snapshotAppPrefix))
+		case strings.HasPrefix(hdr.Name, snapshotModbPrefix):
+			blk := &modbtypes.Block{}
+			var bz []byte
+			if bz, err = ioutil.ReadAll(tr); err == nil {
+				_, err = blk.UnmarshalMsg(bz)
+				blocks = append(blocks, blk)
+			}
+		default:
+			err = fmt.Errorf("unknown file in snapshot: %s", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	currBlock, err := verifyRestoredADS(stagingDir, r.appHash)
+	if err != nil {
+		return err
+	}
+	if uint64(currBlock.Number) != r.snapshot.Height {
+		return errors.New("block height mismatch")
+	}
+	restoredBlocks, err := rebuildSnapshotBlocks(blocks, currBlock.Number, currBlock.ParentHash)
+	if err != nil {
+		return err
+	}
+
+	app.closeStores()
+	if err = replaceDir(appDir, stagingDir); err != nil {
+		app.openStores()
+		app.txEngine.SetContext(app.GetRunTxContext())
+		return err
+	}
+
+	app.openStores()
+	for _, blk := range restoredBlocks {
+		app.historyStore.AddBlock(blk.block, -1)
+		app.bloomBits.add(blk.block.Height, blk.bloom)
+		app.bloomBits.setReceiptsRoot(blk.block.Height, blk.receiptsRoot)
+	}
+	ctx := app.GetRunTxContext()
+	app.block = ctx.GetCurrBlockBasicInfo()
+	atomic.StoreInt64(&app.currHeight, app.block.Number)
+	_, stakingInfo := staking.LoadStakingAcc(ctx)
+	app.currValidators = stakingInfo.GetActiveValidators(staking.MinimumStakingAmount)
+	app.lastMinGasPrice = staking.LoadMinGasPrice(ctx, true)
+	ctx.Close(false)
+	if app.archive != nil {
+		// the archived states before the snapshot are unknown
+		if err := app.archive.reset(archivedHeight(app.currHeight)); err != nil {
+			return err
+		}
+	}
+	app.root.SetHeight(app.currHeight + 1)
+	app.reload()
+	return nil
+}
+
+// Close the stores of MoeingADS and the contexts using them, they can be opened again by openStores
+func (app *App) closeStores() {
+	app.checkTrunk.Close(false)
+	app.txEngine.Context().Close(false)
+	app.trunk.Close(false)
+	if app.archive != nil {
+		app.archive.discard()
+	}
+	app.root.Close()
+}
+
+// Open the stores of MoeingADS for the block after currHeight, as NewApp does
+func (app *App) openStores() {
+	app.root, app.mads = createRootStore(app.config)
+	app.trunk = app.root.GetTrunkStore().(*store.TrunkStore)
+	app.checkTrunk = app.root.GetReadOnlyTrunkStore().(*store.TrunkStore)
+	app.root.SetHeight(app.currHeight + 1)
+}
+
+// Replace 'dir' with 'newDir', 'dir' is kept unchanged if it fails
+func replaceDir(dir, newDir string) error {
+	oldDir := dir + ".old"
+	_ = os.RemoveAll(oldDir)
+	if err := os.Rename(dir, oldDir); err != nil {
+		return err
+	}
+	if err := os.Rename(newDir, dir); err != nil {
+		if renameErr := os.Rename(oldDir, dir); renameErr != nil {
+			panic(renameErr) // the original files are left in oldDir
+		}
+		return err
+	}
+	_ = os.RemoveAll(oldDir)
+	return nil
+}
+
+// Open MoeingADS restored into 'dir' and check its root hash, then returns its current block.
+// MoeingADS only records the root hash in its rocksdb, so it is marked as not closed properly before opening,
+// to make it recover the merkle tree from the entry files and panic if the recovered root hash differs from
+// the recorded one. Its index of the keys is not in rocksdb, it is always rebuilt from the entry files
+// because the history is not queried. Closing it writes the other files of the tree from the recovered one.
+func verifyRestoredADS(dir string, appHash []byte) (blk *types.Block, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot recover the state in snapshot: %v", r)
+		}
+	}()
+	kvdb, err := indextree.NewRocksDB("rocksdb", dir)
+	if err != nil {
+		return nil, err
+	}
+	metadb.NewMetaDB(kvdb).SetIsRunning(true)
+	kvdb.Close()
+
+	root, _ := createRootStoreInDir(dir)
+	defer root.Close()
+	if !bytes.Equal(root.GetRootHash(), appHash) {
+		return nil, errors.New("app hash mismatch")
+	}
+	ctx := types.NewContext(0, nil, nil)
+	rbt := rabbit.NewReadOnlyRabbitStore(root)
+	ctx = ctx.WithRbt(&rbt)
+	defer ctx.Close(false)
+	if blk = ctx.GetCurrBlockBasicInfo(); blk == nil {
+		return nil, errors.New("no block in snapshot")
+	}
+	return blk, nil
+}
+
+// A block restored from a snapshot, with the bloom and the receipts root computed from its txs
+type restoredBlock struct {
+	block        *modbtypes.Block
+	bloom        gethtypes.Bloom
+	receiptsRoot gethcmn.Hash
+}
+
+// Check the blocks in a snapshot taken at 'height', and rebuild them from their block infos and tx contents,
+// instead of trusting the hashes and the indexes in them. They must be the latest ones before 'height' in
+// order. The hash of the block at 'height'-1 is checked against 'parentHash', which is covered by the app hash,
+// and the hashes of the older ones can only be checked to be linked by their parent hashes. The txs must be
+// the ones listed in their blocks, and the blooms are checked against the txs' logs.
+func rebuildSnapshotBlocks(blocks []*modbtypes.Block, height int64, parentHash [32]byte) ([]restoredBlock, error) {
+	res := make([]restoredBlock, len(blocks))
+	for i := len(blocks) - 1; i >= 0; i-- {
+		h := height - int64(len(blocks)-i)
+		var b types.Block
+		if _, err := b.UnmarshalMsg(blocks[i].BlockInfo); err != nil {
+			return nil, err
+		}
+		if blocks[i].Height != h || b.Number != h {
+			return nil, fmt.Errorf("block %d is not the one before %d", blocks[i].Height, h+1)
+		}
+		if b.Hash != parentHash {
+			return nil, fmt.Errorf("hash mismatch of block %d", h)
+		}
+		parentHash = b.ParentHash
+		if len(blocks[i].TxList) != len(b.Transactions) {
+			return nil, fmt.Errorf("tx count mismatch of block %d", h)
+		}
+		blk := &modbtypes.Block{
+			Height:    h,
+			BlockHash: b.Hash,
+			BlockInfo: blocks[i].BlockInfo,
+			TxList:    make([]modbtypes.Tx, len(b.Transactions)),
+		}
+		txs := make([]*types.Transaction, len(b.Transactions))
+		for j, mdbTx := range blocks[i].TxList {
+			tx := &types.Transaction{}
+			if _, err := tx.UnmarshalMsg(mdbTx.Content); err != nil {
+				return nil, err
+			}
+			if tx.Hash != b.Transactions[j] || tx.BlockNumber != h || tx.BlockHash != b.Hash ||
+				tx.TransactionIndex != int64(j) {
+				return nil, fmt.Errorf("tx %d mismatch in block %d", j, h)
+			}
+			txs[j] = tx
+			blk.TxList[j] = toMoDBTx(tx, mdbTx.Content)
+		}
+		receiptsRoot, bloom := ethutils.ReceiptsRootAndBloom(ethutils.ToGethReceipts(txs))
+		if bloom != b.LogsBloom {
+			return nil, fmt.Errorf("logs bloom mismatch of block %d", h)
+		}
+		res[i] = restoredBlock{block: blk, bloom: bloom, receiptsRoot: receiptsRoot}
+	}
+	return res, nil
+}
+
+// Extract a file from the tar reader into 'dir', the file must not escape from 'dir'
+func extractFile(tr *tar.Reader, dir, name string) error {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(target, dir+string(os.PathSeparator)) {
+		return fmt.Errorf("invalid file name in snapshot: %s", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, tr)
+	return err
+}
@@ -0,0 +1,129 @@
+package app_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+
+	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/internal/bigutils"
+	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/param"
+)
+
+const (
+	restoredAdsDir       = "./restoreddbdata"
+	restoredModbDir      = "./restoredmodbdata"
+	restoredSnapshotDir  = "./restoredsnapshotdata"
+	restoredBloomBitsDir = "./restoredbloombitsdata"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	key1, addr1 := testutils.GenKeyAndAddr()
+	_, addr2 := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppWithSnapshots(2, key1)
+	defer _app.Destroy()
+
+	tx, h := _app.MakeAndExecTxInBlock(key1, addr2, 100, nil)
+	_app.WaitMS(100)
+	_app.EnsureTxSuccess(tx.Hash())
+	require.Equal(t, int64(1), h) // the snapshot is taken after the next block
+
+	// the snapshot is built in background
+	var snapshots []*abci.Snapshot
+	require.Eventually(t, func() bool {
+		snapshots = _app.ListSnapshots(abci.RequestListSnapshots{}).Snapshots
+		return len(snapshots) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// a corrupt snapshot is skipped
+	require.NoError(t, os.MkdirAll("./snapshotdata/1", 0700))
+	require.NoError(t, ioutil.WriteFile("./snapshotdata/1/metadata.json", []byte("{"), 0600))
+	snapshots = _app.ListSnapshots(abci.RequestListSnapshots{}).Snapshots
+	require.Len(t, snapshots, 1)
+	snapshot := snapshots[0]
+	require.Equal(t, uint64(2), snapshot.Height)
+	require.Equal(t, app.SnapshotFormat, snapshot.Format)
+	info := _app.Info(abci.RequestInfo{})
+
+	for _, dir := range []string{restoredAdsDir, restoredModbDir, restoredSnapshotDir, restoredBloomBitsDir} {
+		_ = os.RemoveAll(dir)
+	}
+	params := param.DefaultConfig()
+	params.AppDataPath = restoredAdsDir
+	params.ModbDataPath = restoredModbDir
+	params.SnapshotDataPath = restoredSnapshotDir
+	params.BloomBitsDataPath = restoredBloomBitsDir
+	restored := &testutils.TestApp{App: app.NewApp(params, bigutils.NewU256(1), log.NewNopLogger())}
+	defer func() {
+		restored.Stop()
+		for _, dir := range []string{restoredAdsDir, restoredModbDir, restoredSnapshotDir, restoredBloomBitsDir} {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+
+	require.Equal(t, abci.ResponseOfferSnapshot_REJECT_FORMAT, restored.OfferSnapshot(abci.RequestOfferSnapshot{
+		Snapshot: &abci.Snapshot{Height: snapshot.Height, Format: 100},
+		AppHash:  info.LastBlockAppHash,
+	}).Result)
+	loadChunk := func(i uint32) []byte {
+		chunk := _app.LoadSnapshotChunk(abci.RequestLoadSnapshotChunk{
+			Height: snapshot.Height,
+			Format: snapshot.Format,
+			Chunk:  i,
+		}).Chunk
+		require.NotEmpty(t, chunk)
+		return chunk
+	}
+
+	// a snapshot which does not match the trusted app hash is rejected, and the app is kept unchanged
+	require.Equal(t, abci.ResponseOfferSnapshot_ACCEPT, restored.OfferSnapshot(abci.RequestOfferSnapshot{
+		Snapshot: snapshot,
+		AppHash:  append([]byte{1}, info.LastBlockAppHash[1:]...),
+	}).Result)
+	var res abci.ResponseApplySnapshotChunk
+	for i := uint32(0); i < snapshot.Chunks; i++ {
+		res = restored.ApplySnapshotChunk(abci.RequestApplySnapshotChunk{Index: i, Chunk: loadChunk(i)})
+	}
+	require.Equal(t, abci.ResponseApplySnapshotChunk_REJECT_SNAPSHOT, res.Result)
+	require.Equal(t, int64(0), restored.Info(abci.RequestInfo{}).LastBlockHeight)
+	require.Nil(t, restored.GetBalance(addr2))
+
+	require.Equal(t, abci.ResponseOfferSnapshot_ACCEPT, restored.OfferSnapshot(abci.RequestOfferSnapshot{
+		Snapshot: snapshot,
+		AppHash:  info.LastBlockAppHash,
+	}).Result)
+	for i := uint32(0); i < snapshot.Chunks; i++ {
+		chunk := loadChunk(i)
+
+		// a corrupted chunk must be fetched again
+		res = restored.ApplySnapshotChunk(abci.RequestApplySnapshotChunk{
+			Index:  i,
+			Chunk:  append([]byte{1}, chunk...),
+			Sender: "bad",
+		})
+		require.Equal(t, abci.ResponseApplySnapshotChunk_RETRY, res.Result)
+		require.Equal(t, []uint32{i}, res.RefetchChunks)
+		require.Equal(t, []string{"bad"}, res.RejectSenders)
+
+		res = restored.ApplySnapshotChunk(abci.RequestApplySnapshotChunk{
+			Index: i,
+			Chunk: chunk,
+		})
+		require.Equal(t, abci.ResponseApplySnapshotChunk_ACCEPT, res.Result)
+	}
+
+	restoredInfo := restored.Info(abci.RequestInfo{})
+	require.Equal(t, info.LastBlockHeight, restoredInfo.LastBlockHeight)
+	require.Equal(t, info.LastBlockAppHash, restoredInfo.LastBlockAppHash)
+	require.Equal(t, _app.GetBalance(addr1), restored.GetBalance(addr1))
+	require.Equal(t, uint64(100), restored.GetBalance(addr2).Uint64())
+	require.Equal(t, uint64(1), restored.GetNonce(addr1))
+	require.Equal(t, _app.GetBlock(1).Hash, restored.GetBlock(1).Hash)
+}
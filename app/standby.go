@@ -0,0 +1,37 @@
+package app
+
+import (
+	"encoding/binary"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+
+	"github.com/smartbch/moeingevm/types"
+)
+
+// A committed tx which is waiting in the standby queue of txEngine to be executed
+type StandbyTx struct {
+	Hash  gethcmn.Hash
+	From  gethcmn.Address
+	Nonce uint64
+}
+
+// Returns the txs in the standby queue in execution order, and the queue's length reported by txEngine.
+// It waits for postCommit, so the txs and the length are read from the same state.
+func (app *App) GetStandbyTxQueue() (txs []StandbyTx, qLen int) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+
+	ctx := app.txEngine.Context().WithRbtCopy()
+	defer ctx.Close(false)
+	startEnd := ctx.Rbt.Get(types.StandbyTxQueueKey)
+	if startEnd != nil {
+		start, end := binary.BigEndian.Uint64(startEnd[:8]), binary.BigEndian.Uint64(startEnd[8:])
+		txs = make([]StandbyTx, 0, end-start)
+		for i := start; i < end; i++ {
+			var tx types.TxToRun
+			tx.FromBytes(ctx.Rbt.Get(types.GetStandbyTxKey(i)))
+			txs = append(txs, StandbyTx{Hash: tx.HashID, From: tx.From, Nonce: tx.Nonce})
+		}
+	}
+	return txs, app.txEngine.StandbyQLen()
+}
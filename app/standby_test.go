@@ -0,0 +1,50 @@
+package app_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/internal/testutils"
+)
+
+func TestGetStandbyTxQueue(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	defer _app.Destroy()
+
+	// all the txs are executed by postCommit
+	tx, _ := _app.MakeAndExecTxInBlock(key, gethcmn.Address{0x01}, 100, nil)
+	_app.EnsureTxSuccess(tx.Hash())
+	txs, qLen := _app.GetStandbyTxQueue()
+	require.Empty(t, txs)
+	require.Equal(t, 0, qLen)
+
+	// put two txs into the queue as if they were left by postCommit
+	_app.WaitLock()
+	ctx := _app.GetRunTxContext()
+	for i, nonce := range []uint64{5, 6} {
+		txToRun := types.TxToRun{HashID: gethcmn.Hash{0xC0, byte(i)}}
+		txToRun.From = addr
+		txToRun.Nonce = nonce
+		ctx.Rbt.Set(types.GetStandbyTxKey(uint64(10+i)), txToRun.ToBytes())
+	}
+	startEnd := make([]byte, 16)
+	binary.BigEndian.PutUint64(startEnd[:8], 10)
+	binary.BigEndian.PutUint64(startEnd[8:], 12)
+	ctx.Rbt.Set(types.StandbyTxQueueKey, startEnd)
+	ctx.Close(true)
+
+	txs, qLen = _app.GetStandbyTxQueue()
+	require.Equal(t, 2, qLen)
+	require.Equal(t, []app.StandbyTx{
+		{Hash: gethcmn.Hash{0xC0, 0}, From: addr, Nonce: 5},
+		{Hash: gethcmn.Hash{0xC0, 1}, From: addr, Nonce: 6},
+	}, txs)
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"errors"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+
+	"github.com/smartbch/moeingevm/ebp"
+	"github.com/smartbch/moeingevm/types"
+)
+
+// The status strings which ebp's Prepare records for the txs it rejects. Such txs are listed in a block
+// but never executed.
+var notExecutedTxStatus = map[string]bool{
+	"incorrect nonce":                  true,
+	"not enough balance to pay gasfee": true,
+	"invalid signature":                true,
+	"invalid gas price":                true,
+	"invalid gas limit":                true,
+	"non-existent account":             true,
+}
+
+// Re-executes a committed tx for tracing. The txs of block N are executed by postCommit on the state of
+// block N-1, so the txs before the target one are run on that state before running the target one.
+// blk.Transactions is not the order in which the txs were delivered: it is ebp's committedTxs, filled
+// round by round after the txs were shuffled by sender with reorderSeed and the conflicting ones were
+// pushed back to the standby queue. The txs committed in one round touch disjoint keys, so running them
+// one by one in this order gives the same state as the parallel execution. The txs rejected by Prepare
+// are appended after the executed ones without being executed, so they can not be traced.
+// The state of block N-1 is kept only in archive mode, and it is the one before Prepare deducts the gas
+// fees of block N, which the runners for RPC do not charge either.
+func (app *App) ReplayTx(txHash gethcmn.Hash) (*ebp.TxRunner, error) {
+	historyCtx := app.GetHistoryOnlyContext()
+	tx, err := historyCtx.GetTxByHash(txHash)
+	historyCtx.Close(false)
+	if err != nil {
+		return nil, err
+	}
+	if isNotExecutedTx(tx) {
+		return nil, errors.New("the tx was rejected before execution")
+	}
+	if tx.BlockNumber < 1 {
+		return nil, errors.New("the tx is not executed in any block")
+	}
+	if app.archive == nil {
+		return nil, errors.New("the node is not in archive mode")
+	}
+
+	ctx, err := app.GetRpcContextAtHeight(tx.BlockNumber - 1)
+	if err != nil {
+		return nil, err
+	}
+	defer ctx.Close(false)
+	bi, err := app.getBlockInfo(ctx, tx.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	blk, err := ctx.GetBlockByHeight(uint64(tx.BlockNumber))
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range blk.Transactions {
+		blkTx, err := ctx.GetTxByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		runner := &ebp.TxRunner{
+			Ctx: ctx,
+			Tx:  committedTxToRun(blkTx),
+		}
+		ebp.RunTxForRpc(bi, false, runner)
+		if hash == tx.Hash {
+			// the runners for RPC do not count the used gas, which is recorded in history
+			runner.GasUsed = tx.GasUsed
+			return runner, nil
+		}
+	}
+	return nil, errors.New("the tx is not found in its block")
+}
+
+func isNotExecutedTx(tx *types.Transaction) bool {
+	return tx.GasUsed == 0 && notExecutedTxStatus[tx.StatusStr]
+}
+
+func committedTxToRun(tx *types.Transaction) *types.TxToRun {
+	txToRun := &types.TxToRun{
+		HashID: tx.Hash,
+		Height: uint64(tx.BlockNumber),
+	}
+	txToRun.From = tx.From
+	txToRun.To = tx.To
+	txToRun.Nonce = tx.Nonce
+	txToRun.Value = tx.Value
+	txToRun.GasPrice = tx.GasPrice
+	txToRun.Gas = tx.Gas
+	txToRun.Data = tx.Input
+	return txToRun
+}
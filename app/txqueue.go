@@ -0,0 +1,229 @@
+package app
+
+import (
+	"math/big"
+	"sort"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+	mempl "github.com/tendermint/tendermint/mempool"
+)
+
+// A transaction accepted by CheckTx, whose nonce has not been consumed by execution yet
+type pendingTx struct {
+	hash     gethcmn.Hash
+	key      [mempl.TxKeySize]byte // the key of the raw tx in tendermint's mempool
+	nonce    uint64
+	gasPrice *uint256.Int
+	gasFee   *uint256.Int // gas limit * gas price, which is reserved from the sender's balance
+	value    *uint256.Int // the transferred value, which is also reserved until the tx is executed
+	// for the txs in mempool, it is the height at which it was checked or rechecked;
+	// for the txs in block, it is the height of the block
+	height int64
+	// included in a block, so its gas fee has been deducted from the sender's balance, but its nonce and
+	// value are not consumed until it is executed, which may take a few blocks
+	inBlock bool
+}
+
+// The pending transactions of one sender, sorted by nonce
+type senderQueue struct {
+	txs []*pendingTx
+}
+
+func (q *senderQueue) search(nonce uint64) int {
+	return sort.Search(len(q.txs), func(i int) bool { return q.txs[i].nonce >= nonce })
+}
+
+func (q *senderQueue) get(nonce uint64) *pendingTx {
+	if q == nil {
+		return nil
+	}
+	if i := q.search(nonce); i < len(q.txs) && q.txs[i].nonce == nonce {
+		return q.txs[i]
+	}
+	return nil
+}
+
+func (q *senderQueue) len() int {
+	if q == nil {
+		return 0
+	}
+	return len(q.txs)
+}
+
+// Insert tx into the queue, or replace the one with the same nonce
+func (q *senderQueue) set(tx *pendingTx) {
+	i := q.search(tx.nonce)
+	if i < len(q.txs) && q.txs[i].nonce == tx.nonce {
+		q.txs[i] = tx
+		return
+	}
+	q.txs = append(q.txs, nil)
+	copy(q.txs[i+1:], q.txs[i:])
+	q.txs[i] = tx
+}
+
+// Remove the tx with the nonce, if its hash matches
+func (q *senderQueue) remove(nonce uint64, hash gethcmn.Hash) {
+	if i := q.search(nonce); i < len(q.txs) && q.txs[i].nonce == nonce && q.txs[i].hash == hash {
+		q.txs = append(q.txs[:i], q.txs[i+1:]...)
+	}
+}
+
+// The smallest nonce which is not less than accNonce and not taken by any pending tx
+func (q *senderQueue) nextNonce(accNonce uint64) uint64 {
+	if q == nil {
+		return accNonce
+	}
+	for i := q.search(accNonce); i < len(q.txs) && q.txs[i].nonce == accNonce; i++ {
+		accNonce++
+	}
+	return accNonce
+}
+
+// The part of the sender's balance reserved by its pending txs, excluding the one with the nonce 'except':
+// the gas fees of the txs in mempool, and the values of all the txs. Returns false if it overflows.
+func (q *senderQueue) reservedBalance(except uint64) (*uint256.Int, bool) {
+	sum := uint256.NewInt()
+	if q == nil {
+		return sum, true
+	}
+	for _, tx := range q.txs {
+		if tx.nonce == except {
+			continue
+		}
+		if !tx.inBlock && sum.AddOverflow(sum, tx.gasFee) {
+			return nil, false
+		}
+		if sum.AddOverflow(sum, tx.value) {
+			return nil, false
+		}
+	}
+	return sum, true
+}
+
+// pendingTxQueue tracks the transactions accepted by CheckTx, to allow a sender to have more than one
+// pending transactions. It is local to this node and has nothing to do with consensus.
+type pendingTxQueue struct {
+	senders map[gethcmn.Address]*senderQueue
+	// a sender can have at most maxTxsPerSender pending transactions
+	maxTxsPerSender int
+	// a new transaction's nonce can be at most maxNonceGap larger than its sender's next nonce
+	maxNonceGap uint64
+}
+
+func newPendingTxQueue(maxTxsPerSender int, maxNonceGap uint64) *pendingTxQueue {
+	return &pendingTxQueue{
+		senders:         make(map[gethcmn.Address]*senderQueue),
+		maxTxsPerSender: maxTxsPerSender,
+		maxNonceGap:     maxNonceGap,
+	}
+}
+
+// Returns nil if sender has no pending transactions
+func (pq *pendingTxQueue) getQueue(sender gethcmn.Address) *senderQueue {
+	return pq.senders[sender]
+}
+
+func (pq *pendingTxQueue) set(sender gethcmn.Address, tx *pendingTx) {
+	q, ok := pq.senders[sender]
+	if !ok {
+		q = &senderQueue{}
+		pq.senders[sender] = q
+	}
+	q.set(tx)
+}
+
+func (pq *pendingTxQueue) remove(sender gethcmn.Address, nonce uint64, hash gethcmn.Hash) {
+	if q, ok := pq.senders[sender]; ok {
+		q.remove(nonce, hash)
+		if len(q.txs) == 0 {
+			delete(pq.senders, sender)
+		}
+	}
+}
+
+// Mark a transaction as included in the block at 'height', such that its nonce is taken. It replaces
+// the pending one with the same nonce, if any.
+func (pq *pendingTxQueue) markInBlock(sender gethcmn.Address, tx *gethtypes.Transaction, height int64) {
+	gasPrice, _ := uint256.FromBig(tx.GasPrice())
+	value, _ := uint256.FromBig(tx.Value())
+	pq.set(sender, &pendingTx{
+		hash:     tx.Hash(),
+		nonce:    tx.Nonce(),
+		gasPrice: gasPrice,
+		value:    value,
+		height:   height,
+		inBlock:  true,
+	})
+}
+
+// Remove the transactions which are not pending any more, after the block at currHeight is committed:
+// the ones whose nonces are consumed, and the ones in mempool which were not rechecked after the previous
+// block. A tx included in a block keeps its nonce until it is executed, so it is only removed when its
+// nonce is consumed, or when all the txs in previous blocks have been executed ('allExecuted'), which
+// means it was dropped.
+func (pq *pendingTxQueue) prune(currHeight int64, allExecuted bool, getNonce func(addr gethcmn.Address) uint64) {
+	for sender, q := range pq.senders {
+		accNonce := getNonce(sender)
+		txs := q.txs[:0]
+		for _, tx := range q.txs {
+			if tx.nonce < accNonce || (tx.inBlock && allExecuted && tx.height < currHeight) ||
+				(!tx.inBlock && tx.height < currHeight-1) {
+				continue
+			}
+			txs = append(txs, tx)
+		}
+		q.txs = txs
+		if len(q.txs) == 0 {
+			delete(pq.senders, sender)
+		}
+	}
+}
+
+// Returns the gas price of the threshold-th highest-paying tx in mempool,
+// or nil if there are no more than threshold txs in mempool
+func (pq *pendingTxQueue) jamGasPrice(threshold int) *uint256.Int {
+	if threshold < 1 {
+		threshold = 1
+	}
+	gasPrices := make([]*uint256.Int, 0, threshold+1)
+	for _, q := range pq.senders {
+		for _, tx := range q.txs {
+			if !tx.inBlock {
+				gasPrices = append(gasPrices, tx.gasPrice)
+			}
+		}
+	}
+	if len(gasPrices) <= threshold {
+		return nil
+	}
+	sort.Slice(gasPrices, func(i, j int) bool {
+		return gasPrices[i].Gt(gasPrices[j])
+	})
+	return gasPrices[threshold-1]
+}
+
+// MempoolTxRemover is implemented by tendermint's CListMempool. Tendermint v0.34 proposes the txs in mempool
+// in FIFO order, and a tx replaced by another one stays in mempool until it is rechecked after the next block.
+// If both of them are proposed in one block, the loser fails without paying any fee. So the app removes the
+// replaced tx from mempool as soon as the replacing one is accepted.
+type MempoolTxRemover interface {
+	RemoveTxByKey(txKey [mempl.TxKeySize]byte, removeFromCache bool)
+}
+
+// SetMempool must be called before the node starts
+func (app *App) SetMempool(mempool MempoolTxRemover) {
+	app.mempool = mempool
+}
+
+// A pending tx paying oldGasPrice can be replaced by a new one paying newGasPrice,
+// only if the new one pays at least priceBump percent more
+func canReplace(oldGasPrice, newGasPrice *uint256.Int, priceBump uint64) bool {
+	if !newGasPrice.Gt(oldGasPrice) {
+		return false
+	}
+	minGasPrice := new(big.Int).Mul(oldGasPrice.ToBig(), new(big.Int).SetUint64(100+priceBump))
+	return new(big.Int).Mul(newGasPrice.ToBig(), big.NewInt(100)).Cmp(minGasPrice) >= 0
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/staking"
+)
+
+// An UpgradeHandler migrates the state when the upgrade with its name is activated
+type UpgradeHandler func(ctx *types.Context)
+
+// The names of the upgrades known by this binary
+const (
+	// Jail and slash the validators which miss too many blocks, see staking.HandleLiveness
+	UpgradeDowntimeSlashing = "downtime-slashing"
+)
+
+// Register the handlers of the upgrades known by this binary
+func (app *App) registerUpgradeHandlers() {
+	app.upgradeHandlers = make(map[string]UpgradeHandler)
+	app.upgradeHandlers[UpgradeDowntimeSlashing] = staking.EnableDowntimeSlashing
+}
+
+func (app *App) SetUpgradeHandler(name string, handler UpgradeHandler) {
+	app.upgradeHandlers[name] = handler
+}
+
+// A node must not go on if it does not know how to activate an upgrade,
+// the operator must switch to a newer binary
+func (app *App) checkUpgradeHandler(height int64) {
+	if u := app.config.UpgradeAt(height); u != nil {
+		if _, ok := app.upgradeHandlers[u.Name]; !ok {
+			panic(fmt.Sprintf("UPGRADE %q NEEDED at height %d", u.Name, height))
+		}
+	}
+}
+
+// Run the handler of the upgrade activated at current height, if any
+func (app *App) runUpgradeHandler(ctx *types.Context) {
+	if u := app.config.UpgradeAt(app.currHeight); u != nil {
+		app.logger.Info("activating upgrade", "name", u.Name, "height", u.Height)
+		app.upgradeHandlers[u.Name](ctx)
+	}
+}
+
+// Ask the node to stop gracefully, such that the operator can switch the binary. Commit returns as usual,
+// and the one watching Halted stops tendermint and then the app.
+func (app *App) halt() {
+	app.haltOnce.Do(func() {
+		app.logger.Info("halting node", "height", app.currHeight)
+		close(app.halted)
+	})
+}
+
+// Halted is closed after the block at the halt height is committed
+func (app *App) Halted() <-chan struct{} {
+	return app.halted
+}
@@ -0,0 +1,97 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/smartbch/moeingevm/types"
+
+	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/param"
+	"github.com/smartbch/smartbch/staking"
+)
+
+func TestUpgrade(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppWithUpgrades([]param.Upgrade{{Name: "test-upgrade", Height: 3}}, key)
+	defer _app.Destroy()
+
+	_app.ExecTxInBlock(nil)
+	require.Equal(t, int64(2), _app.BlockNum())
+	require.Equal(t, uint64(0), _app.Info(abci.RequestInfo{}).AppVersion)
+
+	// the node can not go on without the handler
+	require.Panics(t, func() {
+		_app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 3}})
+	})
+
+	handled := 0
+	_app.SetUpgradeHandler("test-upgrade", func(ctx *types.Context) {
+		handled++
+		acc := ctx.GetAccount(addr)
+		acc.UpdateBalance(uint256.NewInt().SetUint64(1))
+		ctx.SetAccount(addr, acc)
+	})
+	_app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{
+		Height:          3,
+		ProposerAddress: _app.TestPubkey.Address(),
+	}})
+	res := _app.EndBlock(abci.RequestEndBlock{Height: 3})
+	require.NotNil(t, res.ConsensusParamUpdates)
+	require.Equal(t, uint64(1), res.ConsensusParamUpdates.Version.AppVersion)
+	_app.Commit()
+	_app.WaitLock()
+	require.Equal(t, 1, handled)
+	require.Equal(t, uint64(1), _app.GetBalance(addr).Uint64())
+	require.Equal(t, uint64(1), _app.Info(abci.RequestInfo{}).AppVersion)
+
+	// the handler runs only once
+	_app.ExecTxInBlock(nil)
+	require.Equal(t, 1, handled)
+	require.Equal(t, uint64(1), _app.Info(abci.RequestInfo{}).AppVersion)
+}
+
+func TestUpgradeDowntimeSlashing(t *testing.T) {
+	key, _ := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestAppWithUpgrades([]param.Upgrade{{Name: app.UpgradeDowntimeSlashing, Height: 3}}, key)
+	defer _app.Destroy()
+
+	isEnabled := func() bool {
+		ctx := _app.GetRpcContext()
+		defer ctx.Close(false)
+		return staking.IsDowntimeSlashingEnabled(ctx)
+	}
+	_app.ExecTxInBlock(nil)
+	require.Equal(t, int64(2), _app.BlockNum())
+	require.False(t, isEnabled())
+	_app.ExecTxInBlock(nil)
+	require.Equal(t, int64(4), _app.BlockNum())
+	require.True(t, isEnabled())
+}
+
+func TestHaltHeight(t *testing.T) {
+	_app := testutils.CreateTestAppWithHaltHeight(3)
+	defer _app.Destroy()
+
+	halted := func() bool {
+		select {
+		case <-_app.Halted():
+			return true
+		default:
+			return false
+		}
+	}
+	_app.ExecTxInBlock(nil)
+	require.Equal(t, int64(2), _app.BlockNum())
+	require.False(t, halted())
+	// Commit returns normally, and the one watching Halted stops the node
+	_app.ExecTxInBlock(nil)
+	require.GreaterOrEqual(t, _app.BlockNum(), int64(3))
+	require.True(t, halted())
+}
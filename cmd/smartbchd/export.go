@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/tendermint/tendermint/libs/cli"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/smartbch/smartbch/app"
+	"github.com/smartbch/smartbch/param"
+)
+
+const (
+	flagHeight = "height"
+	flagOutput = "output"
+)
+
+func ExportCmd(ctx *Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the world state as a new genesis file",
+		Long: `Export all the accounts (balances, nonces, code and storage) and the staking info to a new genesis file,
+which can be used to fork the chain into a local test network or to restart the chain from genesis.
+The node must be stopped before exporting. Only the latest height is available since MoeingADS
+does not keep history states.`,
+		Example: `
+smartbchd export --height=123456 --output=exported_genesis.json
+`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			c := ctx.Config
+			c.SetRoot(viper.GetString(cli.HomeFlag))
+			paramConfig := param.DefaultConfig()
+			paramConfig.NodeConfig = c
+			paramConfig.AppDataPath = filepath.Join(c.RootDir, param.AppDataPath)
+			paramConfig.ModbDataPath = filepath.Join(c.RootDir, param.ModbDataPath)
+			paramConfig.SnapshotDataPath = filepath.Join(c.RootDir, param.SnapshotDataPath)
+			paramConfig.BloomBitsDataPath = filepath.Join(c.RootDir, param.BloomBitsDataPath)
+			if err := loadUpgrades(ctx, paramConfig); err != nil {
+				return err
+			}
+			genDoc, err := tmtypes.GenesisDocFromFile(c.GenesisFile())
+			if err != nil {
+				return err
+			}
+			chainID, err := parseChainID(genDoc.ChainID)
+			if err != nil {
+				return err
+			}
+
+			_app := app.NewApp(paramConfig, chainID, ctx.Logger)
+			defer _app.Stop()
+			genesisData, height, err := _app.ExportState()
+			if err != nil {
+				return err
+			}
+			if h := viper.GetInt64(flagHeight); h != 0 && h != height {
+				return fmt.Errorf("can not export height %d, only the latest height %d is available", h, height)
+			}
+			genesisData.Upgrades = paramConfig.Upgrades
+			genDoc.AppState, err = json.Marshal(genesisData)
+			if err != nil {
+				return err
+			}
+			if err := ExportGenesisFile(genDoc, viper.GetString(flagOutput)); err != nil {
+				return err
+			}
+			fmt.Printf("exported the state at height %d to %s\n", height, viper.GetString(flagOutput))
+			return nil
+		},
+	}
+	cmd.Flags().Int64(flagHeight, 0, "The height to export, 0 means the latest height")
+	cmd.Flags().String(flagOutput, "exported_genesis.json", "The file to write the new genesis into")
+	return cmd
+}
@@ -112,7 +112,7 @@ func getAppState() ([]byte, error) {
 
 	fmt.Println("preparing genesis file ...")
 	alloc := testutils.KeysToGenesisAlloc(initBal, testKeys)
-	genData := app.GenesisData{Alloc: alloc}
+	genData := app.GenesisData{Version: app.CurrGenesisVersion, Alloc: alloc}
 	appState, err := json.Marshal(genData)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+
+	cfg "github.com/tendermint/tendermint/config"
+	tmflags "github.com/tendermint/tendermint/libs/cli/flags"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+var _ log.Logger = (*levelLogger)(nil)
+
+// levelLogger filters the logs by a level which can be changed at runtime, including the ones
+// of the loggers derived from it by With
+type levelLogger struct {
+	levels  *logLevels
+	keyvals []interface{}
+	cached  atomic.Value // *versionedLogger
+}
+
+// logLevels holds the current filter, shared by a levelLogger and all the loggers derived from it
+type logLevels struct {
+	base log.Logger
+
+	mtx      sync.RWMutex
+	version  uint64
+	filtered log.Logger
+}
+
+type versionedLogger struct {
+	version uint64
+	logger  log.Logger
+}
+
+func newLevelLogger(base log.Logger, level string) (*levelLogger, error) {
+	l := &levelLogger{levels: &logLevels{base: base}}
+	if err := l.SetLevel(level); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetLevel parses level in the format of the log_level flag, such as "main:info,*:error"
+func (l *levelLogger) SetLevel(level string) error {
+	filtered, err := tmflags.ParseLogLevel(level, l.levels.base, cfg.DefaultLogLevel)
+	if err != nil {
+		return err
+	}
+	l.levels.mtx.Lock()
+	defer l.levels.mtx.Unlock()
+	l.levels.filtered = filtered
+	l.levels.version++
+	return nil
+}
+
+// The keyvals are added after filtering, such that the filter can match the modules in them
+func (l *levelLogger) logger() log.Logger {
+	l.levels.mtx.RLock()
+	version, filtered := l.levels.version, l.levels.filtered
+	l.levels.mtx.RUnlock()
+	if cached, ok := l.cached.Load().(*versionedLogger); ok && cached.version == version {
+		return cached.logger
+	}
+	logger := filtered
+	if len(l.keyvals) > 0 {
+		logger = filtered.With(l.keyvals...)
+	}
+	l.cached.Store(&versionedLogger{version: version, logger: logger})
+	return logger
+}
+
+func (l *levelLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger().Debug(msg, keyvals...)
+}
+
+func (l *levelLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger().Info(msg, keyvals...)
+}
+
+func (l *levelLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger().Error(msg, keyvals...)
+}
+
+func (l *levelLogger) With(keyvals ...interface{}) log.Logger {
+	kvs := make([]interface{}, 0, len(l.keyvals)+len(keyvals))
+	kvs = append(kvs, l.keyvals...)
+	kvs = append(kvs, keyvals...)
+	return &levelLogger{levels: l.levels, keyvals: kvs}
+}
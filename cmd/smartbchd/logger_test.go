@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func TestLevelLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLevelLogger(log.NewTMLogger(&buf), "main:info,*:error")
+	require.NoError(t, err)
+	mainLogger := logger.With("module", "main")
+	p2pLogger := logger.With("module", "p2p")
+
+	mainLogger.Info("main info")
+	p2pLogger.Info("p2p info")
+	require.Contains(t, buf.String(), "main info")
+	require.NotContains(t, buf.String(), "p2p info")
+
+	// the derived loggers follow the new level
+	require.NoError(t, logger.SetLevel("main:error,p2p:debug"))
+	buf.Reset()
+	mainLogger.Info("main info")
+	p2pLogger.Debug("p2p debug")
+	require.NotContains(t, buf.String(), "main info")
+	require.Contains(t, buf.String(), "p2p debug")
+
+	require.Error(t, logger.SetLevel("main:verbose"))
+}
@@ -37,6 +37,7 @@ func createSmartbchdCmd() *cobra.Command {
 	rootCmd.AddCommand(GenerateGenesisValidatorCmd(ctx))
 	rootCmd.AddCommand(AddGenesisValidatorCmd(ctx))
 	rootCmd.AddCommand(StakingCmd(ctx))
+	rootCmd.AddCommand(ExportCmd(ctx))
 	return rootCmd
 }
 
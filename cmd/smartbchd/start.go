@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/holiman/uint256"
 	"github.com/spf13/cobra"
@@ -26,8 +28,40 @@ import (
 const (
 	flagRpcAddr      = "http.addr"
 	flagWsAddr       = "ws.addr"
+	flagRpcHttpsAddr = "https.addr"
+	flagWssAddr      = "wss.addr"
 	flagRetainBlocks = "retain"
 	flagUnlock       = "unlock"
+	flagPersonal     = "personal"
+	flagKeystore     = "keystore"
+	flagArchive      = "archive"
+	flagDebug        = "debug"
+
+	flagCorsDomain   = "http.corsdomain"
+	flagVirtualHosts = "http.vhosts"
+	flagWsOrigins    = "ws.origins"
+	flagTLSCert      = "tls.cert"
+	flagTLSKey       = "tls.key"
+	flagTLSClientCA  = "tls.client-ca"
+	flagAdminAddr    = "admin.addr"
+	flagJWTSecret    = "admin.jwtsecret"
+
+	flagRpcRateLimit         = "rpc.rate-limit"
+	flagRpcRateBurst         = "rpc.rate-burst"
+	flagRpcMaxBatchSize      = "rpc.max-batch-size"
+	flagRpcMaxResponseSize   = "rpc.max-response-size"
+	flagRpcMethodCost        = "rpc.method-cost"
+	flagRpcMaxHistoryQueries = "rpc.max-history-queries"
+
+	flagSnapshotInterval   = "snapshot-interval"
+	flagSnapshotKeepRecent = "snapshot-keep-recent"
+
+	flagMaxPendingTxsPerSender = "max-pending-txs-per-sender"
+	flagMaxPendingNonceGap     = "max-pending-nonce-gap"
+	flagReplaceTxPriceBump     = "replace-tx-price-bump"
+
+	flagUpgrade    = "upgrade"
+	flagHaltHeight = "halt-height"
 )
 
 func StartCmd(ctx *Context, appCreator AppCreator) *cobra.Command {
@@ -46,7 +80,34 @@ func StartCmd(ctx *Context, appCreator AppCreator) *cobra.Command {
 	cmd.Flags().Int64(flagRetainBlocks, -1, "Latest blocks this node retain, default retain all blocks")
 	cmd.Flags().String(flagRpcAddr, "tcp://:8545", "HTTP-RPC server listening address")
 	cmd.Flags().String(flagWsAddr, "tcp://:8546", "WS-RPC server listening address")
+	cmd.Flags().String(flagRpcHttpsAddr, "", "HTTPS-RPC server listening address, empty means disabled")
+	cmd.Flags().String(flagWssAddr, "", "WSS-RPC server listening address, empty means disabled")
+	cmd.Flags().StringSlice(flagCorsDomain, []string{"*"}, "The origins allowed by CORS on HTTP-RPC, empty means CORS is disabled")
+	cmd.Flags().StringSlice(flagVirtualHosts, []string{"*"}, "The host names accepted by HTTP-RPC, '*' means any")
+	cmd.Flags().StringSlice(flagWsOrigins, []string{"*"}, "The origins allowed to connect to WS-RPC, empty means only localhost")
+	cmd.Flags().String(flagTLSCert, "", "The certificate file of HTTPS-RPC and WSS-RPC, default is <home>/config/cert.pem")
+	cmd.Flags().String(flagTLSKey, "", "The key file of HTTPS-RPC and WSS-RPC, default is <home>/config/key.pem")
+	cmd.Flags().String(flagTLSClientCA, "", "The CA certificates file verifying the client certificates of HTTPS-RPC and WSS-RPC, empty means no client authentication")
 	cmd.Flags().String(flagUnlock, "", "Comma separated list of private keys to unlock (only for testing)")
+	cmd.Flags().Bool(flagPersonal, false, "Enable the encrypted keystore and the personal namespace on Admin-RPC, the node fails to start if --admin.addr is not set")
+	cmd.Flags().String(flagKeystore, "", "The directory of the encrypted keystore used by --personal, which requires --admin.addr, default is <home>/keystore")
+	cmd.Flags().String(flagAdminAddr, "", "Admin-RPC server listening address, which is protected by JWT, empty means disabled")
+	cmd.Flags().String(flagJWTSecret, "", "The file of the hex-encoded JWT secret of Admin-RPC, default is <home>/config/jwtsecret")
+	cmd.Flags().Float64(flagRpcRateLimit, 0, "The JSON-RPC requests per second allowed for each client IP, weighted by the method costs, 0 means unlimited")
+	cmd.Flags().Int(flagRpcRateBurst, 0, "The JSON-RPC requests allowed in a burst for each client IP, 0 means the same as the rate limit")
+	cmd.Flags().Int(flagRpcMaxBatchSize, rpc.DefaultMaxBatchSize, "The max number of requests in a JSON-RPC batch, 0 means unlimited")
+	cmd.Flags().Int(flagRpcMaxResponseSize, rpc.DefaultMaxResponseSize, "The max size in bytes of a JSON-RPC response over HTTP, 0 means unlimited")
+	cmd.Flags().StringSlice(flagRpcMethodCost, nil, "Override the cost of a JSON-RPC method in the format of <method>=<cost>")
+	cmd.Flags().Int(flagRpcMaxHistoryQueries, api.DefaultMaxHistoryQueries, "The max number of logs and tx queries running at the same time, 0 means unlimited")
+	cmd.Flags().Bool(flagDebug, false, "Enable the debug namespace on HTTP-RPC and WS-RPC")
+	cmd.Flags().Bool(flagArchive, false, "Keep the states of past blocks, which can be queried through RPC")
+	cmd.Flags().Int64(flagSnapshotInterval, param.DefaultSnapshotInterval, "Take a state-sync snapshot every N blocks, 0 means never")
+	cmd.Flags().Int(flagSnapshotKeepRecent, param.DefaultSnapshotKeepRecent, "How many recent state-sync snapshots are kept")
+	cmd.Flags().Int(flagMaxPendingTxsPerSender, param.DefaultMaxPendingTxsPerSender, "How many pending transactions in mempool are allowed for one sender")
+	cmd.Flags().Uint64(flagMaxPendingNonceGap, param.DefaultMaxPendingNonceGap, "How much a new transaction's nonce can go beyond its sender's next nonce, a tx with a nonce gap may fail without paying fee")
+	cmd.Flags().Uint64(flagReplaceTxPriceBump, param.DefaultReplaceTxPriceBump, "The minimum gas price bump in percent to replace a pending transaction with the same nonce")
+	cmd.Flags().StringSlice(flagUpgrade, nil, "Schedule an upgrade in the format of <name>@<height>, besides the ones in genesis.json")
+	cmd.Flags().Int64(flagHaltHeight, 0, "Halt the node after committing the block at this height, 0 means never")
 	return cmd
 }
 
@@ -60,7 +121,20 @@ func startInProcess(ctx *Context, appCreator AppCreator) (*node.Node, error) {
 	paramConfig.NodeConfig = cfg
 	paramConfig.AppDataPath = filepath.Join(cfg.RootDir, param.AppDataPath)
 	paramConfig.ModbDataPath = filepath.Join(cfg.RootDir, param.ModbDataPath)
+	paramConfig.SnapshotDataPath = filepath.Join(cfg.RootDir, param.SnapshotDataPath)
+	paramConfig.ArchiveMode = viper.GetBool(flagArchive)
+	paramConfig.ArchiveDataPath = filepath.Join(cfg.RootDir, param.ArchiveDataPath)
+	paramConfig.BloomBitsDataPath = filepath.Join(cfg.RootDir, param.BloomBitsDataPath)
 	paramConfig.RetainBlocks = viper.GetInt64(flagRetainBlocks)
+	paramConfig.SnapshotInterval = viper.GetInt64(flagSnapshotInterval)
+	paramConfig.SnapshotKeepRecent = viper.GetInt(flagSnapshotKeepRecent)
+	paramConfig.MaxPendingTxsPerSender = viper.GetInt(flagMaxPendingTxsPerSender)
+	paramConfig.MaxPendingNonceGap = viper.GetUint64(flagMaxPendingNonceGap)
+	paramConfig.ReplaceTxPriceBump = viper.GetUint64(flagReplaceTxPriceBump)
+	paramConfig.HaltHeight = viper.GetInt64(flagHaltHeight)
+	if err := loadUpgrades(ctx, paramConfig); err != nil {
+		return nil, err
+	}
 
 	chainID, err := getChainID(ctx)
 	if err != nil {
@@ -102,34 +176,112 @@ func startInProcess(ctx *Context, appCreator AppCreator) (*node.Node, error) {
 	//	appImpl.Init(nil)
 	//}
 
+	if mempool, ok := tmNode.Mempool().(app.MempoolTxRemover); ok {
+		appImpl.SetMempool(mempool)
+	}
 	if err := tmNode.Start(); err != nil {
 		return nil, err
 	}
 
-	rpcBackend := api.NewBackend(tmNode, appImpl)
-	rpcAddr := viper.GetString(flagRpcAddr)
-	wsAddr := viper.GetString(flagWsAddr)
+	rpcBackend := api.NewBackend(tmNode, appImpl, viper.GetInt(flagRpcMaxHistoryQueries))
 	unlockedKeys := viper.GetString(flagUnlock)
-	//rpcAddr = viper.GetString("")
-	certfileDir := filepath.Join(cfg.RootDir, "config/cert.pem")
-	keyfileDir := filepath.Join(cfg.RootDir, "config/key.pem")
-	rpcServer := rpc.NewServer(rpcAddr, wsAddr, rpcBackend, certfileDir, keyfileDir,
-		ctx.Logger, strings.Split(unlockedKeys, ","))
+	methodCosts, err := rpc.ParseMethodCosts(viper.GetStringSlice(flagRpcMethodCost))
+	if err != nil {
+		return nil, err
+	}
+	rpcConfig := rpc.Config{
+		RpcAddr:       viper.GetString(flagRpcAddr),
+		WsAddr:        viper.GetString(flagWsAddr),
+		RpcHttpsAddr:  viper.GetString(flagRpcHttpsAddr),
+		WssAddr:       viper.GetString(flagWssAddr),
+		CorsOrigins:   viper.GetStringSlice(flagCorsDomain),
+		WsOrigins:     viper.GetStringSlice(flagWsOrigins),
+		VirtualHosts:  viper.GetStringSlice(flagVirtualHosts),
+		CertFile:      viper.GetString(flagTLSCert),
+		KeyFile:       viper.GetString(flagTLSKey),
+		ClientCAFile:  viper.GetString(flagTLSClientCA),
+		AdminAddr:     viper.GetString(flagAdminAddr),
+		JWTSecretFile: viper.GetString(flagJWTSecret),
+		EnableDebug:   viper.GetBool(flagDebug),
+		RateLimits: rpc.RateLimitConfig{
+			RequestsPerSecond: viper.GetFloat64(flagRpcRateLimit),
+			Burst:             viper.GetInt(flagRpcRateBurst),
+			MaxBatchSize:      viper.GetInt(flagRpcMaxBatchSize),
+			MaxResponseSize:   viper.GetInt(flagRpcMaxResponseSize),
+			MethodCosts:       methodCosts,
+		},
+	}
+	if viper.GetBool(flagPersonal) {
+		rpcConfig.KeystoreDir = viper.GetString(flagKeystore)
+		if rpcConfig.KeystoreDir == "" {
+			rpcConfig.KeystoreDir = filepath.Join(cfg.RootDir, "keystore")
+		}
+	}
+	if rpcConfig.JWTSecretFile == "" {
+		rpcConfig.JWTSecretFile = filepath.Join(cfg.RootDir, "config/jwtsecret")
+	}
+	if l, ok := ctx.Logger.(*levelLogger); ok {
+		rpcConfig.SetLogLevel = l.SetLevel
+	}
+	if rpcConfig.CertFile == "" {
+		rpcConfig.CertFile = filepath.Join(cfg.RootDir, "config/cert.pem")
+	}
+	if rpcConfig.KeyFile == "" {
+		rpcConfig.KeyFile = filepath.Join(cfg.RootDir, "config/key.pem")
+	}
+	rpcServer := rpc.NewServer(rpcConfig, rpcBackend, ctx.Logger, strings.Split(unlockedKeys, ","))
 
 	if err := rpcServer.Start(); err != nil {
 		return nil, err
 	}
+	// the app is stopped after tendermint, so that it is not in the middle of a block
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			if tmNode.IsRunning() {
+				_ = rpcServer.Stop()
+				_ = tmNode.Stop()
+				appImpl.Stop()
+			}
+		})
+	}
 	TrapSignal(func() {
-		if tmNode.IsRunning() {
-			_ = rpcServer.Stop()
-			_ = tmNode.Stop()
-			//appImpl.Stop()
-		}
+		stop()
 		ctx.Logger.Info("exiting...")
 	})
 
-	// run forever (the node will not be returned)
-	select {}
+	// run until the halt height is reached
+	<-appImpl.Halted()
+	ctx.Logger.Info("halt height reached, stopping the node", "height", paramConfig.HaltHeight)
+	stop()
+	return tmNode, nil
+}
+
+// Schedule the upgrades in genesis.json and the ones from command line
+func loadUpgrades(ctx *Context, paramConfig *param.ChainConfig) error {
+	gDoc, err := tmtypes.GenesisDocFromFile(ctx.Config.GenesisFile())
+	if err != nil {
+		return err
+	}
+	if len(gDoc.AppState) != 0 {
+		genesisData := app.GenesisData{}
+		if err := json.Unmarshal(gDoc.AppState, &genesisData); err != nil {
+			return err
+		}
+		if err := paramConfig.AddUpgrades(genesisData.Upgrades...); err != nil {
+			return err
+		}
+	}
+	for _, s := range viper.GetStringSlice(flagUpgrade) {
+		u, err := param.ParseUpgrade(s)
+		if err != nil {
+			return err
+		}
+		if err := paramConfig.AddUpgrades(u); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func getChainID(ctx *Context) (*uint256.Int, error) {
@@ -13,7 +13,6 @@ import (
 	tmcmds "github.com/tendermint/tendermint/cmd/tendermint/commands"
 	cfg "github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/crypto"
-	tmflags "github.com/tendermint/tendermint/libs/cli/flags"
 	"github.com/tendermint/tendermint/libs/log"
 	tmos "github.com/tendermint/tendermint/libs/os"
 	"github.com/tendermint/tendermint/p2p"
@@ -84,15 +83,13 @@ func PersistentPreRunEFn(context *Context) func(*cobra.Command, []string) error
 		if err != nil {
 			return err
 		}
-		logger := log.NewTMLogger(log.NewSyncWriter(os.Stdout))
-		// logger = log.NewFilter(logger, log.AllowInfo())
-		logger, err = tmflags.ParseLogLevel(config.LogLevel, logger, cfg.DefaultLogLevel)
+		// the log level can be changed through the admin namespace
+		logger, err := newLevelLogger(log.NewTMLogger(log.NewSyncWriter(os.Stdout)), config.LogLevel)
 		if err != nil {
 			return err
 		}
-		logger = logger.With("module", "main")
 		context.Config = config
-		context.Logger = logger
+		context.Logger = logger.With("module", "main")
 		return nil
 	}
 }
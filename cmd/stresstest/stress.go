@@ -36,9 +36,10 @@ import (
 type RocksDB = indextree.RocksDB
 
 const (
-	adsDir   = "./testdbdata"
-	modbDir  = "./modbdata"
-	blockDir = "./blkdata"
+	adsDir       = "./testdbdata"
+	modbDir      = "./modbdata"
+	blockDir     = "./blkdata"
+	bloomBitsDir = "./bloombitsdata"
 )
 
 var num1e18 = uint256.NewInt().SetUint64(1_000_000_000_000_000_000)
@@ -353,6 +354,7 @@ func CreateTestApp(testInitAmt *uint256.Int, keys []string) *testutils.TestApp {
 	params := param.DefaultConfig()
 	params.AppDataPath = adsDir
 	params.ModbDataPath = modbDir
+	params.BloomBitsDataPath = bloomBitsDir
 	params.UseLiteDB = true
 	params.NumKeptBlocks = 5
 	testValidatorPubKey := ed25519.GenPrivKeyFromSecret([]byte("stress")).PubKey()
@@ -514,6 +516,7 @@ func ShowSlots(_app *testutils.TestApp, caller common.Address, contractAddrs []c
 func RunRecordBlocks(randBlocks, fromSize, toSize, txPerBlock int, fname string) {
 	_ = os.RemoveAll(adsDir)
 	_ = os.RemoveAll(modbDir)
+	_ = os.RemoveAll(bloomBitsDir)
 	_ = os.RemoveAll(blockDir)
 	_ = os.Mkdir(modbDir, 0700)
 	_ = os.Mkdir(blockDir, 0700)
@@ -535,6 +538,7 @@ func RunRecordBlocks(randBlocks, fromSize, toSize, txPerBlock int, fname string)
 
 func RunReplayBlocks(fromSize int, fname string) {
 	_ = os.RemoveAll(modbDir)
+	_ = os.RemoveAll(bloomBitsDir)
 	_ = os.Mkdir(modbDir, 0700)
 
 	blkDB := NewBlockDB(blockDir)
@@ -0,0 +1,45 @@
+package ethutils
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+
+	motypes "github.com/smartbch/moeingevm/types"
+)
+
+// Convert an executed transaction to the receipt defined by go-ethereum
+func ToGethReceipt(tx *motypes.Transaction) *types.Receipt {
+	receipt := &types.Receipt{
+		Type:              types.LegacyTxType,
+		Status:            tx.Status,
+		CumulativeGasUsed: tx.CumulativeGasUsed,
+		Bloom:             tx.LogsBloom,
+		Logs:              motypes.ToGethLogs(tx.Logs),
+		TxHash:            tx.Hash,
+		ContractAddress:   tx.ContractAddress,
+		GasUsed:           tx.GasUsed,
+		BlockHash:         tx.BlockHash,
+		BlockNumber:       big.NewInt(tx.BlockNumber),
+		TransactionIndex:  uint(tx.TransactionIndex),
+	}
+	for _, log := range receipt.Logs {
+		log.TxHash = tx.Hash
+	}
+	return receipt
+}
+
+func ToGethReceipts(txs []*motypes.Transaction) types.Receipts {
+	receipts := make(types.Receipts, len(txs))
+	for i, tx := range txs {
+		receipts[i] = ToGethReceipt(tx)
+	}
+	return receipts
+}
+
+// The root of the receipts trie and the bloom of all the logs, computed in the same way as Ethereum
+func ReceiptsRootAndBloom(receipts types.Receipts) (common.Hash, types.Bloom) {
+	return types.DeriveSha(receipts, trie.NewStackTrie(nil)), types.CreateBloom(receipts)
+}
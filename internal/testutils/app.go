@@ -13,6 +13,7 @@ import (
 	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
 
 	gethcmn "github.com/ethereum/go-ethereum/common"
+	gethcore "github.com/ethereum/go-ethereum/core"
 	gethtypes "github.com/ethereum/go-ethereum/core/types"
 	gethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/holiman/uint256"
@@ -29,8 +30,11 @@ import (
 )
 
 const (
-	adsDir  = "./testdbdata"
-	modbDir = "./modbdata"
+	adsDir       = "./testdbdata"
+	modbDir      = "./modbdata"
+	snapshotDir  = "./snapshotdata"
+	archiveDir   = "./archivedata"
+	bloomBitsDir = "./bloombitsdata"
 )
 
 const (
@@ -51,17 +55,97 @@ func CreateTestApp(keys ...string) *TestApp {
 }
 
 func CreateTestApp0(testInitAmt *uint256.Int, keys ...string) *TestApp {
+	return createTestApp(param.DefaultConfig(), testInitAmt, keys...)
+}
+
+// The created app takes a state-sync snapshot every 'snapshotInterval' blocks
+func CreateTestAppWithSnapshots(snapshotInterval int64, keys ...string) *TestApp {
+	params := param.DefaultConfig()
+	params.SnapshotInterval = snapshotInterval
+	return createTestApp(params, bigutils.NewU256(DefaultInitBalance), keys...)
+}
+
+// The mempool of the created app is jammed when more than 'recheckThreshold' txs remain in it after a block
+func CreateTestAppWithRecheckThreshold(recheckThreshold int, keys ...string) *TestApp {
+	params := param.DefaultConfig()
+	params.RecheckThreshold = recheckThreshold
+	return createTestApp(params, bigutils.NewU256(DefaultInitBalance), keys...)
+}
+
+// The created app accepts the txs whose nonces go at most 'maxNonceGap' beyond their senders' next nonces
+func CreateTestAppWithNonceGap(maxNonceGap uint64, keys ...string) *TestApp {
+	params := param.DefaultConfig()
+	params.MaxPendingNonceGap = maxNonceGap
+	return createTestApp(params, bigutils.NewU256(DefaultInitBalance), keys...)
+}
+
+// The created app halts after committing the block at 'haltHeight'
+func CreateTestAppWithHaltHeight(haltHeight int64, keys ...string) *TestApp {
+	params := param.DefaultConfig()
+	params.HaltHeight = haltHeight
+	return createTestApp(params, bigutils.NewU256(DefaultInitBalance), keys...)
+}
+
+// The created app activates the upgrades at their heights, whose handlers must be set before
+func CreateTestAppWithUpgrades(upgrades []param.Upgrade, keys ...string) *TestApp {
+	params := param.DefaultConfig()
+	if err := params.AddUpgrades(upgrades...); err != nil {
+		panic(err)
+	}
+	return createTestApp(params, bigutils.NewU256(DefaultInitBalance), keys...)
+}
+
+// The created app indexes the logs blooms in sections of 'sectionSize' blocks
+func CreateTestAppWithBloomBitsSection(sectionSize uint64, keys ...string) *TestApp {
+	params := param.DefaultConfig()
+	params.BloomBitsSectionSize = sectionSize
+	return createTestApp(params, bigutils.NewU256(DefaultInitBalance), keys...)
+}
+
+// The created app keeps the states of past blocks
+func CreateTestAppInArchiveMode(keys ...string) *TestApp {
+	params := param.DefaultConfig()
+	params.ArchiveMode = true
+	return createTestApp(params, bigutils.NewU256(DefaultInitBalance), keys...)
+}
+
+// The created app has the accounts in 'alloc' besides the ones of keys
+func CreateTestAppWithAlloc(alloc gethcore.GenesisAlloc, keys ...string) *TestApp {
+	genesisAlloc := KeysToGenesisAlloc(bigutils.NewU256(DefaultInitBalance), keys)
+	for addr, acc := range alloc {
+		genesisAlloc[addr] = acc
+	}
+	return createTestAppWithAlloc(param.DefaultConfig(), app.CurrGenesisVersion, genesisAlloc)
+}
+
+// The created app is initialized with a genesis file of 'genesisVersion'
+func CreateTestAppWithGenesisVersion(genesisVersion int, keys ...string) *TestApp {
+	return createTestAppWithAlloc(param.DefaultConfig(), genesisVersion,
+		KeysToGenesisAlloc(bigutils.NewU256(DefaultInitBalance), keys))
+}
+
+func createTestApp(params *param.ChainConfig, testInitAmt *uint256.Int, keys ...string) *TestApp {
+	return createTestAppWithAlloc(params, app.CurrGenesisVersion, KeysToGenesisAlloc(testInitAmt, keys))
+}
+
+func createTestAppWithAlloc(params *param.ChainConfig, genesisVersion int, alloc gethcore.GenesisAlloc) *TestApp {
 	_ = os.RemoveAll(adsDir)
 	_ = os.RemoveAll(modbDir)
-	params := param.DefaultConfig()
+	_ = os.RemoveAll(snapshotDir)
+	_ = os.RemoveAll(archiveDir)
+	_ = os.RemoveAll(bloomBitsDir)
 	params.AppDataPath = adsDir
 	params.ModbDataPath = modbDir
+	params.SnapshotDataPath = snapshotDir
+	params.ArchiveDataPath = archiveDir
+	params.BloomBitsDataPath = bloomBitsDir
 	testValidatorPubKey := ed25519.GenPrivKey().PubKey()
 	_app := app.NewApp(params, bigutils.NewU256(1), nopLogger)
 	//_app.Init(nil)
 	//_app.txEngine = ebp.NewEbpTxExec(10, 100, 1, 100, _app.signer)
 	genesisData := app.GenesisData{
-		Alloc: KeysToGenesisAlloc(testInitAmt, keys),
+		Version: genesisVersion,
+		Alloc:   alloc,
 	}
 	testValidator := &stakingtypes.Validator{}
 	copy(testValidator.Address[:], testValidatorPubKey.Address().Bytes())
@@ -84,6 +168,9 @@ func (_app *TestApp) Destroy() {
 	_app.Stop()
 	_ = os.RemoveAll(adsDir)
 	_ = os.RemoveAll(modbDir)
+	_ = os.RemoveAll(snapshotDir)
+	_ = os.RemoveAll(archiveDir)
+	_ = os.RemoveAll(bloomBitsDir)
 }
 
 func (_app *TestApp) WaitMS(n int64) {
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
 	"github.com/tendermint/tendermint/config"
 )
 
@@ -13,6 +15,16 @@ const (
 	DefaultNumKeptBlocks           = 10000
 	DefaultSignatureCache          = 20000
 	DefaultRecheckThreshold        = 1000
+	DefaultSnapshotInterval        = 0
+	DefaultSnapshotKeepRecent      = 2
+	DefaultMaxPendingTxsPerSender  = 64
+	DefaultMaxPendingNonceGap      = 0
+	DefaultReplaceTxPriceBump      = 10
+	DefaultBloomBitsSectionSize    = params.BloomBitsBlocks
+	DefaultLivenessWindowSize      = 1000
+	DefaultLivenessMaxMissedBlocks = 500
+	DefaultLivenessMinJailBlocks   = 1000
+	DefaultDowntimeSlashedAmount   = 1 // in BCH
 )
 
 type ChainConfig struct {
@@ -65,15 +77,63 @@ type ChainConfig struct {
 	// How many transactions are allowed to left in the mempool
 	// If more than this threshold, no further transactions can go in mempool
 	RecheckThreshold int
+
+	// How many pending transactions in mempool are allowed for one sender
+	MaxPendingTxsPerSender int
+	// How much a new transaction's nonce can go beyond the next nonce of its sender, where the next nonce
+	// takes the sender's pending transactions into account. Tendermint proposes the txs in mempool in FIFO
+	// order, so a tx with a nonce gap may be proposed before the gap is closed and then fails without paying
+	// any fee, that is why it is 0 by default.
+	MaxPendingNonceGap uint64
+	// A pending transaction can be replaced by a new one with the same nonce,
+	// if the new one's gas price is at least ReplaceTxPriceBump percent higher
+	ReplaceTxPriceBump uint64
+
+	// Take a state-sync snapshot every SnapshotInterval blocks, 0 means never
+	SnapshotInterval int64
+	// How many recent snapshots are kept on disk, older ones are deleted
+	SnapshotKeepRecent int
+	// The directory where the snapshots are stored
+	SnapshotDataPath string `json:"snapshot_data_path,omitempty"`
+
+	// Keep the states of past blocks, such that RPC can read and call at past heights
+	ArchiveMode bool
+	// The directory where the states of past blocks are stored in archive mode
+	ArchiveDataPath string `json:"archive_data_path,omitempty"`
+
+	// The directory where the bloombits index for eth_getLogs is stored
+	BloomBitsDataPath string `json:"bloombits_data_path,omitempty"`
+	// The count of blocks in a section of the bloombits index, which must be a multiple of 8
+	BloomBitsSectionSize uint64
+
+	// Downtime slashing, which is enabled by an upgrade: a validator missing more than LivenessMaxMissedBlocks
+	// blocks in the latest LivenessWindowSize blocks is jailed, and DowntimeSlashedAmount (in wei) of its
+	// staked coins are slashed. A jailed validator can not unjail itself in the LivenessMinJailBlocks blocks
+	// after it was jailed. They must be the same on all the nodes.
+	LivenessWindowSize      int64
+	LivenessMaxMissedBlocks int64
+	LivenessMinJailBlocks   int64
+	DowntimeSlashedAmount   *uint256.Int
+
+	// The scheduled upgrades, sorted by height
+	Upgrades []Upgrade `json:"upgrades,omitempty"`
+	// Halt the node after committing the block at HaltHeight, 0 means never
+	HaltHeight int64
 }
 
 var (
-	AppDataPath  = "app"
-	ModbDataPath = "modb"
+	AppDataPath       = "app"
+	ModbDataPath      = "modb"
+	SnapshotDataPath  = "snapshots"
+	ArchiveDataPath   = "archive"
+	BloomBitsDataPath = "bloombits"
 
-	home                = os.ExpandEnv("$HOME/.smartbchd")
-	defaultAppDataPath  = filepath.Join(home, "data", AppDataPath)
-	defaultModbDataPath = filepath.Join(home, "data", ModbDataPath)
+	home                 = os.ExpandEnv("$HOME/.smartbchd")
+	defaultAppDataPath   = filepath.Join(home, "data", AppDataPath)
+	defaultModbDataPath  = filepath.Join(home, "data", ModbDataPath)
+	defaultSnapshotPath  = filepath.Join(home, "data", SnapshotDataPath)
+	defaultArchivePath   = filepath.Join(home, "data", ArchiveDataPath)
+	defaultBloomBitsPath = filepath.Join(home, "data", BloomBitsDataPath)
 )
 
 func DefaultConfig() *ChainConfig {
@@ -87,6 +147,21 @@ func DefaultConfig() *ChainConfig {
 		NumKeptBlocks:           DefaultNumKeptBlocks,
 		SigCacheSize:            DefaultSignatureCache,
 		RecheckThreshold:        DefaultRecheckThreshold,
+		MaxPendingTxsPerSender:  DefaultMaxPendingTxsPerSender,
+		MaxPendingNonceGap:      DefaultMaxPendingNonceGap,
+		ReplaceTxPriceBump:      DefaultReplaceTxPriceBump,
+		SnapshotInterval:        DefaultSnapshotInterval,
+		SnapshotKeepRecent:      DefaultSnapshotKeepRecent,
+		SnapshotDataPath:        defaultSnapshotPath,
+		ArchiveDataPath:         defaultArchivePath,
+		BloomBitsDataPath:       defaultBloomBitsPath,
+		BloomBitsSectionSize:    DefaultBloomBitsSectionSize,
+		LivenessWindowSize:      DefaultLivenessWindowSize,
+		LivenessMaxMissedBlocks: DefaultLivenessMaxMissedBlocks,
+		LivenessMinJailBlocks:   DefaultLivenessMinJailBlocks,
+		DowntimeSlashedAmount: uint256.NewInt().Mul(
+			uint256.NewInt().SetUint64(DefaultDowntimeSlashedAmount),
+			uint256.NewInt().SetUint64(1000_000_000_000_000_000)),
 	}
 	c.NodeConfig.TxIndex.Indexer = "null"
 	return c
@@ -0,0 +1,77 @@
+package param
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An Upgrade activates new rules at Height. Its Name is used to find the handler which migrates the state.
+type Upgrade struct {
+	Name   string `json:"name"`
+	Height int64  `json:"height"`
+}
+
+// Parse an upgrade in the format of "<name>@<height>"
+func ParseUpgrade(s string) (Upgrade, error) {
+	idx := strings.LastIndex(s, "@")
+	if idx <= 0 {
+		return Upgrade{}, errors.New("upgrade must be in the format of <name>@<height>: " + s)
+	}
+	height, err := strconv.ParseInt(s[idx+1:], 10, 64)
+	if err != nil {
+		return Upgrade{}, fmt.Errorf("invalid height of upgrade %s: %w", s, err)
+	}
+	return Upgrade{Name: s[:idx], Height: height}, nil
+}
+
+// Add upgrades to the schedule, which is kept sorted by height. An upgrade which is already
+// scheduled is ignored. Two upgrades can not share the same name or the same height.
+func (c *ChainConfig) AddUpgrades(upgrades ...Upgrade) error {
+	for _, u := range upgrades {
+		if u.Name == "" || u.Height <= 0 {
+			return fmt.Errorf("invalid upgrade %q at height %d", u.Name, u.Height)
+		}
+		duplicated := false
+		for _, old := range c.Upgrades {
+			if old == u {
+				duplicated = true
+				break
+			}
+			if old.Name == u.Name || old.Height == u.Height {
+				return fmt.Errorf("upgrade %q at height %d conflicts with upgrade %q at height %d",
+					u.Name, u.Height, old.Name, old.Height)
+			}
+		}
+		if !duplicated {
+			c.Upgrades = append(c.Upgrades, u)
+		}
+	}
+	sort.Slice(c.Upgrades, func(i, j int) bool {
+		return c.Upgrades[i].Height < c.Upgrades[j].Height
+	})
+	return nil
+}
+
+// Returns the upgrade activated at height, or nil if there is none
+func (c *ChainConfig) UpgradeAt(height int64) *Upgrade {
+	for i := range c.Upgrades {
+		if c.Upgrades[i].Height == height {
+			return &c.Upgrades[i]
+		}
+	}
+	return nil
+}
+
+// The app version is the count of the upgrades activated at or before height
+func (c *ChainConfig) AppVersionAt(height int64) uint64 {
+	version := uint64(0)
+	for _, u := range c.Upgrades {
+		if u.Height <= height {
+			version++
+		}
+	}
+	return version
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tendermint/tendermint/libs/log"
+
+	"github.com/smartbch/smartbch/internal/ethutils"
+)
+
+// accountManager holds the accounts which can sign txs for eth_sendTransaction: the test keys passed by --unlock,
+// and the ones in the encrypted keystore, which must be unlocked through the personal namespace. The keystore
+// accounts are listed everywhere, but they can sign only on the admin listener, where ksSigning is true.
+type accountManager struct {
+	testKeys  map[common.Address]*ecdsa.PrivateKey // only for test
+	ks        *keystore.KeyStore                   // nil if the keystore is not enabled
+	ksSigning bool
+}
+
+func newAccountManager(testKeys []string, ks *keystore.KeyStore, ksSigning bool, logger log.Logger) *accountManager {
+	return &accountManager{
+		testKeys:  loadTestAccounts(testKeys, logger),
+		ks:        ks,
+		ksSigning: ksSigning,
+	}
+}
+
+// Returns an error if 'from' is not a keystore account which can sign here
+func (am *accountManager) checkKeystoreSigner(from common.Address) error {
+	if am.ks == nil || !am.ks.HasAddress(from) {
+		return errors.New("unknown account: " + from.Hex())
+	}
+	if !am.ksSigning {
+		return errKeystoreSigningNotAllowed
+	}
+	return nil
+}
+
+func loadTestAccounts(testKeys []string, logger log.Logger) map[common.Address]*ecdsa.PrivateKey {
+	accs := make(map[common.Address]*ecdsa.PrivateKey, len(testKeys))
+	for _, testKey := range testKeys {
+		if key, _, err := ethutils.HexToPrivKey(testKey); err == nil {
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+			accs[addr] = key
+		} else {
+			logger.Error("failed to load private key:", testKey, err.Error())
+		}
+	}
+	return accs
+}
+
+// Returns the addresses of the test keys and the keystore accounts in ascending order
+func (am *accountManager) addresses() []common.Address {
+	addrs := make([]common.Address, 0, len(am.testKeys))
+	for addr := range am.testKeys {
+		addrs = append(addrs, addr)
+	}
+	if am.ks != nil {
+		for _, acc := range am.ks.Accounts() {
+			if _, ok := am.testKeys[acc.Address]; !ok {
+				addrs = append(addrs, acc.Address)
+			}
+		}
+	}
+	sortAddresses(addrs)
+	return addrs
+}
+
+// Signs tx with a test key or an unlocked keystore account
+func (am *accountManager) signTx(from common.Address, tx *gethtypes.Transaction, chainID *big.Int) (*gethtypes.Transaction, error) {
+	if key, ok := am.testKeys[from]; ok {
+		return ethutils.SignTx(tx, chainID, key)
+	}
+	if err := am.checkKeystoreSigner(from); err != nil {
+		return nil, err
+	}
+	return am.ks.SignTx(accounts.Account{Address: from}, tx, chainID)
+}
+
+// Signs tx with a keystore account, which is unlocked only for this tx
+func (am *accountManager) signTxWithPassphrase(from common.Address, passphrase string,
+	tx *gethtypes.Transaction, chainID *big.Int) (*gethtypes.Transaction, error) {
+
+	if err := am.checkKeystoreSigner(from); err != nil {
+		return nil, err
+	}
+	return am.ks.SignTxWithPassphrase(accounts.Account{Address: from}, passphrase, tx, chainID)
+}
+
+// Signs a 32-byte hash with a test key or an unlocked keystore account,
+// the V of the returned signature is 0 or 1
+func (am *accountManager) signHash(from common.Address, hash []byte) ([]byte, error) {
+	if key, ok := am.testKeys[from]; ok {
+		return crypto.Sign(hash, key)
+	}
+	if err := am.checkKeystoreSigner(from); err != nil {
+		return nil, err
+	}
+	return am.ks.SignHash(accounts.Account{Address: from}, hash)
+}
+
+// Signs a 32-byte hash with a keystore account, which is unlocked only for this hash
+func (am *accountManager) signHashWithPassphrase(from common.Address, passphrase string, hash []byte) ([]byte, error) {
+	if err := am.checkKeystoreSigner(from); err != nil {
+		return nil, err
+	}
+	return am.ks.SignHashWithPassphrase(accounts.Account{Address: from}, passphrase, hash)
+}
+
+func sortAddresses(addrs []common.Address) {
+	sort.Slice(addrs, func(i, j int) bool {
+		for k := 0; k < common.AddressLength; k++ {
+			if addrs[i][k] < addrs[j][k] {
+				return true
+			} else if addrs[i][k] > addrs[j][k] {
+				return false
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/p2p"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/smartbch/smartbch/api"
+)
+
+var _ AdminAPI = (*adminAPI)(nil)
+
+// AdminAPI is only served on the admin listener, which is protected by JWT
+type AdminAPI interface {
+	NodeInfo() (p2p.NodeInfo, error)
+	Peers() ([]ctypes.Peer, error)
+	AddPeer(url string) (bool, error)
+	RemovePeer(id string) (bool, error)
+	FlushMempool() bool
+	SetLogLevel(level string) (bool, error)
+}
+
+type adminAPI struct {
+	backend     api.BackendService
+	setLogLevel func(level string) error
+	logger      log.Logger
+}
+
+func newAdminAPI(backend api.BackendService, setLogLevel func(level string) error, logger log.Logger) AdminAPI {
+	return &adminAPI{
+		backend:     backend,
+		setLogLevel: setLogLevel,
+		logger:      logger.With("module", "admin-api"),
+	}
+}
+
+// NodeInfo returns the p2p info of this node, in the same format as tendermint's /status
+func (admin *adminAPI) NodeInfo() (p2p.NodeInfo, error) {
+	admin.logger.Debug("admin_nodeInfo")
+	return admin.backend.P2PNodeInfo(), nil
+}
+
+// Peers returns the connected peers, in the same format as tendermint's /net_info
+func (admin *adminAPI) Peers() ([]ctypes.Peer, error) {
+	admin.logger.Debug("admin_peers")
+	peers := admin.backend.Peers()
+	infos := make([]ctypes.Peer, 0, len(peers))
+	for _, peer := range peers {
+		nodeInfo, ok := peer.NodeInfo().(p2p.DefaultNodeInfo)
+		if !ok {
+			return nil, errors.New("unknown node info type")
+		}
+		infos = append(infos, ctypes.Peer{
+			NodeInfo:         nodeInfo,
+			IsOutbound:       peer.IsOutbound(),
+			ConnectionStatus: peer.Status(),
+			RemoteIP:         peer.RemoteIP().String(),
+		})
+	}
+	return infos, nil
+}
+
+// AddPeer connects to a peer in the format of <id>@<host>:<port>, returns before the connection is made
+func (admin *adminAPI) AddPeer(url string) (bool, error) {
+	admin.logger.Info("admin_addPeer", "url", url)
+	if err := admin.backend.DialPeer(url); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemovePeer disconnects the peer with the given node ID
+func (admin *adminAPI) RemovePeer(id string) (bool, error) {
+	admin.logger.Info("admin_removePeer", "id", id)
+	if err := admin.backend.StopPeer(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FlushMempool drops all the txs in mempool
+func (admin *adminAPI) FlushMempool() bool {
+	admin.logger.Info("admin_flushMempool")
+	admin.backend.FlushMempool()
+	return true
+}
+
+// SetLogLevel changes the log level at runtime, in the same format as the log_level flag, such as "main:info,*:error"
+func (admin *adminAPI) SetLogLevel(level string) (bool, error) {
+	admin.logger.Info("admin_setLogLevel", "level", level)
+	if admin.setLogLevel == nil {
+		return false, errors.New("the log level can not be changed")
+	}
+	if err := admin.setLogLevel(level); err != nil {
+		return false, err
+	}
+	return true, nil
+}
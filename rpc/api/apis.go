@@ -1,6 +1,7 @@
 package api
 
 import (
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/tendermint/tendermint/libs/log"
 
@@ -9,32 +10,36 @@ import (
 )
 
 const (
-	namespaceEth    = "eth"
-	namespaceNet    = "net"
-	namespaceWeb3   = "web3"
-	namespaceTxPool = "txpool"
-	namespaceEVM    = "evm"
-	namespaceSBCH   = "sbch"
-	namespaceTM     = "tm"
-	//namespacePersonal = "personal"
+	namespaceEth      = "eth"
+	namespaceNet      = "net"
+	namespaceWeb3     = "web3"
+	namespaceTxPool   = "txpool"
+	namespaceEVM      = "evm"
+	namespaceSBCH     = "sbch"
+	namespaceTM       = "tm"
+	namespaceDebug    = "debug"
+	namespaceAdmin    = "admin"
+	namespacePersonal = "personal"
 
 	apiVersion = "1.0"
 )
 
-// GetAPIs returns the list of all APIs from the Ethereum namespaces
-func GetAPIs(backend sbchapi.BackendService,
-	logger log.Logger, testKeys []string) []rpc.API {
+// GetAPIs returns the list of all APIs from the Ethereum namespaces,
+// and the debug namespace is included only if enableDebug is true.
+// The accounts in the keystore ks, if not nil, are listed but can not sign through these APIs.
+func GetAPIs(backend sbchapi.BackendService, logger log.Logger,
+	testKeys []string, ks *keystore.KeyStore, enableDebug bool) []rpc.API {
 
-	_ethAPI := newEthAPI(backend, testKeys, logger)
-	_netAPI := newNetAPI(backend.ChainId().Uint64())
+	_ethAPI := newEthAPI(backend, newAccountManager(testKeys, ks, false, logger), logger)
+	_netAPI := newNetAPI(backend.ChainId().Uint64(), backend)
 	_filterAPI := filters.NewAPI(backend)
 	_web3API := web3API{}
-	_txPoolAPI := txPoolAPI{}
+	_txPoolAPI := newTxPoolAPI(backend)
 	_sbchAPI := newSbchAPI(backend)
 	_evmAPI := newEvmAPI(backend)
 	_tmAPI := newTendermintAPI(backend)
 
-	return []rpc.API{
+	apis := []rpc.API{
 		{
 			Namespace: namespaceEth,
 			Version:   apiVersion,
@@ -84,4 +89,44 @@ func GetAPIs(backend sbchapi.BackendService,
 			Public:    true,
 		},
 	}
+	if enableDebug {
+		apis = append(apis, rpc.API{
+			Namespace: namespaceDebug,
+			Version:   apiVersion,
+			Service:   newDebugAPI(_ethAPI, logger),
+			Public:    true,
+		})
+	}
+	return apis
+}
+
+// GetAdminAPIs returns the APIs served only on the admin listener. If the keystore ks is not nil,
+// the personal namespace is included, along with an eth namespace which signs with the unlocked
+// keystore accounts, so that an unlocked account is never exposed to the public listeners.
+func GetAdminAPIs(backend sbchapi.BackendService, logger log.Logger,
+	setLogLevel func(level string) error, testKeys []string, ks *keystore.KeyStore) []rpc.API {
+
+	apis := []rpc.API{
+		{
+			Namespace: namespaceAdmin,
+			Version:   apiVersion,
+			Service:   newAdminAPI(backend, setLogLevel, logger),
+			Public:    false,
+		},
+	}
+	if ks != nil {
+		_ethAPI := newEthAPI(backend, newAccountManager(testKeys, ks, true, logger), logger)
+		apis = append(apis, rpc.API{
+			Namespace: namespaceEth,
+			Version:   apiVersion,
+			Service:   _ethAPI,
+			Public:    false,
+		}, rpc.API{
+			Namespace: namespacePersonal,
+			Version:   apiVersion,
+			Service:   newPersonalAPI(_ethAPI, ks, logger),
+			Public:    false,
+		})
+	}
+	return apis
 }
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"math/big"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/tendermint/tendermint/libs/log"
+
+	"github.com/smartbch/moeingevm/ebp"
+
+	rpctypes "github.com/smartbch/smartbch/rpc/internal/ethapi"
+)
+
+const callTracer = "callTracer"
+
+var _ DebugAPI = (*debugAPI)(nil)
+
+// https://geth.ethereum.org/docs/rpc/ns-debug
+type DebugAPI interface {
+	TraceTransaction(txHash gethcmn.Hash, config *TraceConfig) (interface{}, error)
+	TraceCall(args rpctypes.CallArgs, blockNrOrHash gethrpc.BlockNumberOrHash, config *TraceConfig) (interface{}, error)
+}
+
+// TraceConfig selects the tracer. The EVM runs inside evmwrap, which exposes neither its execution steps nor
+// the inner calls, so the only supported tracer is callTracer with onlyTopCall, which traces the outermost frame.
+type TraceConfig struct {
+	Tracer       *string           `json:"tracer"`
+	TracerConfig *CallTracerConfig `json:"tracerConfig"`
+}
+
+// CallTracerConfig is the config of callTracer
+type CallTracerConfig struct {
+	OnlyTopCall bool `json:"onlyTopCall"`
+}
+
+// CallFrame is the output of callTracer with onlyTopCall
+type CallFrame struct {
+	Type    string          `json:"type"`
+	From    gethcmn.Address `json:"from"`
+	To      gethcmn.Address `json:"to"`
+	Value   *hexutil.Big    `json:"value"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+type debugAPI struct {
+	eth    *ethAPI
+	logger log.Logger
+}
+
+func newDebugAPI(eth *ethAPI, logger log.Logger) DebugAPI {
+	return &debugAPI{
+		eth:    eth,
+		logger: logger.With("module", "debug-api"),
+	}
+}
+
+// TraceTransaction re-executes a committed tx on the state it was executed on, which needs archive mode
+func (api *debugAPI) TraceTransaction(txHash gethcmn.Hash, config *TraceConfig) (interface{}, error) {
+	api.logger.Debug("debug_traceTransaction")
+	if err := checkTracer(config); err != nil {
+		return nil, err
+	}
+	runner, err := api.eth.backend.TraceTx(txHash)
+	if err != nil {
+		return nil, err
+	}
+	return newCallFrame(runner), nil
+}
+
+// TraceCall runs a call on the state of the given block like eth_call, and traces it
+func (api *debugAPI) TraceCall(args rpctypes.CallArgs, blockNrOrHash gethrpc.BlockNumberOrHash, config *TraceConfig) (interface{}, error) {
+	api.logger.Debug("debug_traceCall")
+	if err := checkTracer(config); err != nil {
+		return nil, err
+	}
+	height, err := api.eth.resolveHeight(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	tx, from, err := api.eth.createGethTxFromCallArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	runner, err := api.eth.backend.TraceCall(tx, from, height)
+	if err != nil {
+		return nil, err
+	}
+	return newCallFrame(runner), nil
+}
+
+func checkTracer(config *TraceConfig) error {
+	if config == nil || config.Tracer == nil {
+		return errStructLoggerNotSupported
+	}
+	if *config.Tracer != callTracer {
+		return fmt.Errorf("tracer %s is not supported", *config.Tracer)
+	}
+	if config.TracerConfig == nil || !config.TracerConfig.OnlyTopCall {
+		return errInnerCallsNotSupported
+	}
+	return nil
+}
+
+func newCallFrame(runner *ebp.TxRunner) *CallFrame {
+	frame := &CallFrame{
+		Type:    "CALL",
+		From:    runner.Tx.From,
+		To:      runner.Tx.To,
+		Value:   (*hexutil.Big)(new(big.Int).SetBytes(runner.Tx.Value[:])),
+		Gas:     hexutil.Uint64(runner.Tx.Gas),
+		GasUsed: hexutil.Uint64(runner.GasUsed),
+		Input:   runner.Tx.Data,
+		Output:  runner.OutData,
+	}
+	if isZeroAddress(runner.Tx.To) {
+		frame.Type = "CREATE"
+		frame.To = runner.CreatedContractAddress
+	}
+	if ebp.StatusIsFailure(runner.Status) {
+		frame.Error = traceErrorString(runner.Status)
+	}
+	return frame
+}
+
+// The error strings of geth's tracers
+func traceErrorString(status int) string {
+	switch statusStr := ebp.StatusToStr(status); statusStr {
+	case "revert":
+		return "execution reverted"
+	case "out-of-gas":
+		return "out of gas"
+	case "invalid-instruction":
+		return "invalid opcode"
+	default:
+		return statusStr
+	}
+}
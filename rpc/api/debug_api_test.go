@@ -0,0 +1,174 @@
+package api
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/smartbch/smartbch/api"
+	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/rpc/internal/ethapi"
+)
+
+func TestTraceTransaction(t *testing.T) {
+	fromKey, fromAddr := testutils.GenKeyAndAddr()
+
+	_app := testutils.CreateTestAppInArchiveMode(fromKey)
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := newDebugAPI(createEthAPI(_app), _app.Logger())
+
+	// deploy contract
+	tx0 := gethtypes.NewContractCreation(0, big.NewInt(0), 100000, big.NewInt(1),
+		counterContractCreationBytecode)
+	tx0 = testutils.MustSignTx(tx0, _app.ChainID().ToBig(), fromKey)
+	_app.ExecTxInBlock(tx0)
+	contractAddr := gethcrypto.CreateAddress(fromAddr, tx0.Nonce())
+
+	// the second tx is replayed after the first one in the same block
+	tx1 := gethtypes.NewTransaction(1, contractAddr, big.NewInt(0), 100000, big.NewInt(1),
+		counterContractABI.MustPack("update", big.NewInt(2)))
+	tx1 = testutils.MustSignTx(tx1, _app.ChainID().ToBig(), fromKey)
+	tx2 := gethtypes.NewTransaction(2, contractAddr, big.NewInt(0), 100000, big.NewInt(1),
+		counterContractABI.MustPack("update", big.NewInt(3)))
+	tx2 = testutils.MustSignTx(tx2, _app.ChainID().ToBig(), fromKey)
+	_app.ExecTxsInBlock(tx1, tx2)
+	_app.EnsureTxSuccess(tx2.Hash())
+
+	tracer := callTracer
+	topCall := &TraceConfig{Tracer: &tracer, TracerConfig: &CallTracerConfig{OnlyTopCall: true}}
+	ret, err := _api.TraceTransaction(tx2.Hash(), topCall)
+	require.NoError(t, err)
+	frame := ret.(*CallFrame)
+	require.Equal(t, "CALL", frame.Type)
+	require.Equal(t, hexutil.Uint64(_app.GetTx(tx2.Hash()).GasUsed), frame.GasUsed)
+	require.Empty(t, frame.Error)
+
+	ret, err = _api.TraceTransaction(tx0.Hash(), topCall)
+	require.NoError(t, err)
+	frame = ret.(*CallFrame)
+	require.Equal(t, "CREATE", frame.Type)
+	require.Equal(t, fromAddr, frame.From)
+	require.Equal(t, contractAddr, frame.To)
+	require.Equal(t, hexutil.Uint64(100000), frame.Gas)
+	require.Equal(t, hexutil.Uint64(_app.GetTx(tx0.Hash()).GasUsed), frame.GasUsed)
+	require.Empty(t, frame.Error)
+
+	// the execution steps and the inner calls can not be traced
+	_, err = _api.TraceTransaction(tx2.Hash(), nil)
+	require.Equal(t, errStructLoggerNotSupported, err)
+	_, err = _api.TraceTransaction(tx2.Hash(), &TraceConfig{Tracer: &tracer})
+	require.Equal(t, errInnerCallsNotSupported, err)
+	unknownTracer := "prestateTracer"
+	_, err = _api.TraceTransaction(tx2.Hash(), &TraceConfig{Tracer: &unknownTracer})
+	require.EqualError(t, err, "tracer prestateTracer is not supported")
+}
+
+func TestTraceTransaction_rejectedTx(t *testing.T) {
+	fromKey, _ := testutils.GenKeyAndAddr()
+
+	_app := testutils.CreateTestAppInArchiveMode(fromKey)
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := newDebugAPI(createEthAPI(_app), _app.Logger())
+
+	// tx0 is rejected by Prepare and listed in the block without being executed
+	tx0 := gethtypes.NewTransaction(5, gethcmn.Address{0x01}, big.NewInt(1), 100000, big.NewInt(1), nil)
+	tx0 = testutils.MustSignTx(tx0, _app.ChainID().ToBig(), fromKey)
+	tx1 := gethtypes.NewTransaction(0, gethcmn.Address{0x01}, big.NewInt(1), 100000, big.NewInt(1), nil)
+	tx1 = testutils.MustSignTx(tx1, _app.ChainID().ToBig(), fromKey)
+	_app.ExecTxsInBlock(tx0, tx1)
+	_app.EnsureTxFailed(tx0.Hash(), "incorrect nonce")
+	_app.EnsureTxSuccess(tx1.Hash())
+
+	tracer := callTracer
+	topCall := &TraceConfig{Tracer: &tracer, TracerConfig: &CallTracerConfig{OnlyTopCall: true}}
+	_, err := _api.TraceTransaction(tx0.Hash(), topCall)
+	require.EqualError(t, err, "the tx was rejected before execution")
+	ret, err := _api.TraceTransaction(tx1.Hash(), topCall)
+	require.NoError(t, err)
+	require.Empty(t, ret.(*CallFrame).Error)
+}
+
+func TestTraceTransaction_notArchiveMode(t *testing.T) {
+	fromKey, _ := testutils.GenKeyAndAddr()
+
+	_app := testutils.CreateTestApp(fromKey)
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := newDebugAPI(createEthAPI(_app), _app.Logger())
+
+	tx, _ := _app.MakeAndExecTxInBlock(fromKey, gethcmn.Address{0x01}, 100, nil)
+	_app.EnsureTxSuccess(tx.Hash())
+	tracer := callTracer
+	_, err := _api.TraceTransaction(tx.Hash(), &TraceConfig{Tracer: &tracer, TracerConfig: &CallTracerConfig{OnlyTopCall: true}})
+	require.EqualError(t, err, "the node is not in archive mode")
+}
+
+func TestTraceCall(t *testing.T) {
+	fromKey, fromAddr := testutils.GenKeyAndAddr()
+
+	_app := testutils.CreateTestApp(fromKey)
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := newDebugAPI(createEthAPI(_app), _app.Logger())
+
+	tx := gethtypes.NewContractCreation(0, big.NewInt(0), 100000, big.NewInt(1),
+		counterContractCreationBytecode)
+	tx = testutils.MustSignTx(tx, _app.ChainID().ToBig(), fromKey)
+	_app.ExecTxInBlock(tx)
+	contractAddr := gethcrypto.CreateAddress(fromAddr, tx.Nonce())
+
+	tracer := callTracer
+	topCall := &TraceConfig{Tracer: &tracer, TracerConfig: &CallTracerConfig{OnlyTopCall: true}}
+	data := hexutil.Bytes{0x12, 0x34, 0x56, 0x78}
+	ret, err := _api.TraceCall(ethapi.CallArgs{
+		From: &fromAddr,
+		To:   &contractAddr,
+		Data: &data,
+	}, latestBlock, topCall)
+	require.NoError(t, err)
+	frame := ret.(*CallFrame)
+	require.Equal(t, "CALL", frame.Type)
+	require.Equal(t, contractAddr, frame.To)
+	require.Equal(t, data, frame.Input)
+	require.Equal(t, "execution reverted", frame.Error)
+
+	ret, err = _api.TraceCall(ethapi.CallArgs{
+		To:   &contractAddr,
+		Data: testutils.ToHexutilBytes(counterContractABI.MustPack("counter")),
+	}, latestBlock, topCall)
+	require.NoError(t, err)
+	frame = ret.(*CallFrame)
+	require.Empty(t, frame.Error)
+	require.Equal(t, hexutil.Bytes(make([]byte, 32)), frame.Output)
+
+	_, err = _api.TraceCall(ethapi.CallArgs{
+		To:   &contractAddr,
+		Data: testutils.ToHexutilBytes(counterContractABI.MustPack("counter")),
+	}, latestBlock, nil)
+	require.Equal(t, errStructLoggerNotSupported, err)
+}
+
+func TestGetAPIs_debug(t *testing.T) {
+	_app := testutils.CreateTestApp()
+	defer _app.Destroy()
+	backend := api.NewBackend(nil, _app.App, 0)
+
+	hasDebug := func(enableDebug bool) bool {
+		for _, a := range GetAPIs(backend, _app.Logger(), nil, nil, enableDebug) {
+			if a.Namespace == namespaceDebug {
+				return true
+			}
+		}
+		return false
+	}
+	require.False(t, hasDebug(false))
+	require.True(t, hasDebug(true))
+}
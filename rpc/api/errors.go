@@ -13,6 +13,15 @@ const defaultErrorCode = -32000
 
 var _ rpc.Error = callError{}
 
+var (
+	errStructLoggerNotSupported = callError{code: defaultErrorCode,
+		msg: "the struct logger is not supported: evmwrap does not expose the execution steps, use callTracer with onlyTopCall"}
+	errInnerCallsNotSupported = callError{code: defaultErrorCode,
+		msg: "callTracer is only supported with onlyTopCall: evmwrap does not expose the inner calls"}
+	errKeystoreSigningNotAllowed = callError{code: defaultErrorCode,
+		msg: "keystore accounts can only sign through the admin listener"}
+)
+
 type callError struct {
 	msg  string
 	code int
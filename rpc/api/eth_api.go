@@ -1,19 +1,18 @@
 package api
 
 import (
-	"crypto/ecdsa"
 	"errors"
 	"math/big"
-	"sort"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	gethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	gethrpc "github.com/ethereum/go-ethereum/rpc"
+	signercore "github.com/ethereum/go-ethereum/signer/core"
 
 	"github.com/tendermint/tendermint/libs/log"
-	tmrpc "github.com/tendermint/tendermint/rpc/core"
 
 	"github.com/smartbch/moeingevm/ebp"
 	"github.com/smartbch/moeingevm/types"
@@ -34,77 +33,57 @@ var _ PublicEthAPI = (*ethAPI)(nil)
 type PublicEthAPI interface {
 	Accounts() ([]common.Address, error)
 	BlockNumber() (hexutil.Uint64, error)
-	Call(args rpctypes.CallArgs, blockNr gethrpc.BlockNumber) (hexutil.Bytes, error)
+	Call(args rpctypes.CallArgs, blockNrOrHash gethrpc.BlockNumberOrHash) (hexutil.Bytes, error)
 	ChainId() hexutil.Uint64
 	Coinbase() (common.Address, error)
-	EstimateGas(args rpctypes.CallArgs) (hexutil.Uint64, error)
-	GasPrice() *hexutil.Big
-	GetBalance(addr common.Address, blockNum gethrpc.BlockNumber) (*hexutil.Big, error)
+	EstimateGas(args rpctypes.CallArgs, blockNrOrHash *gethrpc.BlockNumberOrHash) (hexutil.Uint64, error)
+	FeeHistory(blockCount DecimalOrHex, lastBlock gethrpc.BlockNumber, rewardPercentiles []float64) (*FeeHistoryResult, error)
+	GasPrice() (*hexutil.Big, error)
+	GetBalance(addr common.Address, blockNrOrHash gethrpc.BlockNumberOrHash) (*hexutil.Big, error)
 	GetBlockByHash(hash common.Hash, fullTx bool) (map[string]interface{}, error)
 	GetBlockByNumber(blockNum gethrpc.BlockNumber, fullTx bool) (map[string]interface{}, error)
 	GetBlockTransactionCountByHash(hash common.Hash) *hexutil.Uint
 	GetBlockTransactionCountByNumber(blockNum gethrpc.BlockNumber) *hexutil.Uint
-	GetCode(addr common.Address, blockNum gethrpc.BlockNumber) (hexutil.Bytes, error)
-	GetStorageAt(addr common.Address, key string, blockNum gethrpc.BlockNumber) (hexutil.Bytes, error)
+	GetCode(addr common.Address, blockNrOrHash gethrpc.BlockNumberOrHash) (hexutil.Bytes, error)
+	GetStorageAt(addr common.Address, key string, blockNrOrHash gethrpc.BlockNumberOrHash) (hexutil.Bytes, error)
 	GetTransactionByBlockHashAndIndex(hash common.Hash, idx hexutil.Uint) (*rpctypes.Transaction, error)
 	GetTransactionByBlockNumberAndIndex(blockNum gethrpc.BlockNumber, idx hexutil.Uint) (*rpctypes.Transaction, error)
 	GetTransactionByHash(hash common.Hash) (*rpctypes.Transaction, error)
-	GetTransactionCount(addr common.Address, blockNum gethrpc.BlockNumber) (*hexutil.Uint64, error)
+	GetTransactionCount(addr common.Address, blockNrOrHash gethrpc.BlockNumberOrHash) (*hexutil.Uint64, error)
 	GetTransactionReceipt(hash common.Hash) (map[string]interface{}, error)
+	GetBlockReceipts(blockNrOrHash gethrpc.BlockNumberOrHash) ([]map[string]interface{}, error)
 	GetUncleByBlockHashAndIndex(hash common.Hash, idx hexutil.Uint) map[string]interface{}
 	GetUncleByBlockNumberAndIndex(number hexutil.Uint, idx hexutil.Uint) map[string]interface{}
 	GetUncleCountByBlockHash(_ common.Hash) hexutil.Uint
 	GetUncleCountByBlockNumber(_ gethrpc.BlockNumber) hexutil.Uint
+	MaxPriorityFeePerGas() (*hexutil.Big, error)
 	ProtocolVersion() hexutil.Uint
 	SendRawTransaction(data hexutil.Bytes) (common.Hash, error) // ?
 	SendTransaction(args rpctypes.SendTxArgs) (common.Hash, error)
+	Sign(addr common.Address, data hexutil.Bytes) (hexutil.Bytes, error)
+	SignTransaction(args rpctypes.SendTxArgs) (*SignTransactionResult, error)
+	SignTypedData_v4(addr common.Address, typedData rpctypes.TypedData) (hexutil.Bytes, error)
 	Syncing() (interface{}, error)
 }
 
 type ethAPI struct {
 	backend  sbchapi.BackendService
-	accounts map[common.Address]*ecdsa.PrivateKey // only for test
+	gpo      *gasPriceOracle
+	accounts *accountManager
 	logger   log.Logger
 }
 
-func newEthAPI(backend sbchapi.BackendService, testKeys []string, logger log.Logger) *ethAPI {
+func newEthAPI(backend sbchapi.BackendService, accounts *accountManager, logger log.Logger) *ethAPI {
 	return &ethAPI{
 		backend:  backend,
-		accounts: loadTestAccounts(testKeys, logger),
+		gpo:      newGasPriceOracle(backend),
+		accounts: accounts,
 		logger:   logger.With("module", "eth-api"),
 	}
 }
 
-func loadTestAccounts(testKeys []string, logger log.Logger) map[common.Address]*ecdsa.PrivateKey {
-	accs := make(map[common.Address]*ecdsa.PrivateKey, len(testKeys))
-	for _, testKey := range testKeys {
-		if key, _, err := ethutils.HexToPrivKey(testKey); err == nil {
-			addr := crypto.PubkeyToAddress(key.PublicKey)
-			accs[addr] = key
-		} else {
-			logger.Error("failed to load private key:", testKey, err.Error())
-		}
-	}
-	return accs
-}
-
 func (api *ethAPI) Accounts() ([]common.Address, error) {
-	addrs := make([]common.Address, 0, len(api.accounts))
-	for addr := range api.accounts {
-		addrs = append(addrs, addr)
-	}
-
-	sort.Slice(addrs, func(i, j int) bool {
-		for k := 0; k < common.AddressLength; k++ {
-			if addrs[i][k] < addrs[j][k] {
-				return true
-			} else if addrs[i][k] > addrs[j][k] {
-				return false
-			}
-		}
-		return false
-	})
-	return addrs, nil
+	return api.accounts.addresses(), nil
 }
 
 // https://eth.wiki/json-rpc/API#eth_blockNumber
@@ -119,20 +98,38 @@ func (api *ethAPI) ChainId() hexutil.Uint64 {
 }
 
 // https://eth.wiki/json-rpc/API#eth_coinbase
+// The coinbase is where the rewards of this node's validator go, or the zero address if this node is not a validator
 func (api *ethAPI) Coinbase() (common.Address, error) {
-	// TODO: this is temporary implementation
-	return common.Address{}, nil
+	rewardTo, _ := api.backend.ValidatorRewardTo()
+	return rewardTo, nil
 }
 
 // https://eth.wiki/json-rpc/API#eth_gasPrice
-func (api *ethAPI) GasPrice() *hexutil.Big {
-	return (*hexutil.Big)(big.NewInt(0))
+func (api *ethAPI) GasPrice() (*hexutil.Big, error) {
+	price, err := api.gpo.SuggestPrice()
+	return (*hexutil.Big)(price), err
+}
+
+// smartBCH has no base fee, so the priority fee is the whole gas price
+func (api *ethAPI) MaxPriorityFeePerGas() (*hexutil.Big, error) {
+	price, err := api.gpo.SuggestPrice()
+	return (*hexutil.Big)(price), err
+}
+
+// https://github.com/ethereum/execution-apis, eth_feeHistory
+func (api *ethAPI) FeeHistory(blockCount DecimalOrHex, lastBlock gethrpc.BlockNumber,
+	rewardPercentiles []float64) (*FeeHistoryResult, error) {
+
+	return api.gpo.FeeHistory(uint64(blockCount), lastBlock.Int64(), rewardPercentiles)
 }
 
 // https://eth.wiki/json-rpc/API#eth_getBalance
-func (api *ethAPI) GetBalance(addr common.Address, blockNum gethrpc.BlockNumber) (*hexutil.Big, error) {
-	// ignore blockNumber temporary
-	b, err := api.backend.GetBalance(addr, int64(gethrpc.LatestBlockNumber))
+func (api *ethAPI) GetBalance(addr common.Address, blockNrOrHash gethrpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	height, err := api.resolveHeight(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	b, err := api.backend.GetBalance(addr, height)
 	if err != nil {
 		if err == types.ErrAccNotFound {
 			return (*hexutil.Big)(big.NewInt(0)), nil
@@ -143,18 +140,24 @@ func (api *ethAPI) GetBalance(addr common.Address, blockNum gethrpc.BlockNumber)
 }
 
 // https://eth.wiki/json-rpc/API#eth_getCode
-func (api *ethAPI) GetCode(addr common.Address, blockNum gethrpc.BlockNumber) (hexutil.Bytes, error) {
-	// ignore blockNumber temporary
-	code, _ := api.backend.GetCode(addr, int64(gethrpc.LatestBlockNumber))
-	return code, nil
+func (api *ethAPI) GetCode(addr common.Address, blockNrOrHash gethrpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	height, err := api.resolveHeight(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	code, _, err := api.backend.GetCode(addr, height)
+	return code, err
 }
 
 // https://eth.wiki/json-rpc/API#eth_getStorageAt
-func (api *ethAPI) GetStorageAt(addr common.Address, key string, blockNum gethrpc.BlockNumber) (hexutil.Bytes, error) {
-	// ignore blockNumber temporary
+func (api *ethAPI) GetStorageAt(addr common.Address, key string, blockNrOrHash gethrpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	height, err := api.resolveHeight(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
 	hash := common.HexToHash(key)
 	key = string(hash[:])
-	return api.backend.GetStorageAt(addr, key, int64(gethrpc.LatestBlockNumber)), nil
+	return api.backend.GetStorageAt(addr, key, height)
 }
 
 // https://eth.wiki/json-rpc/API#eth_getBlockByHash
@@ -175,7 +178,7 @@ func (api *ethAPI) GetBlockByHash(hash common.Hash, fullTx bool) (map[string]int
 		}
 	}
 
-	return blockToRpcResp(block, txs), nil
+	return blockToRpcResp(block, api.backend.ReceiptsRoot(block.Number), txs), nil
 }
 
 // https://eth.wiki/json-rpc/API#eth_getBlockByNumber
@@ -195,7 +198,7 @@ func (api *ethAPI) GetBlockByNumber(blockNum gethrpc.BlockNumber, fullTx bool) (
 			return nil, err
 		}
 	}
-	return blockToRpcResp(block, txs), nil
+	return blockToRpcResp(block, api.backend.ReceiptsRoot(block.Number), txs), nil
 }
 
 // https://eth.wiki/json-rpc/API#eth_getBlockTransactionCountByHash
@@ -246,9 +249,12 @@ func (api *ethAPI) GetTransactionByHash(hash common.Hash) (*rpctypes.Transaction
 }
 
 // https://eth.wiki/json-rpc/API#eth_getTransactionCount
-func (api *ethAPI) GetTransactionCount(addr common.Address, blockNum gethrpc.BlockNumber) (*hexutil.Uint64, error) {
-	// ignore blockNumber temporary
-	nonce, err := api.backend.GetNonce(addr)
+func (api *ethAPI) GetTransactionCount(addr common.Address, blockNrOrHash gethrpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
+	height, err := api.resolveHeight(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := api.backend.GetNonce(addr, height)
 	if err != nil {
 		return nil, err
 	}
@@ -256,6 +262,34 @@ func (api *ethAPI) GetTransactionCount(addr common.Address, blockNum gethrpc.Blo
 	return &nonceU64, nil
 }
 
+// Resolve the block number or hash (EIP-1898) to the height of a state, a negative height means the latest state.
+// All the blocks are canonical because of tendermint's instant finality, so requireCanonical needs no check.
+func (api *ethAPI) resolveHeight(blockNrOrHash gethrpc.BlockNumberOrHash) (int64, error) {
+	var height int64
+	if blockHash, ok := blockNrOrHash.Hash(); ok {
+		block, err := api.backend.BlockByHash(blockHash)
+		if err != nil {
+			return 0, err
+		}
+		height = block.Number
+	} else if blockNr, ok := blockNrOrHash.Number(); ok {
+		if blockNr == gethrpc.LatestBlockNumber || blockNr == gethrpc.PendingBlockNumber {
+			return int64(gethrpc.LatestBlockNumber), nil
+		}
+		height = blockNr.Int64()
+	} else {
+		return 0, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	latest := api.backend.LatestHeight()
+	if height > latest {
+		return 0, errors.New("header not found")
+	}
+	if height == latest {
+		return int64(gethrpc.LatestBlockNumber), nil
+	}
+	return height, nil
+}
+
 func (api *ethAPI) getBlockByNum(blockNum gethrpc.BlockNumber) (*types.Block, error) {
 	height := blockNum.Int64()
 	if height <= 0 {
@@ -290,6 +324,31 @@ func (api *ethAPI) GetTransactionReceipt(hash common.Hash) (map[string]interface
 	return txToReceiptRpcResp(tx), nil
 }
 
+// Returns the receipts of all the txs in a block, each of which is the same as eth_getTransactionReceipt's
+func (api *ethAPI) GetBlockReceipts(blockNrOrHash gethrpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	var block *types.Block
+	var err error
+	if blockHash, ok := blockNrOrHash.Hash(); ok {
+		block, err = api.backend.BlockByHash(blockHash)
+	} else if blockNum, ok := blockNrOrHash.Number(); ok {
+		block, err = api.getBlockByNum(blockNum)
+	} else {
+		return nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	if err != nil {
+		if err == types.ErrBlockNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	txs, err := api.backend.GetTxListByHeight(uint32(block.Number))
+	if err != nil {
+		return nil, err
+	}
+	return txsToReceiptRpcResp(txs), nil
+}
+
 // https://eth.wiki/json-rpc/API#eth_getUncleByBlockHashAndIndex
 func (api *ethAPI) GetUncleByBlockHashAndIndex(hash common.Hash, idx hexutil.Uint) map[string]interface{} {
 	// not supported
@@ -336,70 +395,147 @@ func (api *ethAPI) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
 
 // https://eth.wiki/json-rpc/API#eth_sendTransaction
 func (api *ethAPI) SendTransaction(args rpctypes.SendTxArgs) (common.Hash, error) {
-	privKey, found := api.accounts[args.From]
-	if !found {
-		return common.Hash{}, errors.New("unknown account: " + args.From.Hex())
+	return api.signAndSendTx(args, func(tx *gethtypes.Transaction, chainID *big.Int) (*gethtypes.Transaction, error) {
+		return api.accounts.signTx(args.From, tx, chainID)
+	})
+}
+
+// Signs the tx of args with signFn and broadcasts it
+func (api *ethAPI) signAndSendTx(args rpctypes.SendTxArgs,
+	signFn func(tx *gethtypes.Transaction, chainID *big.Int) (*gethtypes.Transaction, error)) (common.Hash, error) {
+
+	tx, err := api.signTxFromArgs(args, signFn)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	txBytes, err := ethutils.EncodeTx(tx)
+	if err != nil {
+		return common.Hash{}, err
 	}
 
+	tmTxHash, err := api.backend.SendRawTx(txBytes)
+	if err != nil {
+		return tmTxHash, err
+	}
+
+	txHash := tx.Hash()
+	return txHash, err
+}
+
+// Fills the nonce of args if it is missing, then creates the tx and signs it with signFn
+func (api *ethAPI) signTxFromArgs(args rpctypes.SendTxArgs,
+	signFn func(tx *gethtypes.Transaction, chainID *big.Int) (*gethtypes.Transaction, error)) (*gethtypes.Transaction, error) {
+
 	if args.Nonce == nil {
-		if nonce, err := api.backend.GetNonce(args.From); err == nil {
+		if nonce, err := api.backend.GetNonce(args.From, int64(gethrpc.LatestBlockNumber)); err == nil {
 			args.Nonce = (*hexutil.Uint64)(&nonce)
 		}
 	}
 
 	tx, err := createGethTxFromSendTxArgs(args)
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
 	}
+	return signFn(tx, api.backend.ChainId())
+}
 
-	chainID := api.backend.ChainId()
-	tx, err = ethutils.SignTx(tx, chainID, privKey)
+// SignTransactionResult is the result of eth_signTransaction, the signed tx is not broadcast
+type SignTransactionResult struct {
+	Raw hexutil.Bytes          `json:"raw"`
+	Tx  *gethtypes.Transaction `json:"tx"`
+}
+
+// https://eth.wiki/json-rpc/API#eth_signtransaction
+func (api *ethAPI) SignTransaction(args rpctypes.SendTxArgs) (*SignTransactionResult, error) {
+	tx, err := api.signTxFromArgs(args, func(tx *gethtypes.Transaction, chainID *big.Int) (*gethtypes.Transaction, error) {
+		return api.accounts.signTx(args.From, tx, chainID)
+	})
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
+	}
+	raw, err := ethutils.EncodeTx(tx)
+	if err != nil {
+		return nil, err
 	}
+	return &SignTransactionResult{Raw: raw, Tx: tx}, nil
+}
 
-	txBytes, err := ethutils.EncodeTx(tx)
+// https://eth.wiki/json-rpc/API#eth_sign
+// The data is signed with the prefix of EIP-191: "\x19Ethereum Signed Message:\n" + len(data)
+func (api *ethAPI) Sign(addr common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	sig, err := api.accounts.signHash(addr, accounts.TextHash(data))
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
 	}
+	sig[crypto.RecoveryIDOffset] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return sig, nil
+}
 
-	tmTxHash, err := api.backend.SendRawTx(txBytes)
+// https://eips.ethereum.org/EIPS/eip-712
+// The signature is the same as the one of MetaMask's eth_signTypedData_v4
+func (api *ethAPI) SignTypedData_v4(addr common.Address, typedData rpctypes.TypedData) (hexutil.Bytes, error) {
+	hash, err := typedDataHash(typedData.TypedData)
 	if err != nil {
-		return tmTxHash, err
+		return nil, err
 	}
+	sig, err := api.accounts.signHash(addr, hash)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return sig, nil
+}
 
-	txHash := tx.Hash()
-	return txHash, err
+// keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+func typedDataHash(typedData signercore.TypedData) ([]byte, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, err
+	}
+	rawData := make([]byte, 0, 2+len(domainSeparator)+len(messageHash))
+	rawData = append(rawData, 0x19, 0x01)
+	rawData = append(rawData, domainSeparator...)
+	rawData = append(rawData, messageHash...)
+	return crypto.Keccak256(rawData), nil
 }
 
 // https://eth.wiki/json-rpc/API#eth_syncing
 func (api *ethAPI) Syncing() (interface{}, error) {
-	status, err := tmrpc.Status(nil)
-	if err != nil {
-		return false, err
-	}
-	if !status.SyncInfo.CatchingUp {
+	status := api.backend.SyncStatus()
+	if !status.CatchingUp {
 		return false, nil
 	}
 
+	// the states are synced by tendermint as snapshots rather than trie nodes
 	return map[string]interface{}{
-		// "startingBlock": nil, // NA
-		"currentBlock": hexutil.Uint64(status.SyncInfo.LatestBlockHeight),
-		// "highestBlock":  nil, // NA
-		// "pulledStates":  nil, // NA
-		// "knownStates":   nil, // NA
+		"startingBlock": hexutil.Uint64(status.StartingBlock),
+		"currentBlock":  hexutil.Uint64(status.CurrentBlock),
+		"highestBlock":  hexutil.Uint64(status.HighestBlock),
+		"pulledStates":  hexutil.Uint64(0),
+		"knownStates":   hexutil.Uint64(0),
 	}, nil
 }
 
 // https://eth.wiki/json-rpc/API#eth_call
-func (api *ethAPI) Call(args rpctypes.CallArgs, blockNr gethrpc.BlockNumber) (hexutil.Bytes, error) {
-	// ignore blockNumber temporary
+func (api *ethAPI) Call(args rpctypes.CallArgs, blockNrOrHash gethrpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	height, err := api.resolveHeight(blockNrOrHash)
+	if err != nil {
+		return hexutil.Bytes{}, err
+	}
 	tx, from, err := api.createGethTxFromCallArgs(args)
 	if err != nil {
 		return hexutil.Bytes{}, err
 	}
 
-	statusCode, retData := api.backend.Call(tx, from)
+	statusCode, retData, err := api.backend.Call(tx, from, height)
+	if err != nil {
+		return hexutil.Bytes{}, err
+	}
 	if !ebp.StatusIsFailure(statusCode) {
 		return retData, nil
 	}
@@ -408,13 +544,23 @@ func (api *ethAPI) Call(args rpctypes.CallArgs, blockNr gethrpc.BlockNumber) (he
 }
 
 // https://eth.wiki/json-rpc/API#eth_estimateGas
-func (api *ethAPI) EstimateGas(args rpctypes.CallArgs) (hexutil.Uint64, error) {
+func (api *ethAPI) EstimateGas(args rpctypes.CallArgs, blockNrOrHash *gethrpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	height := int64(gethrpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		var err error
+		if height, err = api.resolveHeight(*blockNrOrHash); err != nil {
+			return 0, err
+		}
+	}
 	tx, from, err := api.createGethTxFromCallArgs(args)
 	if err != nil {
 		return 0, err
 	}
 
-	statusCode, retData, gas := api.backend.EstimateGas(tx, from)
+	statusCode, retData, gas, err := api.backend.EstimateGas(tx, from, height)
+	if err != nil {
+		return 0, err
+	}
 	if !ebp.StatusIsFailure(statusCode) {
 		return hexutil.Uint64(gas), nil
 	}
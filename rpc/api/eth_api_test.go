@@ -2,7 +2,9 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"encoding/json"
 	"math"
 	"math/big"
 	"strings"
@@ -11,10 +13,12 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	gethcmn "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	gethtypes "github.com/ethereum/go-ethereum/core/types"
 	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
 
 	modbtypes "github.com/smartbch/moeingdb/types"
 	"github.com/smartbch/moeingevm/types"
@@ -25,6 +29,8 @@ import (
 	rpctypes "github.com/smartbch/smartbch/rpc/internal/ethapi"
 )
 
+var latestBlock = gethrpc.BlockNumberOrHashWithNumber(gethrpc.LatestBlockNumber)
+
 // code, rtCode, _ := testutils.MustCompileSolStr(counterContract)
 var counterContractCreationBytecode = testutils.HexToBytes(`
 608060405234801561001057600080fd5b5060b28061001f6000396000f3fe60
@@ -109,6 +115,22 @@ func TestBlockNum(t *testing.T) {
 	require.Equal(t, "0x100", num.String())
 }
 
+func TestNodeStatusWithoutNode(t *testing.T) {
+	_app := testutils.CreateTestApp()
+	defer _app.Destroy()
+	_api := createEthAPI(_app)
+	_netAPI := newNetAPI(_app.ChainID().Uint64(), _api.backend)
+
+	require.Equal(t, 0, _netAPI.PeerCount())
+	require.False(t, _netAPI.Listening())
+	syncing, err := _api.Syncing()
+	require.NoError(t, err)
+	require.Equal(t, false, syncing)
+	coinbase, err := _api.Coinbase()
+	require.NoError(t, err)
+	require.Equal(t, gethcmn.Address{}, coinbase)
+}
+
 func TestGetBalance(t *testing.T) {
 	key, addr := testutils.GenKeyAndAddr()
 	_, addr2 := testutils.GenKeyAndAddr()
@@ -117,11 +139,11 @@ func TestGetBalance(t *testing.T) {
 	defer _app.Destroy()
 	_api := createEthAPI(_app)
 
-	b, err := _api.GetBalance(addr, -1)
+	b, err := _api.GetBalance(addr, latestBlock)
 	require.NoError(t, err)
 	require.Equal(t, "0x989680", b.String())
 
-	b2, err := _api.GetBalance(addr2, -1)
+	b2, err := _api.GetBalance(addr2, latestBlock)
 	require.NoError(t, err)
 	require.Equal(t, "0x0", b2.String())
 }
@@ -141,7 +163,7 @@ func TestGetTxCount(t *testing.T) {
 	_app.CloseTxEngineContext()
 	_app.CloseTrunk()
 
-	nonce, err := _api.GetTransactionCount(addr, 0)
+	nonce, err := _api.GetTransactionCount(addr, latestBlock)
 	require.NoError(t, err)
 	require.Equal(t, hexutil.Uint64(78), *nonce)
 }
@@ -161,7 +183,7 @@ func TestGetCode(t *testing.T) {
 	_app.CloseTxEngineContext()
 	_app.CloseTrunk()
 
-	c, err := _api.GetCode(addr, 0)
+	c, err := _api.GetCode(addr, latestBlock)
 	require.NoError(t, err)
 	require.Equal(t, "0x1234", c.String())
 }
@@ -184,7 +206,7 @@ func TestGetStorageAt(t *testing.T) {
 	_app.CloseTxEngineContext()
 	_app.CloseTrunk()
 
-	sVal, err := _api.GetStorageAt(addr, "0x"+sKeyHex, 0)
+	sVal, err := _api.GetStorageAt(addr, "0x"+sKeyHex, latestBlock)
 	require.NoError(t, err)
 	require.Equal(t, "0x1234", sVal.String())
 }
@@ -210,6 +232,8 @@ func TestGetBlockByNumAndHash(t *testing.T) {
 	require.Equal(t, hexutil.Bytes(nil), getBlockByNumResult["extraData"])
 	require.Equal(t, "0x0000000000000000", getBlockByNumResult["nonce"].(hexutil.Bytes).String())
 	require.Equal(t, []gethcmn.Hash{{0x56}, {0x78}, {0x90}}, getBlockByNumResult["transactions"])
+	require.True(t, getBlockByNumResult["logsBloom"].(gethtypes.Bloom).Test(gethcmn.Address{}.Bytes()))
+	require.NotEqual(t, gethcmn.Hash{}, getBlockByNumResult["receiptsRoot"])
 
 	getBlockByHashResult, err := _api.GetBlockByHash(hash, false)
 	require.NoError(t, err)
@@ -355,13 +379,56 @@ func TestGetTxReceipt(t *testing.T) {
 	require.Equal(t, []byte{0xD1}, gethLogs[1].Data)
 }
 
+func TestGetBlockReceipts(t *testing.T) {
+	_app := testutils.CreateTestApp()
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := createEthAPI(_app)
+
+	blkHash := gethcmn.Hash{0x12, 0x34}
+	block := testutils.NewMdbBlockBuilder().
+		Hash(blkHash).Height(123).
+		Tx(gethcmn.Hash{0x56}).
+		Tx(gethcmn.Hash{0x78},
+			types.Log{Address: gethcmn.Address{0xA1}, Topics: [][32]byte{{0xF1}, {0xF2}}},
+			types.Log{Address: gethcmn.Address{0xA2}, Topics: [][32]byte{{0xF3}, {0xF4}}, Data: []byte{0xD1}}).
+		Tx(gethcmn.Hash{0x90}).
+		Build()
+	_app.StoreBlocks(block)
+
+	receipts, err := _api.GetBlockReceipts(gethrpc.BlockNumberOrHashWithNumber(123))
+	require.NoError(t, err)
+	require.Len(t, receipts, 3)
+	for i, txHash := range []gethcmn.Hash{{0x56}, {0x78}, {0x90}} {
+		receipt, err := _api.GetTransactionReceipt(txHash)
+		require.NoError(t, err)
+		require.Equal(t, receipt, receipts[i])
+	}
+	require.Len(t, receipts[1]["logs"], 2)
+
+	receiptsByHash, err := _api.GetBlockReceipts(gethrpc.BlockNumberOrHashWithHash(blkHash, false))
+	require.NoError(t, err)
+	require.Equal(t, receipts, receiptsByHash)
+
+	receipts, err = _api.GetBlockReceipts(gethrpc.BlockNumberOrHashWithHash(gethcmn.Hash{0x99}, false))
+	require.NoError(t, err)
+	require.Nil(t, receipts)
+
+	gethReceipts, err := _api.backend.GetReceipts(context.Background(), blkHash)
+	require.NoError(t, err)
+	require.Len(t, gethReceipts, 3)
+	require.Equal(t, gethcmn.Hash{0x78}, gethReceipts[1].TxHash)
+	require.Len(t, gethReceipts[1].Logs, 2)
+	require.Equal(t, gethcmn.Address{0xA2}, gethReceipts[1].Logs[1].Address)
+}
+
 func TestCall_NoFromAddr(t *testing.T) {
 	_app := testutils.CreateTestApp()
 	_app.WaitLock()
 	defer _app.Destroy()
 	_api := createEthAPI(_app)
 
-	_, err := _api.Call(ethapi.CallArgs{}, 0)
+	_, err := _api.Call(ethapi.CallArgs{}, latestBlock)
 	require.NoError(t, err)
 }
 
@@ -378,7 +445,7 @@ func TestCall_Transfer(t *testing.T) {
 		From:  &fromAddr,
 		To:    &toAddr,
 		Value: testutils.ToHexutilBig(10),
-	}, 0)
+	}, latestBlock)
 	require.NoError(t, err)
 	require.Equal(t, []byte{}, []byte(ret))
 
@@ -386,7 +453,7 @@ func TestCall_Transfer(t *testing.T) {
 		From:  &fromAddr,
 		To:    &toAddr,
 		Value: testutils.ToHexutilBig(math.MaxInt64),
-	}, 0)
+	}, latestBlock)
 	require.Error(t, err)
 	//require.Equal(t, []byte{}, []byte(ret))
 }
@@ -402,7 +469,7 @@ func TestCall_DeployContract(t *testing.T) {
 	ret, err := _api.Call(ethapi.CallArgs{
 		From: &fromAddr,
 		Data: testutils.ToHexutilBytes(counterContractCreationBytecode),
-	}, 0)
+	}, latestBlock)
 	require.NoError(t, err)
 	require.Equal(t, []byte{}, []byte(ret))
 }
@@ -421,7 +488,7 @@ func TestCall_RunGetter(t *testing.T) {
 	tx = testutils.MustSignTx(tx, _app.ChainID().ToBig(), fromKey)
 	_app.ExecTxInBlock(tx)
 	contractAddr := gethcrypto.CreateAddress(fromAddr, tx.Nonce())
-	rtCode, err := _api.GetCode(contractAddr, 0)
+	rtCode, err := _api.GetCode(contractAddr, latestBlock)
 	require.NoError(t, err)
 	require.True(t, len(rtCode) > 0)
 
@@ -431,7 +498,7 @@ func TestCall_RunGetter(t *testing.T) {
 		//From: &fromAddr,
 		To:   &contractAddr,
 		Data: testutils.ToHexutilBytes(data),
-	}, 0)
+	}, latestBlock)
 	require.NoError(t, err)
 	require.Equal(t, "0000000000000000000000000000000000000000000000000000000000000000",
 		hex.EncodeToString(results))
@@ -448,14 +515,130 @@ func TestEstimateGas(t *testing.T) {
 	ret, err := _api.EstimateGas(ethapi.CallArgs{
 		From: &fromAddr,
 		Data: testutils.ToHexutilBytes(counterContractCreationBytecode),
-	})
+	}, nil)
 	require.NoError(t, err)
 	require.Equal(t, 96908, int(ret))
 }
 
+func TestSign(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := createEthAPI(_app, key)
+
+	data := hexutil.Bytes("hello")
+	sig, err := _api.Sign(addr, data)
+	require.NoError(t, err)
+	require.Len(t, sig, 65)
+	require.True(t, sig[64] == 27 || sig[64] == 28)
+	sig[64] -= 27
+	pubKey, err := gethcrypto.SigToPub(accounts.TextHash(data), sig)
+	require.NoError(t, err)
+	require.Equal(t, addr, gethcrypto.PubkeyToAddress(*pubKey))
+
+	_, addr2 := testutils.GenKeyAndAddr()
+	_, err = _api.Sign(addr2, data)
+	require.EqualError(t, err, "unknown account: "+addr2.Hex())
+}
+
+func TestSignTransaction(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := createEthAPI(_app, key)
+
+	to := gethcmn.Address{0x01}
+	gas := hexutil.Uint64(21000)
+	ret, err := _api.SignTransaction(rpctypes.SendTxArgs{
+		From:  addr,
+		To:    &to,
+		Gas:   &gas,
+		Value: (*hexutil.Big)(big.NewInt(100)),
+	})
+	require.NoError(t, err)
+
+	tx := &gethtypes.Transaction{}
+	require.NoError(t, tx.UnmarshalBinary(ret.Raw))
+	require.Equal(t, ret.Tx.Hash(), tx.Hash())
+	require.Equal(t, uint64(0), tx.Nonce())
+	require.Equal(t, to, *tx.To())
+	sender, err := gethtypes.Sender(gethtypes.NewEIP155Signer(_app.ChainID().ToBig()), tx)
+	require.NoError(t, err)
+	require.Equal(t, addr, sender)
+
+	// the signed tx is not broadcast
+	nonce, err := _api.GetTransactionCount(addr, latestBlock)
+	require.NoError(t, err)
+	require.Equal(t, hexutil.Uint64(0), *nonce)
+}
+
+// the example of EIP-712, whose signature is the same as MetaMask's
+func TestSignTypedData_v4(t *testing.T) {
+	key := "c85ef7d79691fe79573b1a7064c19c1a9819ebdbd1faaab1a8ec92344438aaf4" // keccak256("cow")
+	_app := testutils.CreateTestApp(key)
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := createEthAPI(_app, key)
+
+	typedDataJSON := `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+	// MetaMask sends the typed data as a JSON string
+	var typedData rpctypes.TypedData
+	param, err := json.Marshal(typedDataJSON)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(param, &typedData))
+
+	addr := gethcmn.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826")
+	sig, err := _api.SignTypedData_v4(addr, typedData)
+	require.NoError(t, err)
+	expected := "0x" +
+		"4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" +
+		"1c"
+	require.Equal(t, expected, sig.String())
+
+	// a JSON object with a hex chainId also works
+	typedData = rpctypes.TypedData{}
+	require.NoError(t, json.Unmarshal([]byte(strings.Replace(typedDataJSON, `"chainId": 1`, `"chainId": "0x1"`, 1)), &typedData))
+	sig, err = _api.SignTypedData_v4(addr, typedData)
+	require.NoError(t, err)
+	require.Equal(t, expected, sig.String())
+}
+
 func createEthAPI(_app *testutils.TestApp, testKeys ...string) *ethAPI {
-	backend := api.NewBackend(nil, _app.App)
-	return newEthAPI(backend, testKeys, _app.Logger())
+	backend := api.NewBackend(nil, _app.App, 0)
+	return newEthAPI(backend, newAccountManager(testKeys, nil, false, _app.Logger()), _app.Logger())
 }
 
 func newMdbBlock(hash gethcmn.Hash, height int64,
@@ -493,7 +676,7 @@ func testRandomTransfer() {
 				From:  &fromAddr,
 				To:    &toAddr,
 				Value: testutils.ToHexutilBig(10),
-			}, 0)
+			}, latestBlock)
 			w.Done()
 		}()
 	}
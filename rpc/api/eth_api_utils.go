@@ -71,14 +71,14 @@ func createGethTxFromSendTxArgs(args rpctypes.SendTxArgs) (*gethtypes.Transactio
 	return tx, nil
 }
 
-func blockToRpcResp(block *types.Block, txs []*types.Transaction) map[string]interface{} {
+func blockToRpcResp(block *types.Block, receiptsRoot gethcmn.Hash, txs []*types.Transaction) map[string]interface{} {
 	result := map[string]interface{}{
 		"number":           hexutil.Uint64(block.Number),
 		"hash":             hexutil.Bytes(block.Hash[:]),
 		"parentHash":       hexutil.Bytes(block.ParentHash[:]),
 		"nonce":            hexutil.Bytes(make([]byte, 8)), // PoW specific
 		"sha3Uncles":       gethcmn.Hash{},                 // No uncles in Tendermint
-		"logsBloom":        gethtypes.Bloom{},
+		"logsBloom":        gethtypes.Bloom(block.LogsBloom),
 		"transactionsRoot": hexutil.Bytes(block.TransactionsRoot[:]),
 		"stateRoot":        hexutil.Bytes(block.StateRoot[:]),
 		"miner":            hexutil.Bytes(block.Miner[:]),
@@ -92,7 +92,7 @@ func blockToRpcResp(block *types.Block, txs []*types.Transaction) map[string]int
 		"timestamp":        hexutil.Uint64(block.Timestamp),
 		"transactions":     types.ToGethHashes(block.Transactions),
 		"uncles":           []string{},
-		"receiptsRoot":     gethcmn.Hash{},
+		"receiptsRoot":     receiptsRoot,
 	}
 
 	if len(txs) > 0 {
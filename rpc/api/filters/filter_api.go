@@ -14,6 +14,7 @@ import (
 
 	motypes "github.com/smartbch/moeingevm/types"
 	mapi "github.com/smartbch/smartbch/api"
+	rpctypes "github.com/smartbch/smartbch/rpc/internal/ethapi"
 )
 
 var _ PublicFilterAPI = (*filterAPI)(nil)
@@ -28,6 +29,8 @@ type PublicFilterAPI interface {
 	GetLogs(crit gethfilters.FilterCriteria) ([]*gethtypes.Log, error)
 	NewBlockFilter() rpc.ID
 	NewFilter(crit gethfilters.FilterCriteria) (rpc.ID, error)
+	NewPendingTransactionFilter(fullTx *bool) rpc.ID
+	NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error)
 	UninstallFilter(id rpc.ID) bool
 }
 
@@ -44,6 +47,8 @@ type filter struct {
 	typ      Type
 	deadline *time.Timer // filter is inactive when deadline triggers
 	hashes   []gethcmn.Hash
+	txs      []*gethtypes.Transaction
+	fullTx   bool // return full transactions instead of hashes for a pending transaction filter
 	crit     gethfilters.FilterCriteria
 	logs     []*gethtypes.Log
 	s        *Subscription // associated subscription in event system
@@ -172,6 +177,87 @@ func (api *filterAPI) NewBlockFilter() rpc.ID {
 	return headerSub.ID
 }
 
+// NewPendingTransactionFilter creates a filter that fetches pending transactions
+// as transactions enter the pending state. The full transactions are returned
+// instead of their hashes if fullTx is true.
+// It is part of the filter package because this filter can be used through the
+// `eth_getFilterChanges` polling method that is also used for log filters.
+//
+// https://eth.wiki/json-rpc/API#eth_newpendingtransactionfilter
+func (api *filterAPI) NewPendingTransactionFilter(fullTx *bool) rpc.ID {
+	var (
+		pendingTxs   = make(chan []*gethtypes.Transaction)
+		pendingTxSub = api.events.SubscribePendingTxs(pendingTxs)
+	)
+
+	api.filtersMu.Lock()
+	api.filters[pendingTxSub.ID] = &filter{
+		typ:      PendingTransactionsSubscription,
+		deadline: time.NewTimer(deadline),
+		txs:      make([]*gethtypes.Transaction, 0),
+		fullTx:   fullTx != nil && *fullTx,
+		s:        pendingTxSub,
+	}
+	api.filtersMu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case txs := <-pendingTxs:
+				api.filtersMu.Lock()
+				if f, found := api.filters[pendingTxSub.ID]; found {
+					f.txs = append(f.txs, txs...)
+				}
+				api.filtersMu.Unlock()
+			case <-pendingTxSub.Err():
+				api.filtersMu.Lock()
+				delete(api.filters, pendingTxSub.ID)
+				api.filtersMu.Unlock()
+				return
+			}
+		}
+	}()
+
+	return pendingTxSub.ID
+}
+
+// NewPendingTransactions creates a subscription that is triggered each time a
+// transaction enters the transaction pool. The full transactions are sent
+// instead of their hashes if fullTx is true.
+func (api *filterAPI) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		txs := make(chan []*gethtypes.Transaction, 128)
+		pendingTxSub := api.events.SubscribePendingTxs(txs)
+		defer pendingTxSub.Unsubscribe()
+
+		for {
+			select {
+			case txs := <-txs:
+				for _, tx := range txs {
+					if fullTx != nil && *fullTx {
+						_ = notifier.Notify(rpcSub.ID, api.newRPCPendingTransaction(tx))
+					} else {
+						_ = notifier.Notify(rpcSub.ID, tx.Hash())
+					}
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // UninstallFilter removes the filter with the given filter id.
 //
 // https://eth.wiki/json-rpc/API#eth_uninstallfilter
@@ -213,7 +299,22 @@ func (api *filterAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
 	f.deadline.Reset(deadline)
 
 	switch f.typ {
-	case /*PendingTransactionsSubscription, */ BlocksSubscription:
+	case PendingTransactionsSubscription:
+		txs := f.txs
+		f.txs = nil
+		if f.fullTx {
+			rpcTxs := make([]*rpctypes.Transaction, len(txs))
+			for i, tx := range txs {
+				rpcTxs[i] = api.newRPCPendingTransaction(tx)
+			}
+			return rpcTxs, nil
+		}
+		hashes := make([]gethcmn.Hash, len(txs))
+		for i, tx := range txs {
+			hashes[i] = tx.Hash()
+		}
+		return hashes, nil
+	case BlocksSubscription:
 		hashes := f.hashes
 		f.hashes = nil
 		return returnHashes(hashes), nil
@@ -298,3 +399,10 @@ func returnLogs(logs []*gethtypes.Log) []*gethtypes.Log {
 	}
 	return logs
 }
+
+// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func (api *filterAPI) newRPCPendingTransaction(tx *gethtypes.Transaction) *rpctypes.Transaction {
+	signer := gethtypes.NewEIP155Signer(api.backend.ChainId())
+	from, _ := gethtypes.Sender(signer, tx)
+	return rpctypes.NewRPCPendingTransaction(tx, from)
+}
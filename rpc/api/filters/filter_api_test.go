@@ -18,6 +18,7 @@ import (
 	"github.com/smartbch/moeingevm/types"
 	"github.com/smartbch/smartbch/api"
 	"github.com/smartbch/smartbch/internal/testutils"
+	rpctypes "github.com/smartbch/smartbch/rpc/internal/ethapi"
 )
 
 func TestNewFilter(t *testing.T) {
@@ -70,8 +71,12 @@ func TestGetFilterChanges_addrFilter(t *testing.T) {
 	block := testutils.NewMdbBlockBuilder().
 		Height(1).Hash(gethcmn.Hash{0xB1}).
 		Tx(gethcmn.Hash{0xC1}, types.Log{
+			Address: [20]byte{0xA2},
+			Topics:  [][32]byte{{0xD3}},
+		}, types.Log{
 			Address: [20]byte{0xA1},
 			Topics:  [][32]byte{{0xD1}, {0xD2}},
+			Data:    []byte{0xE1, 0xE2},
 		}).Build()
 	addBlock(_app, block)
 
@@ -79,6 +84,48 @@ func TestGetFilterChanges_addrFilter(t *testing.T) {
 	logs, err = _api.GetFilterChanges(id)
 	require.NoError(t, err)
 	require.Len(t, logs, 1)
+	log := logs.([]*gethtypes.Log)[0]
+	require.Equal(t, gethcmn.Address{0xA1}, log.Address)
+	require.Equal(t, []gethcmn.Hash{{0xD1}, {0xD2}}, log.Topics)
+	require.Equal(t, []byte{0xE1, 0xE2}, log.Data)
+	require.Equal(t, uint64(1), log.BlockNumber)
+	require.Equal(t, gethcmn.Hash{0xB1}, log.BlockHash)
+	require.Equal(t, gethcmn.Hash{0xC1}, log.TxHash)
+	require.Equal(t, uint(0), log.TxIndex)
+	require.Equal(t, uint(1), log.Index)
+}
+
+func TestGetFilterChanges_pendingTxFilter(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	defer _app.Destroy()
+	_api := createFiltersAPI(_app)
+	fullTx := true
+	hashFilterID := _api.NewPendingTransactionFilter(nil)
+	fullTxFilterID := _api.NewPendingTransactionFilter(&fullTx)
+
+	ret, err := _api.GetFilterChanges(hashFilterID)
+	require.NoError(t, err)
+	require.Len(t, ret, 0)
+
+	tx, _ := _app.MakeAndSignTx(key, &gethcmn.Address{0xA1}, 100, nil, 0)
+	require.Equal(t, abci.CodeTypeOK, _app.CheckNewTxABCI(tx))
+	// rechecked txs are not published again
+	_app.CheckTx(abci.RequestCheckTx{Tx: testutils.MustEncodeTx(tx), Type: abci.CheckTxType_Recheck})
+
+	_app.WaitMS(10)
+	ret, err = _api.GetFilterChanges(hashFilterID)
+	require.NoError(t, err)
+	require.Equal(t, []gethcmn.Hash{tx.Hash()}, ret)
+
+	ret, err = _api.GetFilterChanges(fullTxFilterID)
+	require.NoError(t, err)
+	rpcTxs := ret.([]*rpctypes.Transaction)
+	require.Len(t, rpcTxs, 1)
+	require.Equal(t, tx.Hash(), rpcTxs[0].Hash)
+	require.Equal(t, addr, rpcTxs[0].From)
+	require.Equal(t, gethcmn.Address{0xA1}, *rpcTxs[0].To)
+	require.Nil(t, rpcTxs[0].BlockHash)
 }
 
 /*
@@ -292,7 +339,7 @@ func TestGetLogs_addrFilter(t *testing.T) {
 }
 
 func createFiltersAPI(_app *testutils.TestApp) PublicFilterAPI {
-	backend := api.NewBackend(nil, _app.App)
+	backend := api.NewBackend(nil, _app.App, 0)
 	return NewAPI(backend)
 }
 
@@ -75,6 +75,7 @@ type subscription struct {
 	logsCrit  ethereum.FilterQuery
 	logs      chan []*types.Log
 	hashes    chan []common.Hash
+	txs       chan []*types.Transaction
 	headers   chan *motypes.Header
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
@@ -166,6 +167,7 @@ func (sub *Subscription) Unsubscribe() {
 				break uninstallLoop
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
+			case <-sub.f.txs:
 			case <-sub.f.headers:
 			}
 		}
@@ -250,6 +252,7 @@ func (es *EventSystem) subscribeLogs(crit ethereum.FilterQuery, logs chan []*typ
 		created:   time.Now(),
 		logs:      logs,
 		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   make(chan *motypes.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -283,6 +286,7 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *motypes.Header) *Subscrip
 		created:   time.Now(),
 		logs:      make(chan []*types.Log),
 		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   headers,
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -290,21 +294,22 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *motypes.Header) *Subscrip
 	return es.subscribe(sub)
 }
 
-// SubscribePendingTxs creates a subscription that writes transaction hashes for
+// SubscribePendingTxs creates a subscription that writes transactions for
 // transactions that enter the transaction pool.
-//func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscription {
-//	sub := &subscription{
-//		id:        rpc.NewID(),
-//		typ:       PendingTransactionsSubscription,
-//		created:   time.Now(),
-//		logs:      make(chan []*types.Log),
-//		hashes:    hashes,
-//		headers:   make(chan *motypes.Header),
-//		installed: make(chan struct{}),
-//		err:       make(chan error),
-//	}
-//	return es.subscribe(sub)
-//}
+func (es *EventSystem) SubscribePendingTxs(txs chan []*types.Transaction) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       PendingTransactionsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		txs:       txs,
+		headers:   make(chan *motypes.Header),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
 
 type filterIndex map[Type]map[rpc.ID]*subscription
 
@@ -342,13 +347,9 @@ func (es *EventSystem) handleRemovedLogs(filters filterIndex, ev core.RemovedLog
 }
 
 func (es *EventSystem) handleTxsEvent(filters filterIndex, ev core.NewTxsEvent) {
-	//hashes := make([]common.Hash, 0, len(ev.Txs))
-	//for _, tx := range ev.Txs {
-	//	hashes = append(hashes, tx.Hash())
-	//}
-	//for _, f := range filters[PendingTransactionsSubscription] {
-	//	f.hashes <- hashes
-	//}
+	for _, f := range filters[PendingTransactionsSubscription] {
+		f.txs <- ev.Txs
+	}
 }
 
 func (es *EventSystem) handleChainEvent(filters filterIndex, ev motypes.ChainEvent) {
@@ -1,6 +1,7 @@
 package filters
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -9,6 +10,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/eth/filters"
 
+	"github.com/smartbch/moeingevm/types"
+	"github.com/smartbch/smartbch/api"
 	"github.com/smartbch/smartbch/internal/testutils"
 )
 
@@ -50,3 +53,48 @@ func TestFilterCriteria2(t *testing.T) {
 		{testutils.HexToHash32("0x000000000000000000000000a94f5374fce5edbc8e2a8697c15331677e6ebf0b")},
 	}, fc.Topics)
 }
+
+func TestRangeFilter_bloomBits(t *testing.T) {
+	_app := testutils.CreateTestAppWithBloomBitsSection(8)
+	defer _app.Destroy()
+	backend := api.NewBackend(nil, _app.App, 0)
+
+	addr1 := common.Address{0xA1, 0x23}
+	addr2 := common.Address{0xA3, 0x45}
+	for h := int64(1); h <= 20; h++ {
+		b := testutils.NewMdbBlockBuilder().Height(h).Hash(common.Hash{0xB0, byte(h)})
+		switch h {
+		case 3, 13, 19:
+			b.Tx(common.Hash{0xC1, byte(h)}, types.Log{Address: addr1, Topics: [][32]byte{{0xD1}}})
+		case 7:
+			b.Tx(common.Hash{0xC2, byte(h)}, types.Log{Address: addr2, Topics: [][32]byte{{0xD2}}})
+		}
+		addBlock(_app, b.Build())
+	}
+	// the blocks 0~15 are indexed, and the rest are checked one by one
+	size, sections := backend.BloomStatus()
+	require.Equal(t, uint64(8), size)
+	require.Equal(t, uint64(2), sections)
+
+	logs, err := NewRangeFilter(backend, 1, 20, []common.Address{addr1}, nil).Logs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	require.Equal(t, uint64(3), logs[0].BlockNumber)
+	require.Equal(t, uint64(13), logs[1].BlockNumber)
+	require.Equal(t, uint64(19), logs[2].BlockNumber)
+
+	logs, err = NewRangeFilter(backend, 4, 15, []common.Address{addr1, addr2}, nil).Logs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	require.Equal(t, addr2, logs[0].Address)
+	require.Equal(t, addr1, logs[1].Address)
+
+	logs, err = NewRangeFilter(backend, 1, 20, nil, [][]common.Hash{{{0xD2}}}).Logs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	require.Equal(t, uint64(7), logs[0].BlockNumber)
+
+	logs, err = NewRangeFilter(backend, 8, 12, []common.Address{addr1}, nil).Logs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, logs, 0)
+}
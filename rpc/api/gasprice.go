@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	motypes "github.com/smartbch/moeingevm/types"
+
+	sbchapi "github.com/smartbch/smartbch/api"
+)
+
+const (
+	// the number of recent blocks sampled by the gas price oracle
+	gpoBlocks = 20
+	// the percentile of the sampled gas prices suggested by the gas price oracle
+	gpoPercentile = 60
+	// the number of the lowest gas prices sampled in each block
+	gpoSamplesPerBlock = 3
+	// the max number of blocks queried by eth_feeHistory
+	maxFeeHistoryBlocks = 1024
+)
+
+// gasPriceOracle suggests gas prices based on the min gas price and the gas prices paid in recent blocks.
+// smartBCH has no base fee, so a whole gas price is the priority fee.
+type gasPriceOracle struct {
+	backend sbchapi.BackendService
+
+	mtx        sync.Mutex
+	lastHeight int64
+	lastPrice  *big.Int
+}
+
+func newGasPriceOracle(backend sbchapi.BackendService) *gasPriceOracle {
+	return &gasPriceOracle{backend: backend, lastHeight: -1}
+}
+
+// The gas price accepted by CheckTx, the larger one of the current min gas price and the one taking effect in the next block
+func (gpo *gasPriceOracle) minGasPrice() *big.Int {
+	minGasPrice := gpo.backend.MinGasPrice(true)
+	if next := gpo.backend.MinGasPrice(false); next > minGasPrice {
+		minGasPrice = next
+	}
+	return new(big.Int).SetUint64(minGasPrice)
+}
+
+// SuggestPrice returns the gas price at gpoPercentile of the lowest ones paid in recent blocks,
+// which is no less than the min gas price.
+func (gpo *gasPriceOracle) SuggestPrice() (*big.Int, error) {
+	latest := gpo.backend.LatestHeight()
+	minGasPrice := gpo.minGasPrice()
+	gpo.mtx.Lock()
+	defer gpo.mtx.Unlock()
+	if gpo.lastHeight == latest && gpo.lastPrice != nil {
+		return maxBig(gpo.lastPrice, minGasPrice), nil
+	}
+
+	var prices []*big.Int
+	for h := latest; h > 0 && h > latest-gpoBlocks; h-- {
+		txs, err := gpo.backend.GetTxListByHeight(uint32(h))
+		if err != nil {
+			return nil, err
+		}
+		blockPrices := make([]*big.Int, 0, len(txs))
+		for _, tx := range txs {
+			blockPrices = append(blockPrices, new(big.Int).SetBytes(tx.GasPrice[:]))
+		}
+		sortBigs(blockPrices)
+		if len(blockPrices) > gpoSamplesPerBlock {
+			blockPrices = blockPrices[:gpoSamplesPerBlock]
+		}
+		prices = append(prices, blockPrices...)
+	}
+	price := new(big.Int)
+	if len(prices) > 0 {
+		sortBigs(prices)
+		price = prices[(len(prices)-1)*gpoPercentile/100]
+	}
+	gpo.lastHeight, gpo.lastPrice = latest, price
+	return maxBig(price, minGasPrice), nil
+}
+
+// FeeHistory returns the gas used ratios and the gas prices at rewardPercentiles (weighted by the gas used)
+// of blockCount blocks ending at lastBlock. The base fees are always zero.
+func (gpo *gasPriceOracle) FeeHistory(blockCount uint64, lastBlock int64, rewardPercentiles []float64) (*FeeHistoryResult, error) {
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid reward percentile: %f", p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return nil, fmt.Errorf("invalid reward percentile: #%d:%f > #%d:%f", i-1, rewardPercentiles[i-1], i, p)
+		}
+	}
+	latest := gpo.backend.LatestHeight()
+	if lastBlock < 0 || lastBlock > latest {
+		lastBlock = latest
+	}
+	if blockCount > maxFeeHistoryBlocks {
+		blockCount = maxFeeHistoryBlocks
+	}
+	if blockCount > uint64(lastBlock) {
+		blockCount = uint64(lastBlock)
+	}
+	if blockCount == 0 {
+		return &FeeHistoryResult{OldestBlock: (*hexutil.Big)(new(big.Int))}, nil
+	}
+
+	oldest := lastBlock - int64(blockCount) + 1
+	res := &FeeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(big.NewInt(oldest)),
+		BaseFee:      make([]*hexutil.Big, blockCount+1),
+		GasUsedRatio: make([]float64, blockCount),
+	}
+	for i := range res.BaseFee {
+		res.BaseFee[i] = (*hexutil.Big)(new(big.Int))
+	}
+	if len(rewardPercentiles) != 0 {
+		res.Reward = make([][]*hexutil.Big, blockCount)
+	}
+	for i := int64(0); i < int64(blockCount); i++ {
+		block, err := gpo.backend.BlockByNumber(oldest + i)
+		if err != nil {
+			return nil, err
+		}
+		res.GasUsedRatio[i] = float64(block.GasUsed) / blockGasLimit
+		if len(rewardPercentiles) == 0 {
+			continue
+		}
+		txs, err := gpo.backend.GetTxListByHeight(uint32(oldest + i))
+		if err != nil {
+			return nil, err
+		}
+		res.Reward[i] = rewardsAtPercentiles(txs, rewardPercentiles)
+	}
+	return res, nil
+}
+
+func rewardsAtPercentiles(txs []*motypes.Transaction, percentiles []float64) []*hexutil.Big {
+	rewards := make([]*hexutil.Big, len(percentiles))
+	if len(txs) == 0 {
+		for i := range rewards {
+			rewards[i] = (*hexutil.Big)(new(big.Int))
+		}
+		return rewards
+	}
+	sorted := make([]*motypes.Transaction, len(txs))
+	copy(sorted, txs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return new(big.Int).SetBytes(sorted[i].GasPrice[:]).Cmp(new(big.Int).SetBytes(sorted[j].GasPrice[:])) < 0
+	})
+	var totalGasUsed uint64
+	for _, tx := range sorted {
+		totalGasUsed += tx.GasUsed
+	}
+	var txIndex int
+	sumGasUsed := sorted[0].GasUsed
+	for i, p := range percentiles {
+		thresholdGasUsed := uint64(float64(totalGasUsed) * p / 100)
+		for sumGasUsed < thresholdGasUsed && txIndex < len(sorted)-1 {
+			txIndex++
+			sumGasUsed += sorted[txIndex].GasUsed
+		}
+		rewards[i] = (*hexutil.Big)(new(big.Int).SetBytes(sorted[txIndex].GasPrice[:]))
+	}
+	return rewards
+}
+
+func sortBigs(s []*big.Int) {
+	sort.Slice(s, func(i, j int) bool { return s[i].Cmp(s[j]) < 0 })
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) < 0 {
+		return b
+	}
+	return a
+}
+
+// FeeHistoryResult is the result of eth_feeHistory
+type FeeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// DecimalOrHex unmarshals a non-negative decimal or hex parameter
+type DecimalOrHex uint64
+
+func (dh *DecimalOrHex) UnmarshalJSON(data []byte) error {
+	var input string
+	if err := json.Unmarshal(data, &input); err != nil {
+		var n uint64
+		if err = json.Unmarshal(data, &n); err != nil {
+			return errors.New("invalid decimal or hex number")
+		}
+		*dh = DecimalOrHex(n)
+		return nil
+	}
+	if strings.HasPrefix(input, "0x") || strings.HasPrefix(input, "0X") {
+		n, err := hexutil.DecodeUint64(input)
+		if err != nil {
+			return err
+		}
+		*dh = DecimalOrHex(n)
+		return nil
+	}
+	n, err := strconv.ParseUint(input, 10, 64)
+	if err != nil {
+		return err
+	}
+	*dh = DecimalOrHex(n)
+	return nil
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/smartbch/smartbch/internal/testutils"
+	"github.com/smartbch/smartbch/staking"
+)
+
+func TestGasPrice(t *testing.T) {
+	key, _ := testutils.GenKeyAndAddr()
+
+	_app := testutils.CreateTestApp(key)
+	_app.WaitLock()
+	defer _app.Destroy()
+	_api := createEthAPI(_app)
+
+	price, err := _api.GasPrice()
+	require.NoError(t, err)
+	require.Equal(t, "0x0", price.String())
+
+	to := gethcmn.Address{0x01}
+	signTx := func(nonce uint64, gasPrice int64) *gethtypes.Transaction {
+		tx := gethtypes.NewTransaction(nonce, to, big.NewInt(1), 21000, big.NewInt(gasPrice), nil)
+		return testutils.MustSignTx(tx, _app.ChainID().ToBig(), key)
+	}
+	h := _app.ExecTxsInBlock(signTx(0, 30), signTx(1, 10), signTx(2, 20))
+	_app.ExecTxsInBlock(signTx(3, 50))
+
+	// the sampled prices are 10, 20, 30 and 50
+	price, err = _api.GasPrice()
+	require.NoError(t, err)
+	require.Equal(t, "0x14", price.String())
+	tip, err := _api.MaxPriorityFeePerGas()
+	require.NoError(t, err)
+	require.Equal(t, "0x14", tip.String())
+
+	// the suggested price is no less than the min gas price
+	ctx := _app.GetRunTxContext()
+	staking.SaveMinGasPrice(ctx, 100, false)
+	ctx.Close(true)
+	_app.ExecTxsInBlock()
+	price, err = _api.GasPrice()
+	require.NoError(t, err)
+	require.Equal(t, "0x64", price.String())
+
+	history, err := _api.FeeHistory(1, gethrpc.BlockNumber(h), []float64{0, 50, 100})
+	require.NoError(t, err)
+	require.Equal(t, h, history.OldestBlock.ToInt().Int64())
+	require.Equal(t, []float64{float64(21000*3) / blockGasLimit}, history.GasUsedRatio)
+	require.Equal(t, []*hexutil.Big{(*hexutil.Big)(big.NewInt(0)), (*hexutil.Big)(big.NewInt(0))}, history.BaseFee)
+	require.Equal(t, [][]*hexutil.Big{{
+		(*hexutil.Big)(big.NewInt(10)), (*hexutil.Big)(big.NewInt(20)), (*hexutil.Big)(big.NewInt(30)),
+	}}, history.Reward)
+
+	history, err = _api.FeeHistory(3, gethrpc.LatestBlockNumber, nil)
+	require.NoError(t, err)
+	require.Len(t, history.GasUsedRatio, 3)
+	require.Nil(t, history.Reward)
+
+	_, err = _api.FeeHistory(1, gethrpc.LatestBlockNumber, []float64{50, 10})
+	require.Error(t, err)
+}
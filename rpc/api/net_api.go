@@ -2,6 +2,8 @@ package api
 
 import (
 	"fmt"
+
+	"github.com/smartbch/smartbch/api"
 )
 
 var _ PublicNetAPI = (*netAPI)(nil)
@@ -14,11 +16,13 @@ type PublicNetAPI interface {
 
 type netAPI struct {
 	networkID uint64
+	backend   api.BackendService
 }
 
-func newNetAPI(networkID uint64) PublicNetAPI {
+func newNetAPI(networkID uint64, backend api.BackendService) PublicNetAPI {
 	return netAPI{
 		networkID: networkID,
+		backend:   backend,
 	}
 }
 
@@ -29,10 +33,10 @@ func (n netAPI) Version() string {
 
 // https://eth.wiki/json-rpc/API#net_listening
 func (n netAPI) Listening() bool {
-	return true
+	return n.backend.IsListening()
 }
 
 // https://eth.wiki/json-rpc/API#net_peerCount
 func (n netAPI) PeerCount() int {
-	return 0
+	return n.backend.PeerCount()
 }
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tendermint/tendermint/libs/log"
+
+	rpctypes "github.com/smartbch/smartbch/rpc/internal/ethapi"
+)
+
+// the same as geth, an account is unlocked for 5 minutes by default
+const defaultUnlockDuration = 300
+
+var _ PersonalAPI = (*personalAPI)(nil)
+
+// https://geth.ethereum.org/docs/rpc/ns-personal
+type PersonalAPI interface {
+	NewAccount(password string) (common.Address, error)
+	ListAccounts() []common.Address
+	UnlockAccount(addr common.Address, password string, duration *uint64) (bool, error)
+	LockAccount(addr common.Address) bool
+	SendTransaction(args rpctypes.SendTxArgs, passwd string) (common.Hash, error)
+	Sign(data hexutil.Bytes, addr common.Address, passwd string) (hexutil.Bytes, error)
+	EcRecover(data, sig hexutil.Bytes) (common.Address, error)
+}
+
+type personalAPI struct {
+	eth    *ethAPI
+	ks     *keystore.KeyStore
+	logger log.Logger
+}
+
+func newPersonalAPI(eth *ethAPI, ks *keystore.KeyStore, logger log.Logger) PersonalAPI {
+	return &personalAPI{
+		eth:    eth,
+		ks:     ks,
+		logger: logger.With("module", "personal-api"),
+	}
+}
+
+// NewAccount creates a keystore account encrypted by password
+func (p *personalAPI) NewAccount(password string) (common.Address, error) {
+	p.logger.Debug("personal_newAccount")
+	acc, err := p.ks.NewAccount(password)
+	if err != nil {
+		return common.Address{}, err
+	}
+	p.logger.Info("created a keystore account", "address", acc.Address.Hex())
+	return acc.Address, nil
+}
+
+// ListAccounts returns the addresses of the keystore accounts
+func (p *personalAPI) ListAccounts() []common.Address {
+	p.logger.Debug("personal_listAccounts")
+	accs := p.ks.Accounts()
+	addrs := make([]common.Address, len(accs))
+	for i, acc := range accs {
+		addrs[i] = acc.Address
+	}
+	sortAddresses(addrs)
+	return addrs
+}
+
+// UnlockAccount unlocks a keystore account for duration seconds, 0 means until it is locked or the node exits
+func (p *personalAPI) UnlockAccount(addr common.Address, password string, duration *uint64) (bool, error) {
+	p.logger.Debug("personal_unlockAccount")
+	const maxDuration = uint64(math.MaxInt64 / int64(time.Second))
+	d := uint64(defaultUnlockDuration)
+	if duration != nil {
+		d = *duration
+	}
+	if d > maxDuration {
+		return false, errors.New("unlock duration too large")
+	}
+	err := p.ks.TimedUnlock(accounts.Account{Address: addr}, password, time.Duration(d)*time.Second)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LockAccount locks an unlocked keystore account
+func (p *personalAPI) LockAccount(addr common.Address) bool {
+	p.logger.Debug("personal_lockAccount")
+	return p.ks.Lock(addr) == nil
+}
+
+// SendTransaction signs a tx with a keystore account decrypted by passwd, and broadcasts it
+func (p *personalAPI) SendTransaction(args rpctypes.SendTxArgs, passwd string) (common.Hash, error) {
+	p.logger.Debug("personal_sendTransaction")
+	return p.eth.signAndSendTx(args, func(tx *gethtypes.Transaction, chainID *big.Int) (*gethtypes.Transaction, error) {
+		return p.eth.accounts.signTxWithPassphrase(args.From, passwd, tx, chainID)
+	})
+}
+
+// Sign signs data with the prefix of EIP-191 like eth_sign, using a keystore account decrypted by passwd
+func (p *personalAPI) Sign(data hexutil.Bytes, addr common.Address, passwd string) (hexutil.Bytes, error) {
+	p.logger.Debug("personal_sign")
+	sig, err := p.eth.accounts.signHashWithPassphrase(addr, passwd, accounts.TextHash(data))
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return sig, nil
+}
+
+// EcRecover returns the address which signed data by personal_sign or eth_sign
+func (p *personalAPI) EcRecover(data, sig hexutil.Bytes) (common.Address, error) {
+	p.logger.Debug("personal_ecRecover")
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, errors.New("signature must be 65 bytes long")
+	}
+	if sig[crypto.RecoveryIDOffset] != 27 && sig[crypto.RecoveryIDOffset] != 28 {
+		return common.Address{}, errors.New("invalid Ethereum signature (V is not 27 or 28)")
+	}
+	sig = common.CopyBytes(sig)
+	sig[crypto.RecoveryIDOffset] -= 27 // Transform yellow paper V from 27/28 to 0/1
+	pubKey, err := crypto.SigToPub(accounts.TextHash(data), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
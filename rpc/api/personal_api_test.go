@@ -0,0 +1,142 @@
+package api
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/smartbch/smartbch/api"
+	"github.com/smartbch/smartbch/internal/testutils"
+)
+
+func TestPersonalAccounts(t *testing.T) {
+	key, addr := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	_app.WaitLock()
+	defer _app.Destroy()
+
+	dir, err := ioutil.TempDir("", "keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	backend := createEthAPI(_app).backend
+	_ethAPI := newEthAPI(backend, newAccountManager([]string{key}, ks, true, _app.Logger()), _app.Logger())
+	_api := newPersonalAPI(_ethAPI, ks, _app.Logger())
+
+	acc1, err := _api.NewAccount("pass1")
+	require.NoError(t, err)
+	acc2, err := _api.NewAccount("pass2")
+	require.NoError(t, err)
+	keystoreAccs := []gethcmn.Address{acc1, acc2}
+	sortAddresses(keystoreAccs)
+	require.Equal(t, keystoreAccs, _api.ListAccounts())
+
+	// eth_accounts includes both the test keys and the keystore accounts
+	accs, err := _ethAPI.Accounts()
+	require.NoError(t, err)
+	require.Len(t, accs, 3)
+	require.Contains(t, accs, addr)
+	require.Contains(t, accs, acc1)
+	require.Contains(t, accs, acc2)
+
+	chainID := _app.ChainID().ToBig()
+	tx := gethtypes.NewTransaction(0, gethcmn.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	_, err = _ethAPI.accounts.signTx(acc1, tx, chainID)
+	require.Equal(t, keystore.ErrLocked, err)
+
+	_, err = _api.UnlockAccount(acc1, "pass2", nil)
+	require.Error(t, err)
+	ok, err := _api.UnlockAccount(acc1, "pass1", nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	signed, err := _ethAPI.accounts.signTx(acc1, tx, chainID)
+	require.NoError(t, err)
+	sender, err := gethtypes.Sender(gethtypes.NewEIP155Signer(chainID), signed)
+	require.NoError(t, err)
+	require.Equal(t, acc1, sender)
+
+	require.True(t, _api.LockAccount(acc1))
+	_, err = _ethAPI.accounts.signTx(acc1, tx, chainID)
+	require.Equal(t, keystore.ErrLocked, err)
+
+	// the account is unlocked only for signing with the passphrase
+	signed, err = _ethAPI.accounts.signTxWithPassphrase(acc2, "pass2", tx, chainID)
+	require.NoError(t, err)
+	sender, err = gethtypes.Sender(gethtypes.NewEIP155Signer(chainID), signed)
+	require.NoError(t, err)
+	require.Equal(t, acc2, sender)
+	_, err = _ethAPI.accounts.signTxWithPassphrase(acc2, "pass1", tx, chainID)
+	require.Error(t, err)
+	_, err = _ethAPI.accounts.signTxWithPassphrase(addr, "", tx, chainID)
+	require.EqualError(t, err, "unknown account: "+addr.Hex())
+
+	// personal_sign and eth_sign give the same signature
+	data := hexutil.Bytes("hello")
+	sig, err := _api.Sign(data, acc2, "pass2")
+	require.NoError(t, err)
+	signer, err := _api.EcRecover(data, sig)
+	require.NoError(t, err)
+	require.Equal(t, acc2, signer)
+	_, err = _api.Sign(data, acc2, "pass1")
+	require.Error(t, err)
+	_, err = _ethAPI.Sign(acc2, data)
+	require.Equal(t, keystore.ErrLocked, err)
+	_, err = _api.UnlockAccount(acc2, "pass2", nil)
+	require.NoError(t, err)
+	sig2, err := _ethAPI.Sign(acc2, data)
+	require.NoError(t, err)
+	require.Equal(t, sig, sig2)
+}
+
+func TestGetAdminAPIs_personal(t *testing.T) {
+	key, _ := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	defer _app.Destroy()
+	backend := api.NewBackend(nil, _app.App, 0)
+
+	dir, err := ioutil.TempDir("", "keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+
+	// the filters are also in the eth namespace, only the first service of a namespace is kept
+	services := func(apis []rpc.API) map[string]interface{} {
+		m := make(map[string]interface{})
+		for _, a := range apis {
+			if _, ok := m[a.Namespace]; !ok {
+				m[a.Namespace] = a.Service
+			}
+		}
+		return m
+	}
+	public := services(GetAPIs(backend, _app.Logger(), []string{key}, ks, false))
+	require.NotContains(t, public, namespacePersonal)
+	require.NotContains(t, services(GetAdminAPIs(backend, _app.Logger(), nil, []string{key}, nil)), namespacePersonal)
+	admin := services(GetAdminAPIs(backend, _app.Logger(), nil, []string{key}, ks))
+	require.Contains(t, admin, namespacePersonal)
+	require.Contains(t, admin, namespaceEth)
+
+	// an account unlocked on the admin listener is listed by the public eth namespace, but can not sign there
+	_personalAPI := admin[namespacePersonal].(PersonalAPI)
+	acc, err := _personalAPI.NewAccount("pass")
+	require.NoError(t, err)
+	_, err = _personalAPI.UnlockAccount(acc, "pass", nil)
+	require.NoError(t, err)
+	data := hexutil.Bytes("hello")
+	_, err = admin[namespaceEth].(*ethAPI).Sign(acc, data)
+	require.NoError(t, err)
+	_, err = public[namespaceEth].(*ethAPI).Sign(acc, data)
+	require.Equal(t, errKeystoreSigningNotAllowed, err)
+	accs, err := public[namespaceEth].(*ethAPI).Accounts()
+	require.NoError(t, err)
+	require.Contains(t, accs, acc)
+}
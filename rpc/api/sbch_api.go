@@ -14,7 +14,7 @@ import (
 var _ SbchAPI = (*sbchAPI)(nil)
 
 type SbchAPI interface {
-	GetStandbyTxQueue()
+	GetStandbyTxQueue() *rpctypes.StandbyTxQueue
 	QueryTxBySrc(addr gethcmn.Address, startHeight, endHeight gethrpc.BlockNumber, limit hexutil.Uint64) ([]*rpctypes.Transaction, error)
 	QueryTxByDst(addr gethcmn.Address, startHeight, endHeight gethrpc.BlockNumber, limit hexutil.Uint64) ([]*rpctypes.Transaction, error)
 	QueryTxByAddr(addr gethcmn.Address, startHeight, endHeight gethrpc.BlockNumber, limit hexutil.Uint64) ([]*rpctypes.Transaction, error)
@@ -32,8 +32,20 @@ func newSbchAPI(backend sbchapi.BackendService) SbchAPI {
 	return sbchAPI{backend: backend}
 }
 
-func (sbch sbchAPI) GetStandbyTxQueue() {
-	panic("implement me")
+func (sbch sbchAPI) GetStandbyTxQueue() *rpctypes.StandbyTxQueue {
+	txs, qLen := sbch.backend.GetStandbyTxQueue()
+	queue := &rpctypes.StandbyTxQueue{
+		Length: hexutil.Uint64(qLen),
+		Txs:    make([]*rpctypes.StandbyTx, len(txs)),
+	}
+	for i, tx := range txs {
+		queue.Txs[i] = &rpctypes.StandbyTx{
+			Hash:  tx.Hash,
+			From:  tx.From,
+			Nonce: hexutil.Uint64(tx.Nonce),
+		}
+	}
+	return queue
 }
 
 func (sbch sbchAPI) GetTxListByHeight(height gethrpc.BlockNumber) ([]map[string]interface{}, error) {
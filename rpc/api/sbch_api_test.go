@@ -330,6 +330,6 @@ func TestQueryLogs_limit(t *testing.T) {
 }
 
 func createSbchAPI(_app *testutils.TestApp) SbchAPI {
-	backend := api.NewBackend(nil, _app.App)
+	backend := api.NewBackend(nil, _app.App, 0)
 	return newSbchAPI(backend)
 }
@@ -1,7 +1,13 @@
 package api
 
 import (
+	"fmt"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	sbchapi "github.com/smartbch/smartbch/api"
 	rpctypes "github.com/smartbch/smartbch/rpc/internal/ethapi"
 )
 
@@ -14,28 +20,70 @@ type PublicTxPoolAPI interface {
 }
 
 type txPoolAPI struct {
+	backend sbchapi.BackendService
+}
+
+func newTxPoolAPI(backend sbchapi.BackendService) PublicTxPoolAPI {
+	return txPoolAPI{backend: backend}
 }
 
-func (txPoolAPI) Content() map[string]map[string]map[string]*rpctypes.Transaction {
+// Content returns the transactions contained within the mempool, grouped by sender and nonce
+func (api txPoolAPI) Content() map[string]map[string]map[string]*rpctypes.Transaction {
+	pending, queued := api.backend.TxPoolContent()
 	content := map[string]map[string]map[string]*rpctypes.Transaction{
-		"pending": make(map[string]map[string]*rpctypes.Transaction),
-		"queued":  make(map[string]map[string]*rpctypes.Transaction),
+		"pending": make(map[string]map[string]*rpctypes.Transaction, len(pending)),
+		"queued":  make(map[string]map[string]*rpctypes.Transaction, len(queued)),
+	}
+	for kind, txsBySender := range map[string]map[gethcmn.Address]gethtypes.Transactions{"pending": pending, "queued": queued} {
+		for sender, txs := range txsBySender {
+			dump := make(map[string]*rpctypes.Transaction, len(txs))
+			for _, tx := range txs {
+				dump[fmt.Sprintf("%d", tx.Nonce())] = rpctypes.NewRPCPendingTransaction(tx, sender)
+			}
+			content[kind][sender.Hex()] = dump
+		}
 	}
 	return content
 }
 
-func (txPoolAPI) Status() map[string]hexutil.Uint {
+// Status returns the number of pending and queued transactions in the mempool
+func (api txPoolAPI) Status() map[string]hexutil.Uint {
 	pending, queue := 0, 0
+	pendingTxs, queuedTxs := api.backend.TxPoolContent()
+	for _, txs := range pendingTxs {
+		pending += len(txs)
+	}
+	for _, txs := range queuedTxs {
+		queue += len(txs)
+	}
 	return map[string]hexutil.Uint{
 		"pending": hexutil.Uint(pending),
 		"queued":  hexutil.Uint(queue),
 	}
 }
 
-func (txPoolAPI) Inspect() map[string]map[string]map[string]string {
+// Inspect returns a textual summary of the transactions in the mempool, which is easy to be read by human
+func (api txPoolAPI) Inspect() map[string]map[string]map[string]string {
+	pending, queued := api.backend.TxPoolContent()
 	content := map[string]map[string]map[string]string{
-		"pending": make(map[string]map[string]string),
-		"queued":  make(map[string]map[string]string),
+		"pending": make(map[string]map[string]string, len(pending)),
+		"queued":  make(map[string]map[string]string, len(queued)),
+	}
+	for kind, txsBySender := range map[string]map[gethcmn.Address]gethtypes.Transactions{"pending": pending, "queued": queued} {
+		for sender, txs := range txsBySender {
+			dump := make(map[string]string, len(txs))
+			for _, tx := range txs {
+				dump[fmt.Sprintf("%d", tx.Nonce())] = formatPoolTx(tx)
+			}
+			content[kind][sender.Hex()] = dump
+		}
 	}
 	return content
 }
+
+func formatPoolTx(tx *gethtypes.Transaction) string {
+	if to := tx.To(); to != nil {
+		return fmt.Sprintf("%s: %v wei + %v gas × %v wei", to.Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
+	}
+	return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gethcmn "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	sbchapi "github.com/smartbch/smartbch/api"
+)
+
+// a backend whose mempool is faked, since there is no tendermint node in unit tests
+type txPoolBackend struct {
+	sbchapi.BackendService
+	pending, queued map[gethcmn.Address]gethtypes.Transactions
+}
+
+func (b txPoolBackend) TxPoolContent() (pending, queued map[gethcmn.Address]gethtypes.Transactions) {
+	return b.pending, b.queued
+}
+
+func TestTxPoolAPI(t *testing.T) {
+	addr1 := gethcmn.Address{0xA1}
+	addr2 := gethcmn.Address{0xA2}
+	to := gethcmn.Address{0xB1}
+	tx0 := gethtypes.NewTransaction(0, to, big.NewInt(100), 21000, big.NewInt(10), nil)
+	tx1 := gethtypes.NewTransaction(1, to, big.NewInt(200), 21000, big.NewInt(10), nil)
+	tx5 := gethtypes.NewContractCreation(5, big.NewInt(0), 100000, big.NewInt(20), []byte{0x60})
+
+	_api := newTxPoolAPI(txPoolBackend{
+		pending: map[gethcmn.Address]gethtypes.Transactions{addr1: {tx0, tx1}},
+		queued:  map[gethcmn.Address]gethtypes.Transactions{addr2: {tx5}},
+	})
+
+	require.Equal(t, map[string]hexutil.Uint{"pending": 2, "queued": 1}, _api.Status())
+
+	content := _api.Content()
+	require.Len(t, content["pending"][addr1.Hex()], 2)
+	require.Equal(t, tx1.Hash(), content["pending"][addr1.Hex()]["1"].Hash)
+	require.Equal(t, addr1, content["pending"][addr1.Hex()]["1"].From)
+	require.Equal(t, hexutil.Uint64(5), content["queued"][addr2.Hex()]["5"].Nonce)
+	require.Nil(t, content["queued"][addr2.Hex()]["5"].To)
+
+	inspect := _api.Inspect()
+	require.Equal(t, to.Hex()+": 100 wei + 21000 gas × 10 wei", inspect["pending"][addr1.Hex()]["0"])
+	require.Equal(t, "contract creation: 0 wei + 100000 gas × 20 wei", inspect["queued"][addr2.Hex()]["5"])
+}
@@ -1,8 +1,13 @@
 package ethapi
 
 import (
+	"bytes"
+	"encoding/json"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	signercore "github.com/ethereum/go-ethereum/signer/core"
 )
 
 // Copied the Account and StorageResult types since they are registered under an
@@ -44,6 +49,38 @@ type Transaction struct {
 	S                *hexutil.Big    `json:"s"`
 }
 
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction, from common.Address) *Transaction {
+	v, r, s := tx.RawSignatureValues()
+	return &Transaction{
+		From:     from,
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Hash:     tx.Hash(),
+		Input:    hexutil.Bytes(tx.Data()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		To:       tx.To(),
+		Value:    (*hexutil.Big)(tx.Value()),
+		V:        (*hexutil.Big)(v),
+		R:        (*hexutil.Big)(r),
+		S:        (*hexutil.Big)(s),
+	}
+}
+
+// StandbyTxQueue represents the committed transactions which are waiting to be executed,
+// returned by sbch_getStandbyTxQueue.
+type StandbyTxQueue struct {
+	Length hexutil.Uint64 `json:"length"`
+	Txs    []*StandbyTx   `json:"txs"`
+}
+
+// StandbyTx represents a transaction in StandbyTxQueue.
+type StandbyTx struct {
+	Hash  common.Hash    `json:"hash"`
+	From  common.Address `json:"from"`
+	Nonce hexutil.Uint64 `json:"nonce"`
+}
+
 // SendTxArgs represents the arguments to submit a new transaction into the transaction pool.
 // Duplicate struct definition since geth struct is in internal package
 // Ref: https://github.com/ethereum/go-ethereum/blob/release/1.9/internal/ethapi/api.go#L1346
@@ -83,3 +120,40 @@ type Account struct {
 	State     *map[common.Hash]common.Hash `json:"state"`
 	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
 }
+
+// TypedData is the EIP-712 typed data of eth_signTypedData_v4. Besides a JSON object, it can be
+// a JSON string of the object as MetaMask sends, and domain.chainId can be a JSON number.
+type TypedData struct {
+	signercore.TypedData
+}
+
+func (td *TypedData) UnmarshalJSON(input []byte) error {
+	input = bytes.TrimSpace(input)
+	if len(input) > 0 && input[0] == '"' {
+		var str string
+		if err := json.Unmarshal(input, &str); err != nil {
+			return err
+		}
+		input = []byte(str)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return err
+	}
+	var domain map[string]json.RawMessage
+	if raw, ok := fields["domain"]; ok {
+		if err := json.Unmarshal(raw, &domain); err != nil {
+			return err
+		}
+	}
+	if chainID, ok := domain["chainId"]; ok {
+		var num json.Number
+		if json.Unmarshal(chainID, &num) == nil {
+			domain["chainId"], _ = json.Marshal(num.String())
+			fields["domain"], _ = json.Marshal(domain)
+			input, _ = json.Marshal(fields)
+		}
+	}
+	return json.Unmarshal(input, &td.TypedData)
+}
@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	tmlog "github.com/tendermint/tendermint/libs/log"
+)
+
+const (
+	jwtSecretLength = 32
+	// the same as geth, a token must be issued within this duration around the current time
+	jwtExpiryTimeout = 60 * time.Second
+)
+
+// loadJWTSecret reads the hex-encoded 32-byte secret of HS256 from file, which is generated if it does not exist
+func loadJWTSecret(file string, logger tmlog.Logger) ([]byte, error) {
+	if data, err := ioutil.ReadFile(file); err == nil {
+		secret := common.FromHex(strings.TrimSpace(string(data)))
+		if len(secret) != jwtSecretLength {
+			return nil, fmt.Errorf("invalid JWT secret in %s, it must be %d bytes in hex", file, jwtSecretLength)
+		}
+		return secret, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	secret := make([]byte, jwtSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(file, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		return nil, err
+	}
+	logger.Info("generated the JWT secret", "path", file)
+	return secret, nil
+}
+
+// jwtHandler only passes the requests carrying a valid token in the header "Authorization: Bearer <token>"
+type jwtHandler struct {
+	secret []byte
+	next   http.Handler
+}
+
+func newJWTHandler(secret []byte, next http.Handler) http.Handler {
+	return &jwtHandler{secret: secret, next: next}
+}
+
+func (h *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return
+	}
+	if err := verifyJWT(h.secret, strings.TrimPrefix(auth, "Bearer "), time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	IssuedAt  *int64 `json:"iat"`
+	ExpiresAt *int64 `json:"exp"`
+}
+
+// verifyJWT checks an HS256 token whose "iat" claim is required, like the engine API of geth
+func verifyJWT(secret []byte, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "HS256" {
+		return fmt.Errorf("unexpected signing method: %s", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return errors.New("signature is invalid")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return err
+	}
+	if claims.IssuedAt == nil {
+		return errors.New("missing issued-at")
+	}
+	issuedAt := time.Unix(*claims.IssuedAt, 0)
+	if issuedAt.Before(now.Add(-jwtExpiryTimeout)) || issuedAt.After(now.Add(jwtExpiryTimeout)) {
+		return errors.New("stale token")
+	}
+	if claims.ExpiresAt != nil && now.Unix() > *claims.ExpiresAt {
+		return errors.New("token is expired")
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func signJWT(secret []byte, alg, claims string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(fmt.Sprintf(`{"alg":"%s","typ":"JWT"}`, alg))) + "." +
+		enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	secret := make([]byte, 32)
+	secret[0] = 1
+	now := time.Now()
+	iat := func(d time.Duration) string {
+		return fmt.Sprintf(`{"iat":%d}`, now.Add(d).Unix())
+	}
+
+	require.NoError(t, verifyJWT(secret, signJWT(secret, "HS256", iat(0)), now))
+	require.NoError(t, verifyJWT(secret, signJWT(secret, "HS256", iat(-30*time.Second)), now))
+	require.EqualError(t, verifyJWT(secret, signJWT(secret, "HS256", iat(-2*time.Minute)), now), "stale token")
+	require.EqualError(t, verifyJWT(secret, signJWT(secret, "HS256", iat(2*time.Minute)), now), "stale token")
+	require.EqualError(t, verifyJWT(secret, signJWT(secret, "HS256", `{}`), now), "missing issued-at")
+	require.EqualError(t, verifyJWT(secret, signJWT(secret, "HS256",
+		fmt.Sprintf(`{"iat":%d,"exp":%d}`, now.Unix(), now.Unix()-1)), now), "token is expired")
+	require.EqualError(t, verifyJWT(secret, signJWT(secret, "none", iat(0)), now), "unexpected signing method: none")
+	require.EqualError(t, verifyJWT(secret, signJWT(make([]byte, 32), "HS256", iat(0)), now), "signature is invalid")
+	require.EqualError(t, verifyJWT(secret, "abc.def", now), "malformed token")
+}
+
+func TestJWTHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-jwt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config", "jwtsecret")
+
+	// the secret is generated for the first time
+	secret, err := loadJWTSecret(file, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+	loaded, err := loadJWTSecret(file, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, secret, loaded)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("0x1234\n"), 0600))
+	_, err = loadJWTSecret(file, log.NewNopLogger())
+	require.Error(t, err)
+
+	handler := newJWTHandler(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	check := func(auth string, status int) {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, status, w.Code)
+	}
+	token := signJWT(secret, "HS256", fmt.Sprintf(`{"iat":%d}`, time.Now().Unix()))
+	check("Bearer "+token, http.StatusOK)
+	check(token, http.StatusUnauthorized)
+	check("", http.StatusUnauthorized)
+	check("Bearer "+signJWT([]byte("wrong"), "HS256", fmt.Sprintf(`{"iat":%d}`, time.Now().Unix())),
+		http.StatusUnauthorized)
+}
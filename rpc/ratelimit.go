@@ -0,0 +1,323 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tmlog "github.com/tendermint/tendermint/libs/log"
+)
+
+// The JSON-RPC error codes of the rejected requests,
+// -32008 is used by api.ErrTooManyHistoryQueries
+const (
+	rateLimitedErrorCode      = -32005
+	batchTooLargeErrorCode    = -32006
+	responseTooLargeErrorCode = -32007
+)
+
+const (
+	DefaultMaxBatchSize    = 1000
+	DefaultMaxResponseSize = 25 * 1024 * 1024
+
+	// the same as the limit of go-ethereum's HTTP server, larger requests are rejected by it
+	maxRequestContentLength = 1024 * 1024 * 5
+	// how often the buckets refilled to full are dropped
+	bucketPruneInterval = time.Minute
+)
+
+// DefaultMethodCosts lists the methods taking more than one token, they query the history store or run the EVM
+var DefaultMethodCosts = map[string]int{
+	"eth_call":               2,
+	"eth_estimateGas":        2,
+	"eth_getBlockReceipts":   5,
+	"eth_getLogs":            10,
+	"eth_getFilterLogs":      10,
+	"sbch_queryLogs":         10,
+	"sbch_queryTxBySrc":      5,
+	"sbch_queryTxByDst":      5,
+	"sbch_queryTxByAddr":     5,
+	"debug_traceCall":        5,
+	"debug_traceTransaction": 10,
+}
+
+type RateLimitConfig struct {
+	// The tokens refilled per second into the bucket of each client IP, 0 means unlimited
+	RequestsPerSecond float64
+	// The capacity of the bucket of each client IP, 0 means the same as RequestsPerSecond
+	Burst int
+	// The max number of requests in a batch, 0 means unlimited
+	MaxBatchSize int
+	// The max size in bytes of a response over HTTP, 0 means unlimited
+	MaxResponseSize int
+	// The tokens taken by each method, the ones not listed take one token
+	MethodCosts map[string]int
+}
+
+// ParseMethodCosts overrides DefaultMethodCosts with the costs in the format of <method>=<cost>
+func ParseMethodCosts(ss []string) (map[string]int, error) {
+	costs := make(map[string]int, len(DefaultMethodCosts)+len(ss))
+	for method, cost := range DefaultMethodCosts {
+		costs[method] = cost
+	}
+	for _, s := range ss {
+		fields := strings.Split(s, "=")
+		if len(fields) != 2 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid method cost: %s", s)
+		}
+		cost, err := strconv.Atoi(fields[1])
+		if err != nil || cost < 0 {
+			return nil, fmt.Errorf("invalid method cost: %s", s)
+		}
+		costs[fields[0]] = cost
+	}
+	return costs, nil
+}
+
+// rateLimiter throttles the JSON-RPC requests of each client IP, over HTTP and through the WebSocket
+// connections (see websocket.go). The WebSocket handshakes take one token each.
+type rateLimiter struct {
+	config  RateLimitConfig
+	buckets *tokenBuckets // nil means unlimited
+	logger  tmlog.Logger
+}
+
+func newRateLimiter(config RateLimitConfig, logger tmlog.Logger) *rateLimiter {
+	limiter := &rateLimiter{
+		config: config,
+		logger: logger.With("module", "rate-limiter"),
+	}
+	if config.RequestsPerSecond > 0 {
+		burst := float64(config.Burst)
+		if burst <= 0 {
+			burst = math.Max(1, config.RequestsPerSecond)
+		}
+		limiter.buckets = newTokenBuckets(config.RequestsPerSecond, burst)
+	}
+	return limiter
+}
+
+func (limiter *rateLimiter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter.serveHTTP(next, w, r)
+	})
+}
+
+func (limiter *rateLimiter) serveHTTP(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	var calls []jsonrpcCall
+	var isBatch bool
+	if r.Method == http.MethodPost && r.Body != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestContentLength+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		calls, isBatch = parseCalls(body)
+	}
+	// the errors of a batch are not bound to any of its requests
+	var id json.RawMessage
+	if !isBatch && len(calls) == 1 {
+		id = calls[0].ID
+	}
+
+	if code, msg := limiter.admit(clientIP(r), calls, isBatch); code != 0 {
+		status := http.StatusOK
+		if code == rateLimitedErrorCode {
+			status = http.StatusTooManyRequests
+		}
+		writeJSONRPCError(w, status, id, code, msg)
+		return
+	}
+	if r.Method != http.MethodPost || limiter.config.MaxResponseSize <= 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	lw := &limitedResponseWriter{ResponseWriter: w, limit: limiter.config.MaxResponseSize}
+	next.ServeHTTP(lw, r)
+	if lw.exceeded {
+		writeJSONRPCError(w, http.StatusOK, id, responseTooLargeErrorCode,
+			fmt.Sprintf("response too large, the max is %d bytes", limiter.config.MaxResponseSize))
+		return
+	}
+	if lw.status != 0 {
+		w.WriteHeader(lw.status)
+	}
+	_, _ = w.Write(lw.buf.Bytes())
+}
+
+// Checks the batch size and takes the tokens of the calls from the bucket of ip,
+// returns the error code and message if the calls are rejected, or a zero code otherwise
+func (limiter *rateLimiter) admit(ip string, calls []jsonrpcCall, isBatch bool) (code int, msg string) {
+	if isBatch && limiter.config.MaxBatchSize > 0 && len(calls) > limiter.config.MaxBatchSize {
+		return batchTooLargeErrorCode,
+			fmt.Sprintf("batch too large: %d requests, the max is %d", len(calls), limiter.config.MaxBatchSize)
+	}
+	if limiter.buckets != nil && !limiter.buckets.take(ip, limiter.cost(calls), time.Now()) {
+		limiter.logger.Debug("rate limited", "ip", ip)
+		return rateLimitedErrorCode, "rate limit exceeded, please retry later"
+	}
+	return 0, ""
+}
+
+// The tokens taken by the calls, an empty or invalid request takes one token
+func (limiter *rateLimiter) cost(calls []jsonrpcCall) int {
+	total := 0
+	for _, call := range calls {
+		if cost, ok := limiter.config.MethodCosts[call.Method]; ok {
+			total += cost
+		} else {
+			total++
+		}
+	}
+	if total == 0 {
+		total = 1
+	}
+	return total
+}
+
+type jsonrpcCall struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+// Only the ids and methods are needed here, the malformed requests are left to the JSON-RPC server
+func parseCalls(body []byte) (calls []jsonrpcCall, isBatch bool) {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	if len(body) > 0 && body[0] == '[' {
+		_ = json.Unmarshal(body, &calls)
+		return calls, true
+	}
+	var call jsonrpcCall
+	if json.Unmarshal(body, &call) == nil {
+		calls = append(calls, call)
+	}
+	return calls, false
+}
+
+func writeJSONRPCError(w http.ResponseWriter, status int, id json.RawMessage, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(jsonrpcError(id, code, msg))
+}
+
+func jsonrpcError(id json.RawMessage, code int, msg string) []byte {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	resp, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": msg,
+		},
+	})
+	return resp
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitedResponseWriter buffers a response, which is dropped once it grows beyond the limit
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	limit    int
+	status   int
+	buf      bytes.Buffer
+	exceeded bool
+}
+
+func (w *limitedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *limitedResponseWriter) Write(p []byte) (int, error) {
+	if w.exceeded {
+		return len(p), nil
+	}
+	if w.buf.Len()+len(p) > w.limit {
+		w.exceeded = true
+		w.buf = bytes.Buffer{}
+		return len(p), nil
+	}
+	return w.buf.Write(p)
+}
+
+// tokenBuckets keeps a token bucket for each client
+type tokenBuckets struct {
+	mtx       sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newTokenBuckets(rate, burst float64) *tokenBuckets {
+	return &tokenBuckets{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Takes cost tokens from the bucket of key, the cost larger than the capacity takes a full bucket
+func (tb *tokenBuckets) take(key string, cost int, now time.Time) bool {
+	tb.mtx.Lock()
+	defer tb.mtx.Unlock()
+	tb.prune(now)
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: tb.burst, updated: now}
+		tb.buckets[key] = b
+	}
+	b.tokens = tb.refill(b, now)
+	b.updated = now
+	c := math.Min(float64(cost), tb.burst)
+	if b.tokens < c {
+		return false
+	}
+	b.tokens -= c
+	return true
+}
+
+func (tb *tokenBuckets) refill(b *tokenBucket, now time.Time) float64 {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(tb.burst, b.tokens+elapsed*tb.rate)
+}
+
+// A bucket refilled to full is the same as a new one, so it is dropped to bound the memory
+func (tb *tokenBuckets) prune(now time.Time) {
+	if now.Sub(tb.lastPrune) < bucketPruneInterval {
+		return
+	}
+	tb.lastPrune = now
+	for key, b := range tb.buckets {
+		if tb.refill(b, now) >= tb.burst {
+			delete(tb.buckets, key)
+		}
+	}
+}
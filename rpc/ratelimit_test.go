@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+func TestTokenBuckets(t *testing.T) {
+	tb := newTokenBuckets(2, 4)
+	now := time.Now()
+	require.True(t, tb.take("a", 3, now))
+	require.False(t, tb.take("a", 2, now))
+	require.True(t, tb.take("b", 4, now))
+
+	// refilled by 2 tokens per second
+	require.True(t, tb.take("a", 3, now.Add(time.Second)))
+	require.False(t, tb.take("a", 1, now.Add(time.Second)))
+
+	// the costs larger than the capacity take a full bucket
+	require.True(t, tb.take("c", 10, now))
+	require.False(t, tb.take("c", 1, now))
+
+	// the full buckets are dropped
+	tb.prune(now.Add(bucketPruneInterval))
+	require.Empty(t, tb.buckets)
+}
+
+func TestParseMethodCosts(t *testing.T) {
+	costs, err := ParseMethodCosts([]string{"eth_getLogs=20", "eth_blockNumber=0"})
+	require.NoError(t, err)
+	require.Equal(t, 20, costs["eth_getLogs"])
+	require.Equal(t, 0, costs["eth_blockNumber"])
+	require.Equal(t, DefaultMethodCosts["sbch_queryLogs"], costs["sbch_queryLogs"])
+	require.Equal(t, 10, DefaultMethodCosts["eth_getLogs"])
+
+	_, err = ParseMethodCosts([]string{"eth_getLogs"})
+	require.Error(t, err)
+	_, err = ParseMethodCosts([]string{"eth_getLogs=-1"})
+	require.Error(t, err)
+}
+
+func TestRateLimiter(t *testing.T) {
+	costs, _ := ParseMethodCosts(nil)
+	limiter := newRateLimiter(RateLimitConfig{
+		RequestsPerSecond: 0.001,
+		Burst:             12,
+		MaxBatchSize:      3,
+		MaxResponseSize:   64,
+		MethodCosts:       costs,
+	}, log.NewNopLogger())
+	handler := limiter.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/large" {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + strings.Repeat("0", 64) + `"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	post := func(path, remoteAddr, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post("/", "1.1.1.1:1000", `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, w.Body.String())
+
+	w = post("/", "1.1.1.1:1000", `[{"id":1,"method":"eth_blockNumber"},{"id":2,"method":"eth_blockNumber"},`+
+		`{"id":3,"method":"eth_blockNumber"},{"id":4,"method":"eth_blockNumber"}]`)
+	require.Equal(t, `{"error":{"code":-32006,"message":"batch too large: 4 requests, the max is 3"},"id":null,"jsonrpc":"2.0"}`,
+		w.Body.String())
+
+	w = post("/large", "1.1.1.1:1000", `{"jsonrpc":"2.0","id":"a","method":"eth_blockNumber"}`)
+	require.Equal(t, `{"error":{"code":-32007,"message":"response too large, the max is 64 bytes"},"id":"a","jsonrpc":"2.0"}`,
+		w.Body.String())
+
+	// 10 tokens are left and eth_getLogs takes 10
+	w = post("/", "1.1.1.1:2000", `{"jsonrpc":"2.0","id":2,"method":"eth_getLogs"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = post("/", "1.1.1.1:2000", `{"jsonrpc":"2.0","id":3,"method":"eth_blockNumber"}`)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, `{"error":{"code":-32005,"message":"rate limit exceeded, please retry later"},"id":3,"jsonrpc":"2.0"}`,
+		w.Body.String())
+
+	// the other clients are not affected
+	w = post("/", "2.2.2.2:1000", `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+type wsTestService struct{}
+
+func (wsTestService) Echo(s string) string { return s }
+func (wsTestService) Heavy() int           { return 0 }
+
+func TestRateLimiter_websocket(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{
+		RequestsPerSecond: 0.001,
+		Burst:             4,
+		MaxBatchSize:      2,
+		MethodCosts:       map[string]int{"test_heavy": 3},
+	}, log.NewNopLogger())
+	server := gethrpc.NewServer()
+	require.NoError(t, server.RegisterName("test", wsTestService{}))
+	defer server.Stop()
+	httpServer := httptest.NewServer(limiter.wrap(limiter.websocketHandler(server, []string{"*"})))
+	defer httpServer.Close()
+
+	// the handshake takes one token
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	call := func(req string) string {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(req)))
+		_, resp, err := conn.ReadMessage()
+		require.NoError(t, err)
+		return strings.TrimSpace(string(resp))
+	}
+
+	require.Equal(t, `{"jsonrpc":"2.0","id":1,"result":"a"}`,
+		call(`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["a"]}`))
+	require.Equal(t, `{"error":{"code":-32006,"message":"batch too large: 3 requests, the max is 2"},"id":null,"jsonrpc":"2.0"}`,
+		call(`[{"id":1,"method":"test_echo"},{"id":2,"method":"test_echo"},{"id":3,"method":"test_echo"}]`))
+	// 2 tokens are left and test_heavy takes 3
+	require.Equal(t, `{"error":{"code":-32005,"message":"rate limit exceeded, please retry later"},"id":2,"jsonrpc":"2.0"}`,
+		call(`{"jsonrpc":"2.0","id":2,"method":"test_heavy"}`))
+	// the connection is kept
+	require.Equal(t, `{"jsonrpc":"2.0","id":3,"result":"b"}`,
+		call(`{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["b"]}`))
+}
+
+func TestWsOriginChecker(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{}, log.NewNopLogger())
+	check := limiter.wsOriginChecker([]string{"https://a.com", "b.com:8080"})
+	withOrigin := func(origin string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+	require.True(t, check(withOrigin("")))
+	require.True(t, check(withOrigin("https://A.com")))
+	require.False(t, check(withOrigin("http://a.com")))
+	require.True(t, check(withOrigin("http://b.com:8080")))
+	require.False(t, check(withOrigin("http://b.com:8081")))
+
+	check = limiter.wsOriginChecker(nil)
+	require.True(t, check(withOrigin("http://localhost")))
+	require.False(t, check(withOrigin("http://a.com")))
+	require.True(t, limiter.wsOriginChecker([]string{"*"})(withOrigin("http://a.com")))
+}
@@ -1,13 +1,17 @@
 package rpc
 
 import (
+	"crypto/tls"
+	"errors"
 	"net"
 	"net/http"
+	"strings"
 
 	tmlog "github.com/tendermint/tendermint/libs/log"
 	tmservice "github.com/tendermint/tendermint/libs/service"
 	tmrpcserver "github.com/tendermint/tendermint/rpc/jsonrpc/server"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	gethrpc "github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/cors"
 
@@ -17,124 +21,176 @@ import (
 
 var _ tmservice.Service = (*Server)(nil)
 
+// Config configures the listeners of Server, a listener is disabled if its address is empty
+type Config struct {
+	RpcAddr      string // listen address of rest-server
+	WsAddr       string // listen address of ws server
+	RpcHttpsAddr string // listen address of https rest-server
+	WssAddr      string // listen address of https ws server
+
+	CorsOrigins  []string // the origins allowed by CORS on HTTP(S), empty means CORS is disabled
+	WsOrigins    []string // the origins allowed to connect to WS(S), empty means only localhost
+	VirtualHosts []string // the host names accepted by HTTP(S), "*" means any
+
+	// The certificate and key of HTTPS and WSS, which are reloaded once the files are modified
+	CertFile, KeyFile string
+	// The CA certificates verifying the client certificates, empty means clients are not authenticated
+	ClientCAFile string
+
+	RateLimits RateLimitConfig
+
+	// The directory of the encrypted keystore, empty means the keystore and the personal namespace are disabled.
+	// The keystore accounts are listed on all the listeners, but the personal namespace and signing with them
+	// are served only on the admin listener, so AdminAddr is required.
+	KeystoreDir string
+	// Whether the debug namespace is served on HTTP(S) and WS(S)
+	EnableDebug bool
+
+	// The listen address of the admin namespace, which is protected by JWT, empty means disabled
+	AdminAddr string
+	// The file of the hex-encoded HS256 secret shared with the admin clients, generated if it does not exist
+	JWTSecretFile string
+	// Changes the log level at runtime, nil means the log level can not be changed
+	SetLogLevel func(level string) error
+}
+
 // serve JSON-RPC over HTTP & WebSocket
 type Server struct {
 	tmservice.BaseService
 
-	rpcAddr      string // listen address of rest-server
-	wsAddr       string // listen address of ws server
-	rpcHttpsAddr string //listen address of https rest-server
-	wssAddr      string //listen address of https ws server
+	config Config
 
 	logger  tmlog.Logger
 	backend api.BackendService
 
-	httpServer   *gethrpc.Server
-	httpListener net.Listener
-	wsServer     *gethrpc.Server
-	wsListener   net.Listener
+	httpServer  *gethrpc.Server
+	wsServer    *gethrpc.Server
+	adminServer *gethrpc.Server
+	listeners   []net.Listener
+	certs       *certReloader
 
-	httpsListener     net.Listener
-	wssListener       net.Listener
-	certFile, keyFile string
+	limiter *rateLimiter
 
 	unlockedKeys []string
 }
 
-func NewServer(rpcAddr string, wsAddr string,
-	backend api.BackendService, certFile, keyFile string,
+func NewServer(config Config, backend api.BackendService,
 	logger tmlog.Logger, unlockedKeys []string) tmservice.Service {
 
 	impl := &Server{
-		rpcAddr:      rpcAddr,
-		wsAddr:       wsAddr,
+		config:       config,
 		backend:      backend,
 		logger:       logger,
 		unlockedKeys: unlockedKeys,
-		certFile:     certFile,
-		keyFile:      keyFile,
-		rpcHttpsAddr: "tcp://:9545",
-		wssAddr:      "tcp://:9546",
+		limiter:      newRateLimiter(config.RateLimits, logger),
 	}
 	return tmservice.NewBaseService(logger, "", impl)
 }
 
 func (server *Server) OnStart() error {
-	apis := rpcapi.GetAPIs(server.backend, server.logger, server.unlockedKeys)
-	if err := server.startHTTPAndHTTPS(apis); err != nil {
+	if server.config.KeystoreDir != "" && server.config.AdminAddr == "" {
+		return errors.New("the personal namespace is served only on the admin listener, which is not enabled")
+	}
+	var tlsConfig *tls.Config
+	if server.config.RpcHttpsAddr != "" || server.config.WssAddr != "" {
+		var err error
+		server.certs, err = newCertReloader(server.config.CertFile, server.config.KeyFile,
+			server.config.ClientCAFile, certCheckInterval, server.logger)
+		if err != nil {
+			return err
+		}
+		tlsConfig = server.certs.tlsConfig()
+	}
+	var ks *keystore.KeyStore
+	if server.config.KeystoreDir != "" {
+		ks = keystore.NewKeyStore(server.config.KeystoreDir, keystore.StandardScryptN, keystore.StandardScryptP)
+		server.logger.Info("the personal namespace is enabled", "keystore", server.config.KeystoreDir)
+	}
+	apis := rpcapi.GetAPIs(server.backend, server.logger, server.unlockedKeys, ks, server.config.EnableDebug)
+	if err := server.startHTTPAndHTTPS(apis, tlsConfig); err != nil {
+		server.release()
 		return err
 	}
-	return server.startWSAndWSS(apis)
+	if err := server.startWSAndWSS(apis, tlsConfig); err != nil {
+		server.release()
+		return err
+	}
+	if err := server.startAdmin(ks); err != nil {
+		server.release()
+		return err
+	}
+	return nil
 }
 
-func (server *Server) startHTTPAndHTTPS(apis []gethrpc.API) (err error) {
+func (server *Server) startHTTPAndHTTPS(apis []gethrpc.API, tlsConfig *tls.Config) (err error) {
 	server.httpServer = gethrpc.NewServer()
 	if err = registerApis(server.httpServer, apis); err != nil {
 		return err
 	}
 
-	server.httpListener, err = tmrpcserver.Listen(
-		server.rpcAddr, tmrpcserver.DefaultConfig())
-	if err != nil {
-		return err
-	}
-
-	server.httpsListener, err = tmrpcserver.Listen(
-		server.rpcHttpsAddr, tmrpcserver.DefaultConfig())
-	if err != nil {
+	handler := newVHostHandler(server.config.VirtualHosts, server.limiter.wrap(server.httpServer))
+	handler = newCorsHandler(handler, server.config.CorsOrigins)
+	if err = server.serve(server.config.RpcAddr, handler, nil); err != nil {
 		return err
 	}
-
-	allowedOrigins := []string{"*"} // TODO: get from cmd line options or config file
-	handler := newCorsHandler(server.httpServer, allowedOrigins)
-	go func() {
-		err := tmrpcserver.Serve(server.httpListener, handler, server.logger,
-			tmrpcserver.DefaultConfig()) // TODO: get config from config file
-		if err != nil {
-			server.logger.Error(err.Error())
-		}
-	}()
-	go func() {
-		err := tmrpcserver.ServeTLS(server.httpsListener, handler,
-			server.certFile, server.keyFile, server.logger, tmrpcserver.DefaultConfig())
-		if err != nil {
-			server.logger.Error(err.Error())
-		}
-	}()
-	return nil
+	return server.serve(server.config.RpcHttpsAddr, handler, tlsConfig)
 }
 
-func (server *Server) startWSAndWSS(apis []gethrpc.API) (err error) {
+func (server *Server) startWSAndWSS(apis []gethrpc.API, tlsConfig *tls.Config) (err error) {
 	server.wsServer = gethrpc.NewServer()
 	if err = registerApis(server.wsServer, apis); err != nil {
 		return err
 	}
 
-	server.wsListener, err = tmrpcserver.Listen(
-		server.wsAddr, tmrpcserver.DefaultConfig()) // TODO: get config from config file
-	if err != nil {
+	wsh := server.limiter.wrap(server.limiter.websocketHandler(server.wsServer, server.config.WsOrigins))
+	if err = server.serve(server.config.WsAddr, wsh, nil); err != nil {
 		return err
 	}
+	return server.serve(server.config.WssAddr, wsh, tlsConfig)
+}
 
-	server.wssListener, err = tmrpcserver.Listen(
-		server.wssAddr, tmrpcserver.DefaultConfig())
+// The admin listener serves both HTTP and WebSocket, all the requests must carry JWT.
+// Only here can the accounts in the keystore ks be unlocked and sign.
+func (server *Server) startAdmin(ks *keystore.KeyStore) error {
+	if server.config.AdminAddr == "" {
+		return nil
+	}
+	secret, err := loadJWTSecret(server.config.JWTSecretFile, server.logger)
 	if err != nil {
 		return err
 	}
-	allowedOrigins := []string{"*"} // TODO: get from cmd line options or config file
-	wsh := server.wsServer.WebsocketHandler(allowedOrigins)
+	server.adminServer = gethrpc.NewServer()
+	apis := rpcapi.GetAdminAPIs(server.backend, server.logger, server.config.SetLogLevel, server.unlockedKeys, ks)
+	if err = registerApis(server.adminServer, apis); err != nil {
+		return err
+	}
 
-	go func() {
-		err := tmrpcserver.Serve(server.wsListener, wsh, server.logger,
-			tmrpcserver.DefaultConfig()) // TODO: get config from config file
-		if err != nil {
-			server.logger.Error(err.Error())
+	wsh := server.adminServer.WebsocketHandler(nil)
+	handler := newJWTHandler(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocket(r) {
+			wsh.ServeHTTP(w, r)
+			return
 		}
-	}()
+		server.adminServer.ServeHTTP(w, r)
+	}))
+	return server.serve(server.config.AdminAddr, handler, nil)
+}
 
+// Serves handler on addr, over TLS if tlsConfig is not nil. Nothing is served if addr is empty.
+func (server *Server) serve(addr string, handler http.Handler, tlsConfig *tls.Config) error {
+	if addr == "" {
+		return nil
+	}
+	listener, err := tmrpcserver.Listen(addr, tmrpcserver.DefaultConfig()) // TODO: get config from config file
+	if err != nil {
+		return err
+	}
+	server.listeners = append(server.listeners, listener)
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
 	go func() {
-		err := tmrpcserver.ServeTLS(server.wssListener, wsh,
-			server.certFile, server.keyFile, server.logger, tmrpcserver.DefaultConfig()) // TODO: get config from config file
+		err := tmrpcserver.Serve(listener, handler, server.logger, tmrpcserver.DefaultConfig())
 		if err != nil {
 			server.logger.Error(err.Error())
 		}
@@ -143,24 +199,26 @@ func (server *Server) startWSAndWSS(apis []gethrpc.API) (err error) {
 }
 
 func (server *Server) OnStop() {
-	server.stopHTTP()
-	server.stopWS()
-}
-
-func (server *Server) stopHTTP() {
 	if server.httpServer != nil {
 		server.httpServer.Stop()
 	}
-	if server.httpListener != nil {
-		_ = server.httpListener.Close()
+	if server.wsServer != nil {
+		server.wsServer.Stop()
+	}
+	if server.adminServer != nil {
+		server.adminServer.Stop()
 	}
+	server.release()
 }
-func (server *Server) stopWS() {
-	if server.wsServer != nil {
-		server.httpServer.Stop()
+
+func (server *Server) release() {
+	for _, listener := range server.listeners {
+		_ = listener.Close()
 	}
-	if server.wsListener != nil {
-		_ = server.httpListener.Close()
+	server.listeners = nil
+	if server.certs != nil {
+		server.certs.stop()
+		server.certs = nil
 	}
 }
 
@@ -173,6 +231,11 @@ func registerApis(rpcServer *gethrpc.Server, apis []gethrpc.API) error {
 	return nil
 }
 
+func isWebsocket(r *http.Request) bool {
+	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket" &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
 func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
@@ -186,3 +249,48 @@ func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	})
 	return c.Handler(srv)
 }
+
+// virtualHostHandler is a handler which validates the Host-header of incoming requests.
+// Using virtual hosts can help prevent DNS rebinding attacks, where a 'random' domain name points to
+// the service ip address (but without CORS headers). By verifying the targeted virtual host, we can
+// ensure that it's a destination that the node operator has defined.
+type virtualHostHandler struct {
+	vhosts map[string]struct{}
+	next   http.Handler
+}
+
+func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
+	vhostMap := make(map[string]struct{})
+	for _, allowedHost := range vhosts {
+		vhostMap[strings.ToLower(allowedHost)] = struct{}{}
+	}
+	return &virtualHostHandler{vhostMap, next}
+}
+
+func (h *virtualHostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// if r.Host is not set, we can continue serving since a browser would set the Host header
+	if r.Host == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		// Either invalid (too many colons) or no port specified
+		host = r.Host
+	}
+	if ipAddr := net.ParseIP(host); ipAddr != nil {
+		// It's an IP address, we can serve that
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	// Not an IP address, but a hostname. Need to validate
+	if _, exist := h.vhosts["*"]; exist {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if _, exist := h.vhosts[strings.ToLower(host)]; exist {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, "invalid host specified", http.StatusForbidden)
+}
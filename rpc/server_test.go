@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVHostHandler(t *testing.T) {
+	handler := newVHostHandler([]string{"localhost", "rpc.example.com"},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	check := func(host string, status int) {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, status, w.Code, host)
+	}
+	check("localhost:8545", http.StatusOK)
+	check("RPC.example.com", http.StatusOK)
+	check("127.0.0.1:8545", http.StatusOK)
+	check("evil.com", http.StatusForbidden)
+}
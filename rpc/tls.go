@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tmlog "github.com/tendermint/tendermint/libs/log"
+)
+
+const (
+	// how often the files of the certificate and the client CAs are checked
+	certCheckInterval = 10 * time.Second
+	// a failure of reloading is logged at most once in this interval
+	certErrorLogInterval = 10 * time.Minute
+)
+
+// certReloader checks the files of the certificate and the client CAs periodically, and reloads them once they
+// are modified, so that they can be renewed without restarting the node. The handshakes are served with the
+// last config loaded successfully.
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	logger       tmlog.Logger
+
+	config atomic.Value // *tls.Config
+
+	// only accessed by the checking goroutine after the first loading
+	modTimes   []time.Time
+	lastErr    string
+	lastErrLog time.Time
+
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+// newCertReloader loads the certificate in certFile, which also requires the clients to present certificates
+// signed by the CAs in clientCAFile if it is not empty, and then checks the files every 'interval'
+func newCertReloader(certFile, keyFile, clientCAFile string, interval time.Duration,
+	logger tmlog.Logger) (*certReloader, error) {
+
+	r := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		logger:       logger.With("module", "tls"),
+		quit:         make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	go r.run(interval)
+	return r, nil
+}
+
+// The TLS config of the listeners, whose handshakes get the current certificate and client CAs
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+	}
+}
+
+func (r *certReloader) stop() {
+	r.stopOnce.Do(func() { close(r.quit) })
+}
+
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return r.config.Load().(*tls.Config), nil
+}
+
+func (r *certReloader) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.quit:
+			return
+		case now := <-ticker.C:
+			r.check(now)
+		}
+	}
+}
+
+func (r *certReloader) check(now time.Time) {
+	if !r.modified() {
+		return
+	}
+	// keep serving with the old certificate until the new one is complete
+	if err := r.reload(); err != nil {
+		if err.Error() != r.lastErr || now.Sub(r.lastErrLog) >= certErrorLogInterval {
+			r.logger.Error("failed to reload the TLS certificate", "err", err)
+			r.lastErr, r.lastErrLog = err.Error(), now
+		}
+		return
+	}
+	r.lastErr = ""
+	r.logger.Info("reloaded the TLS certificate")
+}
+
+func (r *certReloader) files() []string {
+	if r.clientCAFile == "" {
+		return []string{r.certFile, r.keyFile}
+	}
+	return []string{r.certFile, r.keyFile, r.clientCAFile}
+}
+
+func (r *certReloader) modified() bool {
+	for i, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// The modification times are recorded before loading, such that a failed reload is retried
+// only after the files are modified again, a missing file is retried on every check
+func (r *certReloader) reload() error {
+	modTimes := make([]time.Time, 0, 3)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	r.modTimes = modTimes
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.clientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate is found in %s", r.clientCAFile)
+		}
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if clientCAs != nil {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = clientCAs
+	}
+	r.config.Store(config)
+	return nil
+}
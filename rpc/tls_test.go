@@ -0,0 +1,175 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// Writes a self-signed certificate for 127.0.0.1, which also serves as a CA and a client certificate
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64, modTime time.Time) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "smartbch-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	require.NoError(t, ioutil.WriteFile(certFile, certPem, 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, keyPem, 0600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	require.NoError(t, err)
+	return cert
+}
+
+func serveTLS(t *testing.T, tlsConfig *tls.Config) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		_ = http.Serve(tls.NewListener(ln, tlsConfig), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+	}()
+	return "https://" + ln.Addr().String()
+}
+
+// Returns the serial number of the server certificate
+func getTLS(t *testing.T, url string, clientCert *tls.Certificate) (int64, error) {
+	client := &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if clientCert == nil {
+					return &tls.Certificate{}, nil
+				}
+				return clientCert, nil
+			},
+		},
+	}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestTLSCertReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	now := time.Now()
+	writeTestCert(t, certFile, keyFile, 1, now)
+	certs, err := newCertReloader(certFile, keyFile, "", 10*time.Millisecond, log.NewNopLogger())
+	require.NoError(t, err)
+	defer certs.stop()
+	url := serveTLS(t, certs.tlsConfig())
+
+	serial, err := getTLS(t, url, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, serial)
+
+	writeTestCert(t, certFile, keyFile, 2, now.Add(time.Second))
+	require.Eventually(t, func() bool {
+		serial, err = getTLS(t, url, nil)
+		return err == nil && serial == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// an invalid certificate is not loaded
+	require.NoError(t, ioutil.WriteFile(certFile, []byte("invalid"), 0600))
+	time.Sleep(100 * time.Millisecond)
+	serial, err = getTLS(t, url, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, serial)
+
+	_, err = newCertReloader(filepath.Join(dir, "none.pem"), keyFile, "", time.Hour, log.NewNopLogger())
+	require.Error(t, err)
+}
+
+func TestTLSCertReloadErrorLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	now := time.Now()
+	writeTestCert(t, certFile, keyFile, 1, now)
+	buf := &bytes.Buffer{}
+	certs, err := newCertReloader(certFile, keyFile, "", time.Hour, log.NewTMLogger(buf))
+	require.NoError(t, err)
+	defer certs.stop()
+
+	// a missing file fails every check, but is logged once in certErrorLogInterval
+	require.NoError(t, os.Remove(certFile))
+	certs.check(now)
+	certs.check(now.Add(time.Second))
+	certs.check(now.Add(certErrorLogInterval - time.Second))
+	require.Equal(t, 1, strings.Count(buf.String(), "failed to reload"))
+	certs.check(now.Add(certErrorLogInterval))
+	require.Equal(t, 2, strings.Count(buf.String(), "failed to reload"))
+
+	// the last good certificate is kept
+	config, err := certs.getConfigForClient(nil)
+	require.NoError(t, err)
+	require.Len(t, config.Certificates, 1)
+}
+
+func TestTLSClientAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	caFile, caKeyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+	otherFile, otherKeyFile := filepath.Join(dir, "other.pem"), filepath.Join(dir, "other-key.pem")
+
+	now := time.Now()
+	writeTestCert(t, certFile, keyFile, 1, now)
+	clientCert := writeTestCert(t, caFile, caKeyFile, 2, now)
+	otherCert := writeTestCert(t, otherFile, otherKeyFile, 3, now)
+	certs, err := newCertReloader(certFile, keyFile, caFile, time.Hour, log.NewNopLogger())
+	require.NoError(t, err)
+	defer certs.stop()
+	url := serveTLS(t, certs.tlsConfig())
+
+	_, err = getTLS(t, url, &clientCert)
+	require.NoError(t, err)
+	_, err = getTLS(t, url, nil)
+	require.Error(t, err)
+	_, err = getTLS(t, url, &otherCert)
+	require.Error(t, err)
+}
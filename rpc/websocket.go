@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// The WebSocket connections are set up here instead of by gethrpc.Server.WebsocketHandler, whose codec can not
+// be wrapped, so that every message read from a connection passes rateLimiter.admit before the server sees it.
+// A rejected message gets its error response at once, and the connection is kept. The other behaviors follow
+// go-ethereum: the buffer sizes, the message size limit, the origin check and the pings on idle connections.
+
+const (
+	wsReadBuffer       = 1024
+	wsWriteBuffer      = 1024
+	wsPingInterval     = 60 * time.Second
+	wsPingWriteTimeout = 5 * time.Second
+	wsMessageSizeLimit = 15 * 1024 * 1024
+)
+
+var wsBufferPool = new(sync.Pool)
+
+func (limiter *rateLimiter) websocketHandler(server *gethrpc.Server, allowedOrigins []string) http.Handler {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  wsReadBuffer,
+		WriteBufferSize: wsWriteBuffer,
+		WriteBufferPool: wsBufferPool,
+		CheckOrigin:     limiter.wsOriginChecker(allowedOrigins),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			limiter.logger.Debug("WebSocket upgrade failed", "err", err)
+			return
+		}
+		conn.SetReadLimit(wsMessageSizeLimit)
+		wc := &wsConn{Conn: conn, limiter: limiter, ip: clientIP(r), closed: make(chan struct{})}
+		go wc.pingLoop()
+		server.ServeCodec(gethrpc.NewFuncCodec(conn, wc.writeJSON, wc.readJSON), 0)
+		close(wc.closed)
+	})
+}
+
+type wsConn struct {
+	*websocket.Conn
+	limiter *rateLimiter
+	ip      string
+	writeMu sync.Mutex // a websocket.Conn supports only one writer at a time
+	closed  chan struct{}
+}
+
+func (wc *wsConn) writeJSON(v interface{}) error {
+	wc.writeMu.Lock()
+	defer wc.writeMu.Unlock()
+	return wc.Conn.WriteJSON(v)
+}
+
+// Reads the next message admitted by the rate limiter, the rejected ones are answered here
+func (wc *wsConn) readJSON(v interface{}) error {
+	for {
+		_, data, err := wc.Conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		calls, isBatch := parseCalls(data)
+		code, msg := wc.limiter.admit(wc.ip, calls, isBatch)
+		if code == 0 {
+			return json.Unmarshal(data, v)
+		}
+		// the errors of a batch are not bound to any of its requests
+		var id json.RawMessage
+		if !isBatch && len(calls) == 1 {
+			id = calls[0].ID
+		}
+		wc.writeMu.Lock()
+		err = wc.Conn.WriteMessage(websocket.TextMessage, jsonrpcError(id, code, msg))
+		wc.writeMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (wc *wsConn) pingLoop() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-wc.closed:
+			return
+		case <-ticker.C:
+			_ = wc.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsPingWriteTimeout))
+		}
+	}
+}
+
+// The same as go-ethereum: the requests without Origin are accepted, '*' accepts any origin,
+// and only localhost is accepted if no origin is specified
+func (limiter *rateLimiter) wsOriginChecker(allowedOrigins []string) func(*http.Request) bool {
+	allowAll := false
+	var origins []string
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		origins = append(origins, "http://localhost")
+		if hostname, err := os.Hostname(); err == nil {
+			origins = append(origins, "http://"+hostname)
+		}
+	}
+	return func(r *http.Request) bool {
+		if _, ok := r.Header["Origin"]; !ok || allowAll {
+			return true
+		}
+		origin := strings.ToLower(r.Header.Get("Origin"))
+		for _, allowed := range origins {
+			if originAllowedBy(allowed, origin) {
+				return true
+			}
+		}
+		limiter.logger.Info("rejected WebSocket connection", "origin", origin)
+		return false
+	}
+}
+
+func originAllowedBy(allowedOrigin, browserOrigin string) bool {
+	allowedScheme, allowedHost, allowedPort, err := parseOriginURL(allowedOrigin)
+	if err != nil {
+		return false
+	}
+	browserScheme, browserHost, browserPort, err := parseOriginURL(browserOrigin)
+	if err != nil {
+		return false
+	}
+	return (allowedScheme == "" || allowedScheme == browserScheme) &&
+		(allowedHost == "" || allowedHost == browserHost) &&
+		(allowedPort == "" || allowedPort == browserPort)
+}
+
+// An origin may be specified without the scheme, such as "example.com" or "example.com:8080"
+func parseOriginURL(origin string) (scheme, host, port string, err error) {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil {
+		return "", "", "", err
+	}
+	if strings.Contains(origin, "://") {
+		return u.Scheme, u.Hostname(), u.Port(), nil
+	}
+	host, port = u.Scheme, u.Opaque
+	if host == "" {
+		host = origin
+	}
+	return "", host, port, nil
+}
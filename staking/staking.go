@@ -35,6 +35,8 @@ var (
 	    function decreaseMinGasPrice() external;
 	    //9ce06909
 	    function sumVotingPower(address[] calldata addrList) external override returns (uint summedPower, uint totalPower)
+	    //0xf679d305
+	    function unjail() external;
 	}*/
 	SelectorCreateValidator     [4]byte = [4]byte{0x24, 0xd1, 0xed, 0x5d}
 	SelectorEditValidator       [4]byte = [4]byte{0x9d, 0xc1, 0x59, 0xb6}
@@ -42,12 +44,14 @@ var (
 	SelectorIncreaseMinGasPrice [4]byte = [4]byte{0xf2, 0x01, 0x6e, 0x8e}
 	SelectorDecreaseMinGasPrice [4]byte = [4]byte{0x69, 0x6e, 0x6a, 0xd2}
 	SelectorSumVotingPower      [4]byte = [4]byte{0x9c, 0xe0, 0x69, 0x09}
+	SelectorUnjail              [4]byte = [4]byte{0xf6, 0x79, 0xd3, 0x05}
 
 	//slot
 	SlotStakingInfo     string = strings.Repeat(string([]byte{0}), 32)
 	SlotAllBurnt        string = strings.Repeat(string([]byte{0}), 31) + string([]byte{1})
 	SlotMinGasPrice     string = strings.Repeat(string([]byte{0}), 31) + string([]byte{2})
 	SlotLastMinGasPrice string = strings.Repeat(string([]byte{0}), 31) + string([]byte{3})
+	SlotLivenessInfo    string = strings.Repeat(string([]byte{0}), 31) + string([]byte{4})
 
 	/*------param------*/
 	//staking
@@ -84,6 +88,8 @@ var (
 	MinGasPriceExceedBlockChangeDelta = errors.New("the amount of variation in minGasPrice exceeds the allowable range")
 	OperatorNotValidator              = errors.New("minGasPrice operator not validator or its rewardTo")
 	InvalidArgument                   = errors.New("invalid argument")
+	ValidatorNotJailed                = errors.New("validator is not jailed")
+	ValidatorStillJailed              = errors.New("validator can not unjail itself before the jail period ends")
 )
 
 const (
@@ -135,6 +141,13 @@ func (_ *StakingContractExecutor) Execute(ctx *mevmtypes.Context, currBlock *mev
 	case SelectorDecreaseMinGasPrice:
 		//function decreaseMinGasPrice() external;
 		return handleMinGasPrice(ctx, tx.From, false)
+	case SelectorUnjail:
+		//function unjail() external;
+		if !IsDowntimeSlashingEnabled(ctx) {
+			status = StatusFailed
+			return
+		}
+		return unjail(ctx, tx.From)
 	default:
 		status = StatusFailed
 		return
@@ -321,6 +334,31 @@ func handleMinGasPrice(ctx *mevmtypes.Context, sender common.Address, isIncrease
 	return
 }
 
+// A jailed validator can unjail itself after its jail period, and then it will get voting power when next epoch begins
+func unjail(ctx *mevmtypes.Context, sender common.Address) (status int, logs []mevmtypes.EvmLog, gasUsed uint64, outData []byte) {
+	status = StatusFailed
+	gasUsed = GasOfStakingExternalOp
+	stakingAcc, info := LoadStakingAcc(ctx)
+	val := info.GetValidatorByAddr(sender)
+	if val == nil {
+		outData = []byte(NoSuchValidator.Error())
+		return
+	}
+	if !val.IsJailed {
+		outData = []byte(ValidatorNotJailed.Error())
+		return
+	}
+	if int64(ctx.Height) < val.JailedUntil {
+		outData = []byte(ValidatorStillJailed.Error())
+		return
+	}
+	val.IsJailed = false
+	val.JailedUntil = 0
+	SaveStakingInfo(ctx, stakingAcc, info)
+	status = StatusSuccess
+	return
+}
+
 func LoadStakingAcc(ctx *mevmtypes.Context) (stakingAcc *mevmtypes.AccountInfo, info types.StakingInfo) {
 	stakingAcc = ctx.GetAccount(StakingContractAddress)
 	if stakingAcc == nil {
@@ -345,6 +383,45 @@ func SaveStakingInfo(ctx *mevmtypes.Context, stakingAcc *mevmtypes.AccountInfo,
 	ctx.SetStorageAt(stakingAcc.Sequence(), SlotStakingInfo, bz)
 }
 
+func LoadLivenessInfo(ctx *mevmtypes.Context, stakingAcc *mevmtypes.AccountInfo) (info types.LivenessInfo) {
+	bz := ctx.GetStorageAt(stakingAcc.Sequence(), SlotLivenessInfo)
+	if bz == nil {
+		return
+	}
+	_, err := info.UnmarshalMsg(bz)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+// Downtime slashing is enabled by an upgrade, which initializes the liveness info
+func EnableDowntimeSlashing(ctx *mevmtypes.Context) {
+	stakingAcc := ctx.GetAccount(StakingContractAddress)
+	if stakingAcc == nil {
+		panic("Cannot find staking contract")
+	}
+	if !IsDowntimeSlashingEnabled(ctx) {
+		SaveLivenessInfo(ctx, stakingAcc, types.LivenessInfo{})
+	}
+}
+
+func IsDowntimeSlashingEnabled(ctx *mevmtypes.Context) bool {
+	stakingAcc := ctx.GetAccount(StakingContractAddress)
+	if stakingAcc == nil {
+		return false
+	}
+	return len(ctx.GetStorageAt(stakingAcc.Sequence(), SlotLivenessInfo)) != 0
+}
+
+func SaveLivenessInfo(ctx *mevmtypes.Context, stakingAcc *mevmtypes.AccountInfo, info types.LivenessInfo) {
+	bz, err := info.MarshalMsg(nil)
+	if err != nil {
+		panic(err)
+	}
+	ctx.SetStorageAt(stakingAcc.Sequence(), SlotLivenessInfo, bz)
+}
+
 func LoadMinGasPrice(ctx *mevmtypes.Context, isLast bool) uint64 {
 	stakingAcc := ctx.GetAccount(StakingContractAddress)
 	if stakingAcc == nil {
@@ -386,6 +463,13 @@ func Slash(ctx *mevmtypes.Context, pubkey [32]byte, amount *uint256.Int) (totalS
 	if val == nil {
 		return // If tendermint works fine, we'll never reach here
 	}
+	return slash(ctx, stakingAcc, &info, val, amount)
+}
+
+// Slash 'amount' of coins from 'val' in 'info' and burn them, the caller decides whether to save 'info'
+func slash(ctx *mevmtypes.Context, stakingAcc *mevmtypes.AccountInfo, info *types.StakingInfo,
+	val *types.Validator, amount *uint256.Int) (totalSlashed *uint256.Int) {
+
 	coins := uint256.NewInt().SetBytes32(val.StakedCoins[:])
 	if coins.Lt(amount) { // not enough coins to be slashed
 		totalSlashed = coins.Clone()
@@ -417,6 +501,72 @@ func incrAllBurnt(ctx *mevmtypes.Context, stakingAcc *mevmtypes.AccountInfo, amo
 	ctx.SetStorageAt(stakingAcc.Sequence(), SlotAllBurnt, bz32[:])
 }
 
+// The parameters of downtime slashing: a validator missing more than MaxMissedBlocks blocks in the latest
+// WindowSize blocks is jailed, and SlashedAmount of its staked coins are slashed. A jailed validator can
+// not unjail itself in the MinJailBlocks blocks after it was jailed.
+type LivenessParams struct {
+	WindowSize      int64
+	MaxMissedBlocks int64
+	SlashedAmount   *uint256.Int
+	MinJailBlocks   int64
+}
+
+// Record the voters and absentees of the block at 'height' in the validators' signing infos. The validators
+// who missed too many blocks are jailed and slashed. Returns the jailed validators. It does nothing before
+// downtime slashing is enabled, and the states are only written when they are changed.
+func HandleLiveness(ctx *mevmtypes.Context, params LivenessParams, height int64,
+	voters, absentees [][32]byte) (jailed []*types.Validator) {
+
+	if !IsDowntimeSlashingEnabled(ctx) {
+		return
+	}
+	stakingAcc, info := LoadStakingAcc(ctx)
+	liveness := LoadLivenessInfo(ctx, stakingAcc)
+	changed := false
+	record := func(pubkey [32]byte, missed bool) {
+		val := info.GetValidatorByPubkey(pubkey)
+		if val == nil || val.IsJailed || val.VotingPower == 0 {
+			return
+		}
+		si, created := liveness.GetOrCreateSigningInfo(pubkey)
+		if si.Record(height, missed, params.WindowSize) || created {
+			changed = true
+		}
+		if si.MissedCount > params.MaxMissedBlocks {
+			val.IsJailed = true
+			val.JailedUntil = height + params.MinJailBlocks
+			val.VotingPower = 0
+			jailed = append(jailed, val)
+		}
+	}
+	for _, pubkey := range voters {
+		record(pubkey, false)
+	}
+	for _, pubkey := range absentees {
+		record(pubkey, true)
+	}
+	// the jailed validators start over after unjailing, and the inactive validators are forgotten
+	valMapByPubkey := info.GetValMapByPubkey()
+	if liveness.Filter(func(pubkey [32]byte) bool {
+		val, ok := valMapByPubkey[pubkey]
+		return ok && !val.IsJailed && val.VotingPower != 0
+	}) {
+		changed = true
+	}
+	if changed {
+		SaveLivenessInfo(ctx, stakingAcc, liveness)
+	}
+	if len(jailed) == 0 {
+		return
+	}
+	// unlike double signing, the slashed coins and the cleared rewards are saved together with the jailing
+	for _, val := range jailed {
+		slash(ctx, stakingAcc, &info, val, params.SlashedAmount)
+	}
+	SaveStakingInfo(ctx, stakingAcc, info)
+	return
+}
+
 // distribute the collected gas fee to validators who voted for current block
 func DistributeFee(ctx *mevmtypes.Context, collectedFee *uint256.Int, proposer [32]byte /*pubKey*/, voters [][32]byte) {
 	if collectedFee == nil {
@@ -568,7 +718,7 @@ func updateVotingPower(info *types.StakingInfo, pubkey2power map[[32]byte]int64)
 	valMapByPubkey := info.GetValMapByPubkey()
 	for pubkey, power := range pubkey2power {
 		val, ok := valMapByPubkey[pubkey]
-		if !ok || val.IsRetiring {
+		if !ok || val.IsRetiring || val.IsJailed {
 			continue
 		}
 		if uint256.NewInt().SetBytes32(val.StakedCoins[:]).Cmp(MinimumStakingAmount) >= 0 {
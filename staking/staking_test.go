@@ -106,6 +106,24 @@ func buildChangeMinGasPriceCallEntry(sender common.Address, isIncrease bool) *ca
 	return c
 }
 
+func buildUnjailCallEntry(sender common.Address) *callEntry {
+	c := &callEntry{
+		Address: staking.StakingContractAddress,
+		Tx:      nil,
+	}
+	c.Tx = &types.TxToRun{
+		BasicTx: types.BasicTx{
+			From: sender,
+			To:   c.Address,
+		},
+	}
+	// unjail()
+	// data: (4B selector)
+	c.Tx.Data = make([]byte, 0, 100)
+	c.Tx.Data = append(c.Tx.Data, staking.SelectorUnjail[:]...)
+	return c
+}
+
 func TestStaking(t *testing.T) {
 	key, sender := testutils.GenKeyAndAddr()
 	_app := testutils.CreateTestApp(key)
@@ -240,6 +258,86 @@ func TestSlash(t *testing.T) {
 	require.Equal(t, uint64(1), allBurnt.Uint64())
 }
 
+func TestDowntimeJail(t *testing.T) {
+	params := staking.LivenessParams{WindowSize: 4, MaxMissedBlocks: 2, SlashedAmount: uint256.NewInt().SetUint64(1),
+		MinJailBlocks: 2}
+
+	key, _ := testutils.GenKeyAndAddr()
+	_app := testutils.CreateTestApp(key)
+	defer _app.Destroy()
+	ctx := _app.GetRunTxContext()
+	var pubkey [32]byte
+	copy(pubkey[:], _app.GetTestPubkey().Bytes())
+	valAddr := common.Address{}
+	copy(valAddr[:], _app.GetTestPubkey().Address())
+	ctx.SetAccount(valAddr, types.ZeroAccountInfo())
+	stakingAcc, info := staking.LoadStakingAcc(ctx)
+	info.Validators[0].StakedCoins[31] = 100
+	staking.SaveStakingInfo(ctx, stakingAcc, info)
+	require.Len(t, info.GetActiveValidators(staking.MinimumStakingAmount), 1)
+
+	// nothing happens before downtime slashing is enabled by the upgrade
+	e := &staking.StakingContractExecutor{}
+	status, _, gasUsed, _ := e.Execute(ctx, nil, buildUnjailCallEntry(valAddr).Tx)
+	require.Equal(t, staking.StatusFailed, status)
+	require.Equal(t, uint64(0), gasUsed)
+	for h := int64(1); h <= 4; h++ {
+		require.Len(t, staking.HandleLiveness(ctx, params, h, nil, [][32]byte{pubkey}), 0)
+	}
+	require.False(t, staking.IsDowntimeSlashingEnabled(ctx))
+	staking.Slash(ctx, pubkey, uint256.NewInt().SetUint64(1))
+	_, info = staking.LoadStakingAcc(ctx)
+	require.Equal(t, uint8(100), info.Validators[0].StakedCoins[31]) // the slashed coins were not saved
+	staking.EnableDowntimeSlashing(ctx)
+	require.True(t, staking.IsDowntimeSlashingEnabled(ctx))
+
+	// missed, signed, missed: only 2 blocks are missed in the window
+	require.Len(t, staking.HandleLiveness(ctx, params, 1, nil, [][32]byte{pubkey}), 0)
+	require.Len(t, staking.HandleLiveness(ctx, params, 2, [][32]byte{pubkey}, nil), 0)
+	require.Len(t, staking.HandleLiveness(ctx, params, 3, nil, [][32]byte{pubkey}), 0)
+	// signed, the first missed block slides out of the window
+	require.Len(t, staking.HandleLiveness(ctx, params, 4, [][32]byte{pubkey}, nil), 0)
+	require.Len(t, staking.HandleLiveness(ctx, params, 5, nil, [][32]byte{pubkey}), 0)
+	// the third missed block in the window
+	jailed := staking.HandleLiveness(ctx, params, 6, nil, [][32]byte{pubkey})
+	require.Len(t, jailed, 1)
+	require.Equal(t, pubkey, jailed[0].Pubkey)
+
+	_, info = staking.LoadStakingAcc(ctx)
+	require.True(t, info.Validators[0].IsJailed)
+	require.Equal(t, int64(8), info.Validators[0].JailedUntil)
+	require.Equal(t, int64(0), info.Validators[0].VotingPower)
+	require.Len(t, info.GetActiveValidators(staking.MinimumStakingAmount), 0)
+	require.Equal(t, uint8(99), info.Validators[0].StakedCoins[31])
+	// slashing for double signing still does not save the reduced coins after the upgrade
+	staking.Slash(ctx, pubkey, uint256.NewInt().SetUint64(1))
+	_, info = staking.LoadStakingAcc(ctx)
+	require.Equal(t, uint8(99), info.Validators[0].StakedCoins[31])
+	// a jailed validator is no longer tracked
+	require.Len(t, staking.HandleLiveness(ctx, params, 7, nil, [][32]byte{pubkey}), 0)
+	stakingAcc = ctx.GetAccount(staking.StakingContractAddress)
+	require.Len(t, staking.LoadLivenessInfo(ctx, stakingAcc).SigningInfos, 0)
+
+	c := buildUnjailCallEntry(common.Address{1})
+	status, _, _, outData := e.Execute(ctx, nil, c.Tx)
+	require.Equal(t, staking.StatusFailed, status)
+	require.Equal(t, staking.NoSuchValidator.Error(), string(outData))
+	c = buildUnjailCallEntry(valAddr)
+	ctx.Height = 7
+	status, _, _, outData = e.Execute(ctx, nil, c.Tx)
+	require.Equal(t, staking.StatusFailed, status)
+	require.Equal(t, staking.ValidatorStillJailed.Error(), string(outData))
+	ctx.Height = 8
+	status, _, _, _ = e.Execute(ctx, nil, c.Tx)
+	require.Equal(t, staking.StatusSuccess, status)
+	_, info = staking.LoadStakingAcc(ctx)
+	require.False(t, info.Validators[0].IsJailed)
+	require.Equal(t, int64(0), info.Validators[0].JailedUntil)
+	status, _, _, outData = e.Execute(ctx, nil, c.Tx)
+	require.Equal(t, staking.StatusFailed, status)
+	require.Equal(t, staking.ValidatorNotJailed.Error(), string(outData))
+}
+
 func TestGasPriceAdjustment(t *testing.T) {
 	staking.DefaultMinGasPrice = 100
 	key, sender := testutils.GenKeyAndAddr()
@@ -65,6 +65,10 @@ type Validator struct {
 	Introduction string   `msgp:"introduction"` // a short introduction
 	StakedCoins  [32]byte `msgp:"staked_coins"`
 	IsRetiring   bool     `msgp:"is_retiring"` // whether this validator is in a retiring process
+	// The fields below are omitted when they are zero, so the validators which were never jailed
+	// are encoded in the same way as before downtime slashing
+	IsJailed    bool  `msgp:"is_jailed" msg:"IsJailed,omitempty"`       // whether this validator is jailed for downtime, until it unjails itself
+	JailedUntil int64 `msgp:"jailed_until" msg:"JailedUntil,omitempty"` // a jailed validator can not unjail itself before this height
 }
 
 // Because EpochCountBeforeRewardMature >= 1, some rewards will be pending for a while before mature
@@ -74,6 +78,18 @@ type PendingReward struct {
 	Amount   [32]byte `msgp:"amount"`    // amount of rewards
 }
 
+// The signing record of a validator in the latest blocks, used for downtime slashing
+type SigningInfo struct {
+	Pubkey       [32]byte `msgp:"pubkey"`
+	MissedBitmap []byte   `msgp:"missed_bitmap"` // a ring buffer with one bit for each block in the window, indexed by height
+	MissedCount  int64    `msgp:"missed_count"`  // how many blocks are missed in the window
+}
+
+// This struct is stored in the world state, it tracks the liveness of validators
+type LivenessInfo struct {
+	SigningInfos []*SigningInfo `msgp:"signing_infos"`
+}
+
 var (
 	CreateValidatorCoinLtInitAmount = errors.New("Validator's staking coin less than init amount")
 	ValidatorAddressAlreadyExists   = errors.New("Validator's address already exists")
@@ -158,10 +174,11 @@ func (si *StakingInfo) GetValidatorByPubkey(pubkey [32]byte) *Validator {
 // 2. inactive validator with no vote power and pending reward in prev epoch,
 //    which may escape slash if it vote nothing after double sign !!!
 //    maybe there should have more epoch not one.
+// A jailed validator is not useless unless it is retiring, otherwise it can escape from the jail.
 func (si *StakingInfo) GetUselessValidators() map[[20]byte]struct{} {
 	res := make(map[[20]byte]struct{})
 	for _, val := range si.Validators {
-		if val.VotingPower == 0 {
+		if val.VotingPower == 0 && (!val.IsJailed || val.IsRetiring) {
 			res[val.Address] = struct{}{}
 		}
 	}
@@ -193,12 +210,12 @@ func (si *StakingInfo) ClearRewardsOf(addr [20]byte) (totalCleared *uint256.Int)
 }
 
 // Returns current validators on duty, who must have enough coins staked and be not in a retiring process
-// only update validator voting power on switchEpoch
+// or jailed. Only update validator voting power on switchEpoch
 func (si *StakingInfo) GetActiveValidators(minStakedCoins *uint256.Int) []*Validator {
 	res := make([]*Validator, 0, len(si.Validators))
 	for _, val := range si.Validators {
 		coins := uint256.NewInt().SetBytes32(val.StakedCoins[:])
-		if coins.Cmp(minStakedCoins) >= 0 && !val.IsRetiring && val.VotingPower > 0 {
+		if coins.Cmp(minStakedCoins) >= 0 && !val.IsRetiring && !val.IsJailed && val.VotingPower > 0 {
 			res = append(res, val)
 		}
 	}
@@ -211,3 +228,51 @@ func (si *StakingInfo) GetActiveValidators(minStakedCoins *uint256.Int) []*Valid
 	}
 	return res
 }
+
+// Find the signing info of the validator with matching pubkey, a new entry is created if not found
+func (li *LivenessInfo) GetOrCreateSigningInfo(pubkey [32]byte) (si *SigningInfo, created bool) {
+	for _, si := range li.SigningInfos {
+		if bytes.Equal(pubkey[:], si.Pubkey[:]) {
+			return si, false
+		}
+	}
+	si = &SigningInfo{Pubkey: pubkey}
+	li.SigningInfos = append(li.SigningInfos, si)
+	return si, true
+}
+
+// Only keep the signing infos whose pubkeys make keep() return true, returns whether any one is removed
+func (li *LivenessInfo) Filter(keep func(pubkey [32]byte) bool) (removed bool) {
+	infos := make([]*SigningInfo, 0, len(li.SigningInfos))
+	for _, si := range li.SigningInfos {
+		if keep(si.Pubkey) {
+			infos = append(infos, si)
+		}
+	}
+	removed = len(infos) != len(li.SigningInfos)
+	li.SigningInfos = infos
+	return
+}
+
+// Record whether the validator missed the block at 'height', the window contains the latest windowSize blocks.
+// Returns whether the signing info is changed.
+func (si *SigningInfo) Record(height int64, missed bool, windowSize int64) (changed bool) {
+	if int64(len(si.MissedBitmap)) != (windowSize+7)/8 { // the window size is changed, start over
+		si.MissedCount = 0
+		si.MissedBitmap = make([]byte, (windowSize+7)/8)
+		changed = true
+	}
+	pos := height % windowSize
+	mask := byte(1) << (pos % 8)
+	wasMissed := si.MissedBitmap[pos/8]&mask != 0
+	if missed && !wasMissed {
+		si.MissedBitmap[pos/8] |= mask
+		si.MissedCount++
+		changed = true
+	} else if !missed && wasMissed {
+		si.MissedBitmap[pos/8] &^= mask
+		si.MissedCount--
+		changed = true
+	}
+	return
+}
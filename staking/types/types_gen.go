@@ -478,6 +478,190 @@ func (z Epoch) Msgsize() (s int) {
 	return
 }
 
+// DecodeMsg implements msgp.Decodable
+func (z *LivenessInfo) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "SigningInfos":
+			var zb0002 uint32
+			zb0002, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "SigningInfos")
+				return
+			}
+			if cap(z.SigningInfos) >= int(zb0002) {
+				z.SigningInfos = (z.SigningInfos)[:zb0002]
+			} else {
+				z.SigningInfos = make([]*SigningInfo, zb0002)
+			}
+			for za0001 := range z.SigningInfos {
+				if dc.IsNil() {
+					err = dc.ReadNil()
+					if err != nil {
+						err = msgp.WrapError(err, "SigningInfos", za0001)
+						return
+					}
+					z.SigningInfos[za0001] = nil
+				} else {
+					if z.SigningInfos[za0001] == nil {
+						z.SigningInfos[za0001] = new(SigningInfo)
+					}
+					err = z.SigningInfos[za0001].DecodeMsg(dc)
+					if err != nil {
+						err = msgp.WrapError(err, "SigningInfos", za0001)
+						return
+					}
+				}
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *LivenessInfo) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 1
+	// write "SigningInfos"
+	err = en.Append(0x81, 0xac, 0x53, 0x69, 0x67, 0x6e, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x73)
+	if err != nil {
+		return
+	}
+	err = en.WriteArrayHeader(uint32(len(z.SigningInfos)))
+	if err != nil {
+		err = msgp.WrapError(err, "SigningInfos")
+		return
+	}
+	for za0001 := range z.SigningInfos {
+		if z.SigningInfos[za0001] == nil {
+			err = en.WriteNil()
+			if err != nil {
+				return
+			}
+		} else {
+			err = z.SigningInfos[za0001].EncodeMsg(en)
+			if err != nil {
+				err = msgp.WrapError(err, "SigningInfos", za0001)
+				return
+			}
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *LivenessInfo) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 1
+	// string "SigningInfos"
+	o = append(o, 0x81, 0xac, 0x53, 0x69, 0x67, 0x6e, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x73)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.SigningInfos)))
+	for za0001 := range z.SigningInfos {
+		if z.SigningInfos[za0001] == nil {
+			o = msgp.AppendNil(o)
+		} else {
+			o, err = z.SigningInfos[za0001].MarshalMsg(o)
+			if err != nil {
+				err = msgp.WrapError(err, "SigningInfos", za0001)
+				return
+			}
+		}
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *LivenessInfo) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "SigningInfos":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "SigningInfos")
+				return
+			}
+			if cap(z.SigningInfos) >= int(zb0002) {
+				z.SigningInfos = (z.SigningInfos)[:zb0002]
+			} else {
+				z.SigningInfos = make([]*SigningInfo, zb0002)
+			}
+			for za0001 := range z.SigningInfos {
+				if msgp.IsNil(bts) {
+					bts, err = msgp.ReadNilBytes(bts)
+					if err != nil {
+						return
+					}
+					z.SigningInfos[za0001] = nil
+				} else {
+					if z.SigningInfos[za0001] == nil {
+						z.SigningInfos[za0001] = new(SigningInfo)
+					}
+					bts, err = z.SigningInfos[za0001].UnmarshalMsg(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "SigningInfos", za0001)
+						return
+					}
+				}
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *LivenessInfo) Msgsize() (s int) {
+	s = 1 + 13 + msgp.ArrayHeaderSize
+	for za0001 := range z.SigningInfos {
+		if z.SigningInfos[za0001] == nil {
+			s += msgp.NilSize
+		} else {
+			s += z.SigningInfos[za0001].Msgsize()
+		}
+	}
+	return
+}
+
 // DecodeMsg implements msgp.Decodable
 func (z *Nomination) DecodeMsg(dc *msgp.Reader) (err error) {
 	var field []byte
@@ -759,6 +943,159 @@ func (z *PendingReward) Msgsize() (s int) {
 	return
 }
 
+// DecodeMsg implements msgp.Decodable
+func (z *SigningInfo) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Pubkey":
+			err = dc.ReadExactBytes((z.Pubkey)[:])
+			if err != nil {
+				err = msgp.WrapError(err, "Pubkey")
+				return
+			}
+		case "MissedBitmap":
+			z.MissedBitmap, err = dc.ReadBytes(z.MissedBitmap)
+			if err != nil {
+				err = msgp.WrapError(err, "MissedBitmap")
+				return
+			}
+		case "MissedCount":
+			z.MissedCount, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "MissedCount")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *SigningInfo) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "Pubkey"
+	err = en.Append(0x83, 0xa6, 0x50, 0x75, 0x62, 0x6b, 0x65, 0x79)
+	if err != nil {
+		return
+	}
+	err = en.WriteBytes((z.Pubkey)[:])
+	if err != nil {
+		err = msgp.WrapError(err, "Pubkey")
+		return
+	}
+	// write "MissedBitmap"
+	err = en.Append(0xac, 0x4d, 0x69, 0x73, 0x73, 0x65, 0x64, 0x42, 0x69, 0x74, 0x6d, 0x61, 0x70)
+	if err != nil {
+		return
+	}
+	err = en.WriteBytes(z.MissedBitmap)
+	if err != nil {
+		err = msgp.WrapError(err, "MissedBitmap")
+		return
+	}
+	// write "MissedCount"
+	err = en.Append(0xab, 0x4d, 0x69, 0x73, 0x73, 0x65, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt64(z.MissedCount)
+	if err != nil {
+		err = msgp.WrapError(err, "MissedCount")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *SigningInfo) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 3
+	// string "Pubkey"
+	o = append(o, 0x83, 0xa6, 0x50, 0x75, 0x62, 0x6b, 0x65, 0x79)
+	o = msgp.AppendBytes(o, (z.Pubkey)[:])
+	// string "MissedBitmap"
+	o = append(o, 0xac, 0x4d, 0x69, 0x73, 0x73, 0x65, 0x64, 0x42, 0x69, 0x74, 0x6d, 0x61, 0x70)
+	o = msgp.AppendBytes(o, z.MissedBitmap)
+	// string "MissedCount"
+	o = append(o, 0xab, 0x4d, 0x69, 0x73, 0x73, 0x65, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74)
+	o = msgp.AppendInt64(o, z.MissedCount)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *SigningInfo) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Pubkey":
+			bts, err = msgp.ReadExactBytes(bts, (z.Pubkey)[:])
+			if err != nil {
+				err = msgp.WrapError(err, "Pubkey")
+				return
+			}
+		case "MissedBitmap":
+			z.MissedBitmap, bts, err = msgp.ReadBytesBytes(bts, z.MissedBitmap)
+			if err != nil {
+				err = msgp.WrapError(err, "MissedBitmap")
+				return
+			}
+		case "MissedCount":
+			z.MissedCount, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "MissedCount")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *SigningInfo) Msgsize() (s int) {
+	s = 1 + 7 + msgp.ArrayHeaderSize + (32 * (msgp.ByteSize)) + 13 + msgp.BytesPrefixSize + len(z.MissedBitmap) + 12 + msgp.Int64Size
+	return
+}
+
 // DecodeMsg implements msgp.Decodable
 func (z *StakingInfo) DecodeMsg(dc *msgp.Reader) (err error) {
 	var field []byte
@@ -1135,6 +1472,18 @@ func (z *Validator) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "IsRetiring")
 				return
 			}
+		case "IsJailed":
+			z.IsJailed, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "IsJailed")
+				return
+			}
+		case "JailedUntil":
+			z.JailedUntil, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "JailedUntil")
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -1148,9 +1497,27 @@ func (z *Validator) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *Validator) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 7
+	// omitempty: check for empty values
+	zb0001Len := uint32(9)
+	var zb0001Mask uint16 /* 9 bits */
+	if z.IsJailed == false {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.JailedUntil == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	// variable map header, size zb0001Len
+	err = en.Append(0x80 | uint8(zb0001Len))
+	if err != nil {
+		return
+	}
+	if zb0001Len == 0 {
+		return
+	}
 	// write "Address"
-	err = en.Append(0x87, 0xa7, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73)
+	err = en.Append(0xa7, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73)
 	if err != nil {
 		return
 	}
@@ -1219,15 +1586,54 @@ func (z *Validator) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "IsRetiring")
 		return
 	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// write "IsJailed"
+		err = en.Append(0xa8, 0x49, 0x73, 0x4a, 0x61, 0x69, 0x6c, 0x65, 0x64)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.IsJailed)
+		if err != nil {
+			err = msgp.WrapError(err, "IsJailed")
+			return
+		}
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// write "JailedUntil"
+		err = en.Append(0xab, 0x4a, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x55, 0x6e, 0x74, 0x69, 0x6c)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt64(z.JailedUntil)
+		if err != nil {
+			err = msgp.WrapError(err, "JailedUntil")
+			return
+		}
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *Validator) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 7
+	// omitempty: check for empty values
+	zb0001Len := uint32(9)
+	var zb0001Mask uint16 /* 9 bits */
+	if z.IsJailed == false {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.JailedUntil == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	// variable map header, size zb0001Len
+	o = append(o, 0x80|uint8(zb0001Len))
+	if zb0001Len == 0 {
+		return
+	}
 	// string "Address"
-	o = append(o, 0x87, 0xa7, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73)
+	o = append(o, 0xa7, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73)
 	o = msgp.AppendBytes(o, (z.Address)[:])
 	// string "Pubkey"
 	o = append(o, 0xa6, 0x50, 0x75, 0x62, 0x6b, 0x65, 0x79)
@@ -1247,6 +1653,16 @@ func (z *Validator) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "IsRetiring"
 	o = append(o, 0xaa, 0x49, 0x73, 0x52, 0x65, 0x74, 0x69, 0x72, 0x69, 0x6e, 0x67)
 	o = msgp.AppendBool(o, z.IsRetiring)
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// string "IsJailed"
+		o = append(o, 0xa8, 0x49, 0x73, 0x4a, 0x61, 0x69, 0x6c, 0x65, 0x64)
+		o = msgp.AppendBool(o, z.IsJailed)
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// string "JailedUntil"
+		o = append(o, 0xab, 0x4a, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x55, 0x6e, 0x74, 0x69, 0x6c)
+		o = msgp.AppendInt64(o, z.JailedUntil)
+	}
 	return
 }
 
@@ -1310,6 +1726,18 @@ func (z *Validator) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "IsRetiring")
 				return
 			}
+		case "IsJailed":
+			z.IsJailed, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "IsJailed")
+				return
+			}
+		case "JailedUntil":
+			z.JailedUntil, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "JailedUntil")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -1324,6 +1752,6 @@ func (z *Validator) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Validator) Msgsize() (s int) {
-	s = 1 + 8 + msgp.ArrayHeaderSize + (20 * (msgp.ByteSize)) + 7 + msgp.ArrayHeaderSize + (32 * (msgp.ByteSize)) + 9 + msgp.ArrayHeaderSize + (20 * (msgp.ByteSize)) + 12 + msgp.Int64Size + 13 + msgp.StringPrefixSize + len(z.Introduction) + 12 + msgp.ArrayHeaderSize + (32 * (msgp.ByteSize)) + 11 + msgp.BoolSize
+	s = 1 + 8 + msgp.ArrayHeaderSize + (20 * (msgp.ByteSize)) + 7 + msgp.ArrayHeaderSize + (32 * (msgp.ByteSize)) + 9 + msgp.ArrayHeaderSize + (20 * (msgp.ByteSize)) + 12 + msgp.Int64Size + 13 + msgp.StringPrefixSize + len(z.Introduction) + 12 + msgp.ArrayHeaderSize + (32 * (msgp.ByteSize)) + 11 + msgp.BoolSize + 9 + msgp.BoolSize + 12 + msgp.Int64Size
 	return
 }